@@ -0,0 +1,112 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import "sync"
+
+type afterFunc struct {
+	f func()
+}
+
+// afterFuncs holds the functions registered for a stopper event.
+type afterFuncs struct {
+	fired bool
+	fns   map[*afterFunc]struct{}
+}
+
+// AfterQuiesce arranges to call f in its own goroutine once the stopper begins
+// to quiesce. If the stopper is already quiescing, f is called immediately in
+// its own goroutine.
+//
+// Like context.AfterFunc, it returns a function which deregisters f. The
+// function returns true if the call stopped f from being run, and false if f
+// has already been started or the call was stopped already.
+func (s *Stopper) AfterQuiesce(f func()) (stop func() bool) {
+	return s.afterFunc(&s.mu.afterQuiesce, f)
+}
+
+// AfterDrain is like AfterQuiesce, but f is called once the stopper begins to
+// drain, as signaled by ShouldDrain().
+func (s *Stopper) AfterDrain(f func()) (stop func() bool) {
+	return s.afterFunc(&s.mu.afterDrain, f)
+}
+
+// AfterStop is like AfterQuiesce, but f is called once the stopper has
+// stopped, as signaled by IsStopped().
+func (s *Stopper) AfterStop(f func()) (stop func() bool) {
+	return s.afterFunc(&s.mu.afterStop, f)
+}
+
+func (s *Stopper) afterFunc(fs *afterFuncs, f func()) func() bool {
+	return fs.add(&s.mu, f)
+}
+
+// add registers f to be called when the event fires, with the fields of fs
+// guarded by mu.
+func (fs *afterFuncs) add(mu sync.Locker, f func()) func() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	if fs.fired {
+		go f()
+		return func() bool { return false }
+	}
+
+	af := &afterFunc{f}
+	if fs.fns == nil {
+		fs.fns = map[*afterFunc]struct{}{}
+	}
+	fs.fns[af] = struct{}{}
+	return func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		_, ok := fs.fns[af]
+		delete(fs.fns, af)
+		return ok
+	}
+}
+
+// fireLocked starts the functions registered for an event.
+func (s *Stopper) fireLocked(fs *afterFuncs) {
+	fs.fired = true
+	for af := range fs.fns {
+		go af.f()
+	}
+	fs.fns = nil
+}
+
+// NotifyOnQuiesce arranges for a value to be sent on ch once the stopper
+// begins to quiesce, for select loops which can't wait on ShouldQuiesce()
+// directly, for instance because they select on a fixed set of channels. Like
+// signal.Notify, it does not block sending on ch, so ch should be buffered.
+// The returned function deregisters ch, as with AfterQuiesce.
+func (s *Stopper) NotifyOnQuiesce(ch chan<- struct{}) (stop func() bool) {
+	return s.AfterQuiesce(notifyFunc(ch))
+}
+
+// NotifyOnStop is like NotifyOnQuiesce, but the value is sent once the
+// stopper has stopped, as signaled by IsStopped().
+func (s *Stopper) NotifyOnStop(ch chan<- struct{}) (stop func() bool) {
+	return s.AfterStop(notifyFunc(ch))
+}
+
+// notifyFunc returns a function sending on ch without blocking.
+func notifyFunc(ch chan<- struct{}) func() {
+	return func() {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
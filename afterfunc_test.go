@@ -0,0 +1,86 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperAfterFuncs(t *testing.T) {
+	s := stop.NewStopper()
+
+	drained := make(chan struct{})
+	s.AfterDrain(func() { close(drained) })
+	quiesced := make(chan struct{})
+	s.AfterQuiesce(func() { close(quiesced) })
+	stopped := make(chan struct{})
+	s.AfterStop(func() { close(stopped) })
+
+	deregister := s.AfterStop(func() { t.Error("deregistered function should not run") })
+	if !deregister() {
+		t.Fatal("expected deregistration to stop the function")
+	}
+	if deregister() {
+		t.Fatal("expected second deregistration to report false")
+	}
+
+	s.Stop(context.Background())
+
+	for _, ch := range []chan struct{}{drained, quiesced, stopped} {
+		select {
+		case <-ch:
+		case <-time.After(time.Second):
+			t.Fatal("expected registered function to run")
+		}
+	}
+
+	// Functions registered after the event run immediately.
+	late := make(chan struct{})
+	if s.AfterQuiesce(func() { close(late) })() {
+		t.Fatal("expected deregistration after the event to report false")
+	}
+	select {
+	case <-late:
+	case <-time.After(time.Second):
+		t.Fatal("expected late function to run")
+	}
+}
+
+func TestStopperNotify(t *testing.T) {
+	s := stop.NewStopper()
+
+	quiesced := make(chan struct{}, 1)
+	s.NotifyOnQuiesce(quiesced)
+	stopped := make(chan struct{}, 1)
+	s.NotifyOnStop(stopped)
+	// A full channel doesn't block the stopper.
+	full := make(chan struct{})
+	s.NotifyOnStop(full)
+
+	s.Stop(context.Background())
+
+	for _, ch := range []chan struct{}{quiesced, stopped} {
+		select {
+		case <-ch:
+		case <-time.After(time.Second):
+			t.Fatal("expected notification")
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// An asyncTask records an async task submitted with RunAsyncTask, so that it
+// can be handed to the goroutine running it without allocating. Records are
+// reused through asyncTasks.
+type asyncTask struct {
+	s   *Stopper
+	ctx context.Context
+	key taskKey
+	o   taskOptions
+	f   func(context.Context)
+
+	// run calls exec. It is created once per record, as spawning a goroutine
+	// with a method value or a closure capturing the record would allocate.
+	run func()
+}
+
+var asyncTasks sync.Pool
+
+func init() {
+	asyncTasks.New = func() interface{} {
+		t := &asyncTask{}
+		t.run = t.exec
+		return t
+	}
+}
+
+// newAsyncTask returns an unused record for an async task.
+func newAsyncTask(s *Stopper, ctx context.Context, f func(context.Context)) *asyncTask {
+	t := asyncTasks.Get().(*asyncTask)
+	t.s, t.ctx, t.f = s, ctx, f
+	return t
+}
+
+// release clears the record, so it doesn't retain the task, and returns it
+// for reuse.
+func (t *asyncTask) release() {
+	*t = asyncTask{run: t.run}
+	asyncTasks.Put(t)
+}
+
+// exec runs the task and releases the record.
+func (t *asyncTask) exec() {
+	t.s.execAsyncTask(t)
+	t.release()
+}
+
+func (s *Stopper) execAsyncTask(t *asyncTask) {
+	defer s.recoverTask(t.ctx, t.key, &t.o, nil)
+	defer s.runPostlude(t.key)
+	//defer tracing.FinishSpan(span)
+
+	s.runTaskFunc(t.ctx, t.key, &t.o, true, t.f)
+}
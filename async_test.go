@@ -0,0 +1,99 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/birkelund/stop"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperRunAsyncTaskAllocs(t *testing.T) {
+	ctx := context.Background()
+	s := stop.NewStopper()
+	defer s.Stop(ctx)
+	done := make(chan struct{})
+	f := func(context.Context) { done <- struct{}{} }
+	if n := testing.AllocsPerRun(100, func() {
+		if err := s.RunAsyncTask(ctx, f); err != nil {
+			t.Fatal(err)
+		}
+		<-done
+	}); n != 0 {
+		t.Errorf("expected RunAsyncTask not to allocate, got %.1f allocs", n)
+	}
+}
+
+// TestStopperRunAsyncTaskConcurrent verifies that concurrently running async
+// tasks each see their own context and function.
+func TestStopperRunAsyncTaskConcurrent(t *testing.T) {
+	s := stop.NewStopper()
+	const n = 100
+	var wg sync.WaitGroup
+	release := make(chan struct{})
+	results := make([]int, n)
+	for i := 0; i < n; i++ {
+		i := i
+		ctx := context.WithValue(context.Background(), ctxKey{}, i)
+		wg.Add(1)
+		if err := s.RunAsyncTask(ctx, func(ctx context.Context) {
+			defer wg.Done()
+			<-release
+			results[i] = ctx.Value(ctxKey{}).(int)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(release)
+	wg.Wait()
+	s.Stop(context.Background())
+	for i, r := range results {
+		if r != i {
+			t.Errorf("task %d saw context of task %d", i, r)
+		}
+	}
+}
+
+func TestStopperGo(t *testing.T) {
+	s := stop.NewStopper()
+	release := make(chan struct{})
+	done := make(chan struct{})
+	s.Go(func() {
+		<-release
+		close(done)
+	})
+
+	// The task is tracked at the call site of Go.
+	tasks := s.RunningTasks()
+	if len(tasks) != 1 {
+		t.Errorf("expected 1 running task, got %v", tasks)
+	}
+	for task := range tasks {
+		if !strings.HasPrefix(task, "async_test.go:") {
+			t.Errorf("expected task at call site of Go, got %s", task)
+		}
+	}
+	close(release)
+	<-done
+	s.Stop(context.Background())
+
+	s.Go(func() {
+		t.Error("unexpected call after Stop")
+	})
+}
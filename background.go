@@ -0,0 +1,142 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// DefaultBackgroundGracePeriod is the time Stop waits for background tasks
+// to finish, unless changed with the BackgroundGracePeriod option.
+const DefaultBackgroundGracePeriod = time.Second
+
+type backgroundTask struct {
+	key    taskKey
+	cancel func()
+}
+
+type optionBackgroundGracePeriod time.Duration
+
+func (obgp optionBackgroundGracePeriod) apply(stopper *Stopper) {
+	stopper.backgroundGrace = time.Duration(obgp)
+}
+
+// BackgroundGracePeriod is an option which sets the time Stop waits for
+// background tasks (see RunBackgroundTask) to finish after their contexts
+// have been canceled.
+func BackgroundGracePeriod(d time.Duration) Option {
+	return optionBackgroundGracePeriod(d)
+}
+
+// RunBackgroundTask runs function f in a goroutine as a best-effort background
+// task, such as a cache refresh or a telemetry upload. Background tasks are
+// tracked (see BackgroundTasks), but do not hold up quiescing. Instead, the
+// context passed to f is canceled when the stopper begins to quiesce, and Stop
+// waits at most the background grace period for them to finish before
+// abandoning them.
+//
+// It returns an error when the Stopper is quiescing, in which case the
+// function is not executed.
+func (s *Stopper) RunBackgroundTask(
+	ctx context.Context, f func(context.Context), opts ...TaskOption,
+) error {
+	o := makeTaskOptions(opts)
+	key := s.makeTaskKey(&o)
+
+	ctx, cancel := context.WithCancel(ctx)
+	t := &backgroundTask{key, cancel}
+
+	s.mu.Lock()
+	if s.quiescing.Load() {
+		err := s.errUnavailableLocked()
+		s.mu.Unlock()
+		cancel()
+		return newTaskError(key, &o, err)
+	}
+	s.mu.background[t] = struct{}{}
+	s.mu.Unlock()
+
+	s.goTask(func() {
+		defer s.recoverTask(ctx, key, &o, nil)
+		defer func() {
+			s.mu.Lock()
+			delete(s.mu.background, t)
+			s.mu.quiesce.Broadcast()
+			s.mu.Unlock()
+			cancel()
+		}()
+
+		s.runTaskFunc(ctx, key, &o, true, f)
+	})
+	return nil
+}
+
+// BackgroundTasks returns a map containing the count of running background
+// tasks keyed by call site.
+func (s *Stopper) BackgroundTasks() TaskMap {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.backgroundTasksLocked()
+}
+
+func (s *Stopper) backgroundTasksLocked() TaskMap {
+	m := map[string]int{}
+	for t := range s.mu.background {
+		m[t.key.String()]++
+	}
+	return m
+}
+
+// cancelBackgroundTasksLocked cancels the contexts of all running background
+// tasks.
+func (s *Stopper) cancelBackgroundTasksLocked() {
+	for t := range s.mu.background {
+		t.cancel()
+	}
+}
+
+// waitForBackgroundTasks waits at most the background grace period for
+// background tasks to finish. It must only be called after quiescing.
+func (s *Stopper) waitForBackgroundTasks() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Wake up the wait below when the grace period expires. Unlike waiting
+	// for the tasks in a goroutine, this does not leave a goroutine behind
+	// when tasks are abandoned.
+	expired := false
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-s.clock.After(s.backgroundGrace):
+			s.mu.Lock()
+			expired = true
+			s.mu.quiesce.Broadcast()
+			s.mu.Unlock()
+		case <-done:
+		}
+	}()
+
+	for len(s.mu.background) > 0 {
+		if expired {
+			s.logger.Printf("abandoning background tasks:\n%s", s.backgroundTasksLocked())
+			return
+		}
+		s.mu.quiesce.Wait()
+	}
+}
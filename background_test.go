@@ -0,0 +1,76 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperRunBackgroundTask(t *testing.T) {
+	s := stop.NewStopper(stop.BackgroundGracePeriod(50 * time.Millisecond))
+	ctx := context.Background()
+
+	canceled := make(chan struct{})
+	if err := s.RunBackgroundTask(ctx, func(ctx context.Context) {
+		<-ctx.Done()
+		close(canceled)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A background task which never finishes.
+	block := make(chan struct{})
+	defer close(block)
+	if err := s.RunBackgroundTask(ctx, func(context.Context) {
+		<-block
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := s.NumTasks(); n != 0 {
+		t.Fatalf("expected background tasks not to be counted as tasks, got %d", n)
+	}
+	if m := s.BackgroundTasks(); len(m) != 2 {
+		t.Fatalf("expected 2 background task call sites, got %+v", m)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.Stop(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("expected background task context to be canceled")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected Stop() to abandon the blocked background task")
+	}
+
+	if err := s.RunBackgroundTask(ctx, func(context.Context) {}); !errors.Is(err, stop.ErrStopped) {
+		t.Fatalf("expected %v; got %v", stop.ErrStopped, err)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// A Barrier is a rendezvous point for a fixed number of parties, typically
+// workers of a stopper, for instance to start serving only once all of them
+// have caught up, or to run the closers only once all of them have flushed.
+// A Barrier is used once: it trips when the last party arrives, and stays
+// tripped.
+type Barrier struct {
+	s       *Stopper
+	mu      sync.Mutex
+	waiting int // parties yet to arrive
+	done    chan struct{}
+}
+
+// NewBarrier returns a Barrier which trips once n parties have arrived.
+func (s *Stopper) NewBarrier(n int) *Barrier {
+	b := &Barrier{s: s, waiting: n, done: make(chan struct{})}
+	if n <= 0 {
+		close(b.done)
+	}
+	return b
+}
+
+// Arrive records the arrival of a party without waiting for the others, for
+// parties which need not wait, such as workers flushing at shutdown.
+func (b *Barrier) Arrive() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.waiting > 0 {
+		b.waiting--
+		if b.waiting == 0 {
+			close(b.done)
+		}
+	}
+}
+
+// Wait records the arrival of a party and waits for the others. It returns
+// nil once the barrier has tripped. If ctx is done or the stopper begins to
+// quiesce first, the party withdraws, so that the barrier does not trip
+// without it, and the error of ctx, or ErrQuiescing or ErrStopped, is
+// returned.
+func (b *Barrier) Wait(ctx context.Context) error {
+	b.Arrive()
+
+	var err error
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-b.s.ShouldQuiesce():
+		err = b.s.errUnavailable()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	select {
+	case <-b.done:
+		// The barrier tripped after all.
+		return nil
+	default:
+	}
+	b.waiting++
+	return err
+}
+
+// Done returns a channel which is closed once the barrier has tripped.
+func (b *Barrier) Done() <-chan struct{} {
+	return b.done
+}
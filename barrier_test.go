@@ -0,0 +1,88 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/birkelund/stop"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+func TestBarrier(t *testing.T) {
+	s := stop.NewStopper()
+	defer s.Stop(context.Background())
+	ctx := context.Background()
+
+	const n = 3
+	b := s.NewBarrier(n)
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		if err := s.RunWorker(ctx, func(ctx context.Context) {
+			defer wg.Done()
+			errs <- b.Wait(ctx)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-b.Done()
+}
+
+func TestBarrierWithdraw(t *testing.T) {
+	s := stop.NewStopper()
+	defer s.Stop(context.Background())
+
+	b := s.NewBarrier(2)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := b.Wait(ctx); err != context.Canceled {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+	// The canceled party withdrew, so one arrival doesn't trip the barrier.
+	b.Arrive()
+	select {
+	case <-b.Done():
+		t.Fatal("expected barrier not to trip")
+	default:
+	}
+	b.Arrive()
+	<-b.Done()
+}
+
+func TestBarrierQuiesce(t *testing.T) {
+	s := stop.NewStopper()
+	b := s.NewBarrier(2)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- b.Wait(context.Background())
+	}()
+	s.Stop(context.Background())
+	if err := <-errCh; !errors.Is(err, stop.ErrUnavailable) {
+		t.Fatalf("expected %v, got %v", stop.ErrUnavailable, err)
+	}
+}
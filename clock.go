@@ -0,0 +1,78 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import "time"
+
+// A Clock provides the time to the time-based behavior of the stopper, such
+// as the background grace period, supervisor backoff, heartbeat deadlines and
+// acquisition timeouts. It can be replaced with the WithClock option, for
+// instance by a fake clock in tests (see stoptest.FakeClock).
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// After returns a channel on which the time is sent once d has elapsed.
+	After(d time.Duration) <-chan time.Time
+	// NewTicker returns a Ticker which sends the time every d.
+	NewTicker(d time.Duration) Ticker
+}
+
+// A Ticker is returned by Clock.NewTicker.
+type Ticker interface {
+	// Chan returns the channel on which the ticks are sent.
+	Chan() <-chan time.Time
+	// Stop turns off the ticker.
+	Stop()
+}
+
+// RealClock is the Clock used unless the WithClock option is given. It is
+// backed by the time package.
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (rt realTicker) Chan() <-chan time.Time {
+	return rt.C
+}
+
+type optionClock struct {
+	clock Clock
+}
+
+func (oc optionClock) apply(stopper *Stopper) {
+	stopper.clock = oc.clock
+}
+
+// WithClock is an option which makes the stopper use the given clock instead
+// of RealClock.
+func WithClock(clock Clock) Option {
+	return optionClock{clock}
+}
@@ -0,0 +1,50 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+	"github.com/birkelund/stop/stoptest"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperWithClock(t *testing.T) {
+	c := stoptest.NewFakeClock(time.Unix(0, 0))
+	s := stop.NewStopper(stop.WithClock(c))
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	started := make(chan struct{})
+	if err := s.RunWorkerWithHeartbeat(ctx, "idle", time.Minute, func(ctx context.Context, _ *stop.Heartbeat) {
+		close(started)
+		<-s.ShouldStop()
+	}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	c.Advance(time.Minute)
+	if w := s.WedgedWorkers(); len(w) != 0 {
+		t.Fatalf("expected no wedged workers, got %+v", w)
+	}
+	c.Advance(time.Second)
+	if w := s.WedgedWorkers(); len(w) != 1 || w[0].Name != "idle" {
+		t.Fatalf("expected worker to be wedged, got %+v", w)
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"time"
+)
+
+// CloserErrFn is a type that allows any function returning an error, such as
+// the Close method of a type which does not implement io.Closer, to be used
+// as an io.Closer (see AddIOCloser).
+type CloserErrFn func() error
+
+// Close implements the io.Closer interface.
+func (f CloserErrFn) Close() error {
+	return f()
+}
+
+// ioCloser adapts an io.Closer to the Closer interface. The stopper closes it
+// through closeErr, so the error is not lost.
+type ioCloser struct {
+	c io.Closer
+}
+
+func (ic ioCloser) Close() {
+	_ = ic.c.Close()
+}
+
+// IOCloser returns a Closer closing c, for use with AddCloserToStage and
+// AddComponent. When closed by Stop, an error returned by c is recorded as a
+// CloserError (see CloserErrors).
+func IOCloser(c io.Closer) Closer {
+	return ioCloser{c}
+}
+
+// AddIOCloser is like AddCloser, but accepts an io.Closer, such as a file or
+// a network listener. An error returned by c is recorded as a CloserError
+// (see CloserErrors) and logged.
+func (s *Stopper) AddIOCloser(c io.Closer) {
+	s.AddCloser(IOCloser(c))
+}
+
+// A CloserError is an error returned by a closer added as an io.Closer.
+type CloserError struct {
+	// Closer identifies the closer as in CloserOverrun.
+	Closer string
+	// Err is the error returned by the closer.
+	Err error
+}
+
+func (e *CloserError) Error() string {
+	return fmt.Sprintf("closer %s: %v", e.Closer, e.Err)
+}
+
+// Unwrap returns the error returned by the closer.
+func (e *CloserError) Unwrap() error {
+	return e.Err
+}
+
+// CloserErrors returns the errors returned by closers during Stop, as
+// *CloserError, in the order the closers were closed.
+func (s *Stopper) CloserErrors() []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]error(nil), s.mu.closeErrors...)
+}
+
+// A CloserOverrun describes a closer which did not return within the closer
+// timeout (see CloserTimeout).
+type CloserOverrun struct {
+	// Closer is the component name (see AddComponent) of the closer or, for
+	// other closers, its type or function name.
+	Closer string
+	// Stack is the stack trace of the goroutine running the closer at the time
+	// the timeout expired.
+	Stack []byte
+}
+
+type optionCloserTimeout time.Duration
+
+func (oct optionCloserTimeout) apply(stopper *Stopper) {
+	stopper.closerTimeout = time.Duration(oct)
+}
+
+// CloserTimeout is an option which bounds the time Stop waits for each
+// closer. A closer which has not returned when the timeout expires is left
+// running, recorded as a CloserOverrun (see CloserOverruns) and logged, and
+// Stop carries on with the remaining closers rather than hang.
+func CloserTimeout(d time.Duration) Option {
+	return optionCloserTimeout(d)
+}
+
+// CloserOverruns returns the closers which exceeded the closer timeout during
+// Stop, in the order they were closed.
+func (s *Stopper) CloserOverruns() []CloserOverrun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]CloserOverrun(nil), s.mu.overruns...)
+}
+
+// closeCloser closes c, giving up after the closer timeout, if any, and
+// returns the error returned by an io.Closer. It must be called without the
+// stopper lock held.
+func (s *Stopper) closeCloser(c stagedCloser) error {
+	if s.closerTimeout <= 0 {
+		return c.closeErr()
+	}
+
+	var err error
+	done := make(chan struct{})
+	gid := make(chan []byte, 1)
+	go func() {
+		defer close(done)
+		gid <- goroutineID()
+		err = c.closeErr()
+	}()
+	select {
+	case <-done:
+		return err
+	case <-s.clock.After(s.closerTimeout):
+	}
+
+	overrun := CloserOverrun{Closer: c.String(), Stack: goroutineStack(<-gid)}
+	s.logger.Printf("closer %s did not return within %s:\n%s", overrun.Closer, s.closerTimeout, overrun.Stack)
+	s.mu.Lock()
+	s.mu.overruns = append(s.mu.overruns, overrun)
+	s.mu.Unlock()
+	return nil
+}
+
+// closeErr closes the closer, returning the error of an io.Closer.
+func (c stagedCloser) closeErr() error {
+	if ic, ok := c.Closer.(ioCloser); ok {
+		return ic.c.Close()
+	}
+	c.Close()
+	return nil
+}
+
+// String returns the component name of the closer, or otherwise its type or
+// function name.
+func (c stagedCloser) String() string {
+	if c.name != "" {
+		return c.name
+	}
+	var closer interface{} = c.Closer
+	if ic, ok := closer.(ioCloser); ok {
+		closer = ic.c
+	}
+	if dc, ok := closer.(drainableCloser); ok {
+		closer = dc.d
+	}
+	if v := reflect.ValueOf(closer); v.Kind() == reflect.Func {
+		if f := runtime.FuncForPC(v.Pointer()); f != nil {
+			return f.Name()
+		}
+	}
+	return fmt.Sprintf("%T", closer)
+}
+
+// goroutineID returns the ID of the calling goroutine, as shown in stack
+// traces.
+func goroutineID() []byte {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	// The trace begins with "goroutine 123 [running]:".
+	if fields := bytes.Fields(buf); len(fields) > 1 {
+		return fields[1]
+	}
+	return nil
+}
+
+// goroutineStack returns the stack trace of the goroutine with the given ID.
+func goroutineStack(id []byte) []byte {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	prefix := append(append([]byte("goroutine "), id...), " ["...)
+	for _, trace := range bytes.Split(buf, []byte("\n\n")) {
+		if bytes.HasPrefix(trace, prefix) {
+			return trace
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperCloserTimeout(t *testing.T) {
+	s := stop.NewStopper(stop.CloserTimeout(10 * time.Millisecond))
+	release := make(chan struct{})
+	defer close(release)
+
+	var closed bool
+	if err := s.AddComponent("db", stop.CloserFn(func() { <-release })); err != nil {
+		t.Fatal(err)
+	}
+	s.AddCloser(stop.CloserFn(func() { <-release }))
+	s.AddCloser(stop.CloserFn(func() { closed = true }))
+
+	s.Stop(context.Background())
+
+	if !closed {
+		t.Error("expected the closer after the overruns to be closed")
+	}
+	overruns := s.CloserOverruns()
+	if len(overruns) != 2 {
+		t.Fatalf("expected 2 overruns, got %+v", overruns)
+	}
+	if overruns[0].Closer != "db" {
+		t.Errorf("expected db to overrun, got %s", overruns[0].Closer)
+	}
+	if name := overruns[1].Closer; !strings.Contains(name, "TestStopperCloserTimeout") {
+		t.Errorf("expected closer to be named after its function, got %s", name)
+	}
+	for _, o := range overruns {
+		if !strings.Contains(string(o.Stack), "TestStopperCloserTimeout") {
+			t.Errorf("expected stack of the closer, got:\n%s", o.Stack)
+		}
+	}
+}
+
+func TestStopperAddIOCloser(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "closer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errFailed := errors.New("failed")
+	s := stop.NewStopper()
+	s.AddIOCloser(f)
+	s.AddCloserToStage(1, stop.IOCloser(stop.CloserErrFn(func() error {
+		return errFailed
+	})))
+	s.Stop(context.Background())
+
+	if _, err := f.Write([]byte("x")); err == nil {
+		t.Error("expected file to be closed")
+	}
+	errs := s.CloserErrors()
+	if len(errs) != 1 || !errors.Is(errs[0], errFailed) {
+		t.Fatalf("expected the closer error, got %v", errs)
+	}
+	var ce *stop.CloserError
+	if !errors.As(errs[0], &ce) || !strings.Contains(ce.Closer, "TestStopperAddIOCloser") {
+		t.Errorf("expected closer to be identified by function name, got %v", errs[0])
+	}
+	if closers := s.StopReport().Closers; len(closers) != 2 || closers[0].Err != nil || closers[1].Err != errs[0] {
+		t.Errorf("expected closer error in stop report, got %+v", closers)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Command stopmon prints the state of a stopper served by its JSON status
+// handler (see Stopper.StatusHandler), or of the stoppers listed on the
+// /debug/stopper page, for instance when a process will not terminate.
+//
+// Usage:
+//
+//	stopmon [-watch interval] [-timeout duration] url
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/birkelund/stop"
+	"github.com/pkg/errors"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("stopmon: ")
+
+	watch := flag.Duration("watch", 0, "poll the url at this interval instead of printing once")
+	timeout := flag.Duration("timeout", 10*time.Second, "give up on a request after this long")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: stopmon [-watch interval] [-timeout duration] url\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// A process which will not terminate may not answer either, so the
+	// requests must time out.
+	client := &http.Client{Timeout: *timeout}
+	for {
+		if err := show(os.Stdout, client, flag.Arg(0)); err != nil {
+			if *watch <= 0 {
+				log.Fatal(err)
+			}
+			log.Print(err)
+		}
+		if *watch <= 0 {
+			return
+		}
+		time.Sleep(*watch)
+		fmt.Println()
+	}
+}
+
+// show fetches the url with client and prints the state it describes to w.
+func show(w io.Writer, client *http.Client, url string) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("%s: %s", url, resp.Status)
+	}
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		// The text rendering of HandleDebug.
+		_, err := w.Write(body)
+		return err
+	}
+
+	var status stop.Status
+	if err := json.Unmarshal(body, &status); err != nil {
+		return errors.Wrapf(err, "%s", url)
+	}
+	printStatus(w, status)
+	return nil
+}
+
+func printStatus(w io.Writer, status stop.Status) {
+	name := status.Name
+	if name == "" {
+		name = "stopper"
+	}
+	fmt.Fprintf(w, "%s: %s for %s\n", name, status.State, status.TimeInState)
+	fmt.Fprintf(w, "%d tasks, %d background tasks, %d workers, %d closers\n",
+		status.NumTasks, len(status.BackgroundTasks), status.NumWorkers, status.NumClosers)
+	if len(status.Tasks) > 0 {
+		fmt.Fprintf(w, "running tasks:\n%s\n", status.Tasks)
+	}
+	if len(status.BackgroundTasks) > 0 {
+		fmt.Fprintf(w, "background tasks:\n%s\n", status.BackgroundTasks)
+	}
+	if len(status.Panics) > 0 {
+		fmt.Fprintf(w, "recovered panics:\n%s\n", status.Panics)
+	}
+	if status.State == "stopping" && status.NumWorkers > 0 {
+		fmt.Fprintf(w, "waiting for %d workers to exit before running closers\n", status.NumWorkers)
+	}
+	if status.Closer != "" {
+		fmt.Fprintf(w, "closing %s for %s\n", status.Closer, status.TimeInCloser)
+	}
+}
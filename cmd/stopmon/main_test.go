@@ -0,0 +1,108 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+
+	"golang.org/x/net/context"
+)
+
+func TestShow(t *testing.T) {
+	s := stop.NewStopper(stop.WithName("kv-server"))
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	block := make(chan struct{})
+	defer close(block)
+	if err := s.RunAsyncTask(ctx, func(context.Context) { <-block }, stop.TaskName("raft")); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/status", s.StatusHandler())
+	mux.HandleFunc("/debug/stopper", stop.HandleDebug)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	for _, path := range []string{"/status", "/debug/stopper"} {
+		var buf bytes.Buffer
+		if err := show(&buf, http.DefaultClient, srv.URL+path); err != nil {
+			t.Fatal(err)
+		}
+		for _, expected := range []string{"kv-server", "running", "raft"} {
+			if !strings.Contains(buf.String(), expected) {
+				t.Errorf("%s: expected %q in output:\n%s", path, expected, buf.String())
+			}
+		}
+	}
+
+	if err := show(&bytes.Buffer{}, http.DefaultClient, srv.URL+"/missing"); err == nil {
+		t.Fatal("expected error for missing endpoint")
+	}
+}
+
+func TestShowStuckCloser(t *testing.T) {
+	s := stop.NewStopper(stop.WithName("kv-server"))
+	ctx := context.Background()
+
+	closing := make(chan struct{})
+	release := make(chan struct{})
+	s.AddCloser(stop.CloserFn(func() {
+		close(closing)
+		<-release
+	}))
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		s.Stop(ctx)
+	}()
+	defer func() {
+		close(release)
+		<-stopped
+	}()
+	<-closing
+
+	srv := httptest.NewServer(s.StatusHandler())
+	defer srv.Close()
+	var buf bytes.Buffer
+	if err := show(&buf, http.DefaultClient, srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "closing github.com/birkelund/stop/cmd/stopmon.TestShowStuckCloser.func1 for ") {
+		t.Errorf("expected the stuck closer in output:\n%s", buf.String())
+	}
+}
+
+func TestShowTimeout(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		<-block
+	}))
+	defer srv.Close()
+	defer close(block)
+
+	client := &http.Client{Timeout: 10 * time.Millisecond}
+	if err := show(&bytes.Buffer{}, client, srv.URL); err == nil {
+		t.Fatal("expected a timeout")
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import "golang.org/x/net/context"
+
+// This file provides the newer API of the CockroachDB stopper this package
+// derives from, so that code and patches written against it carry over.
+// Closer and CloserFn already have the upstream shape, and AddCloser closes a
+// closer added after Stop immediately, as upstream does. The upstream
+// ShouldQuiesce-only model is a follow-up, see the package documentation.
+
+// TaskOpts groups the options of RunAsyncTaskEx.
+type TaskOpts struct {
+	// TaskName is the name of the task, as with the TaskName option.
+	TaskName string
+	// Sem, if set, limits the number of tasks run concurrently, as with
+	// RunLimitedAsyncTaskWithSemaphore.
+	Sem Semaphore
+	// WaitForSem makes the task wait for the semaphore to be available rather
+	// than fail with ErrThrottled.
+	WaitForSem bool
+}
+
+// RunAsyncTaskEx is like RunAsyncTask, or RunLimitedAsyncTaskWithSemaphore if
+// opt.Sem is set, with the task configured by opt.
+func (s *Stopper) RunAsyncTaskEx(ctx context.Context, opt TaskOpts, f func(context.Context)) error {
+	o := makeTaskOptions(nil)
+	o.name = opt.TaskName
+	key := s.makeTaskKey(&o)
+	if opt.Sem != nil {
+		return newTaskError(key, &o, s.runLimitedAsyncTask(ctx, opt.Sem, opt.WaitForSem, f, key, &o))
+	}
+	t := newAsyncTask(s, ctx, f)
+	t.o, t.key = o, key
+	return s.startAsyncTask(t)
+}
+
+// WithCancelOnQuiesce is QuiesceContext.
+func (s *Stopper) WithCancelOnQuiesce(ctx context.Context) (context.Context, func()) {
+	return s.QuiesceContext(ctx)
+}
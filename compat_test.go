@@ -0,0 +1,91 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"testing"
+
+	"github.com/birkelund/stop"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperRunAsyncTaskEx(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+	sem := stop.ChanSemaphore(make(chan struct{}, 1))
+
+	block := make(chan struct{})
+	if err := s.RunAsyncTaskEx(ctx, stop.TaskOpts{TaskName: "sync", Sem: sem}, func(context.Context) {
+		<-block
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RunAsyncTaskEx(ctx, stop.TaskOpts{TaskName: "sync", Sem: sem}, func(context.Context) {
+		t.Error("unexpected call")
+	}); !errors.Is(err, stop.ErrThrottled) {
+		t.Fatalf("expected %v; got %v", stop.ErrThrottled, err)
+	}
+	if err := s.RunAsyncTaskEx(ctx, stop.TaskOpts{TaskName: "gc"}, func(context.Context) {
+		<-block
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if tasks := s.RunningTasks(); tasks["sync"] != 1 || tasks["gc"] != 1 {
+		t.Errorf("expected named tasks, got %v", tasks)
+	}
+	close(block)
+	s.Stop(ctx)
+
+	if err := s.RunAsyncTaskEx(ctx, stop.TaskOpts{}, func(context.Context) {
+		t.Error("unexpected call after Stop")
+	}); !errors.Is(err, stop.ErrUnavailable) {
+		t.Fatalf("expected %v; got %v", stop.ErrUnavailable, err)
+	}
+}
+
+func TestStopperWithCancelOnQuiesce(t *testing.T) {
+	s := stop.NewStopper()
+	ctx, cancel := s.WithCancelOnQuiesce(context.Background())
+	defer cancel()
+	s.Stop(context.Background())
+	<-ctx.Done()
+}
+
+func TestStopperAddCloserAfterStop(t *testing.T) {
+	s := stop.NewStopper()
+	s.Stop(context.Background())
+
+	closed := false
+	s.AddCloser(stop.CloserFn(func() { closed = true }))
+	if !closed {
+		t.Error("expected a closer added after Stop to be closed immediately")
+	}
+
+	// Once reset, closers are kept until the next Stop.
+	if err := s.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	closed = false
+	s.AddCloser(stop.CloserFn(func() { closed = true }))
+	if closed {
+		t.Error("unexpected close before Stop")
+	}
+	s.Stop(context.Background())
+	if !closed {
+		t.Error("expected the closer to be closed by Stop")
+	}
+}
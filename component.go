@@ -0,0 +1,207 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+// AddComponent adds a named object to close after the stopper has been
+// stopped, like AddCloser, which is closed before the components it depends
+// on. For instance, an HTTP server depending on a session store, which in
+// turn depends on a database pool, is closed first and the pool last.
+//
+// Dependencies may name components which have not been added yet, and are
+// ignored if they are never added. AddComponent returns an error, without
+// adding the component, if the name is already taken or the dependencies
+// would form a cycle.
+//
+// Components are closed as part of stage 0 (see AddCloserToStage), after all
+// workers have exited. Within the stage, closers are closed in the order they
+// were added, except that a component is held back until the components
+// depending on it have been closed. The workers of components (see
+// RunComponentWorker) are stopped in the same order, before the other
+// workers.
+func (s *Stopper) AddComponent(name string, c Closer, dependsOn ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deps := map[string][]string{}
+	for _, sc := range s.mu.closers {
+		if sc.name != "" {
+			deps[sc.name] = sc.dependsOn
+		}
+	}
+	if _, ok := deps[name]; ok {
+		return errors.Errorf("component %q already added", name)
+	}
+	deps[name] = dependsOn
+	if cycle := dependencyCycle(deps, name); cycle != nil {
+		return errors.Errorf("component %q: dependency cycle %s", name, strings.Join(cycle, " -> "))
+	}
+	s.mu.closers = append(s.mu.closers, stagedCloser{Closer: c, name: name, dependsOn: dependsOn})
+	return nil
+}
+
+// componentWorkers are the running workers of a component.
+type componentWorkers struct {
+	cancels []context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// RunComponentWorker runs f as a worker (see RunWorker) of the named
+// component, which is stopped in dependency order (see AddComponent): once the
+// tasks have quiesced, the contexts of the workers of a component are
+// canceled, and Stop waits for them to exit before canceling those of the
+// components it depends on. Only then is ShouldStop closed for the other
+// workers, so a component worker must exit when its context is canceled
+// rather than wait for ShouldStop.
+//
+// The component need not have been added with AddComponent, in which case
+// it depends on no other component.
+func (s *Stopper) RunComponentWorker(
+	ctx context.Context, component string, f func(context.Context),
+) error {
+	s.mu.Lock()
+	if s.quiescing.Load() {
+		defer s.mu.Unlock()
+		return s.errUnavailableLocked()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	cw := s.mu.components[component]
+	if cw == nil {
+		cw = &componentWorkers{}
+		if s.mu.components == nil {
+			s.mu.components = map[string]*componentWorkers{}
+		}
+		s.mu.components[component] = cw
+	}
+	cw.cancels = append(cw.cancels, cancel)
+	cw.wg.Add(1)
+	s.mu.Unlock()
+
+	err := s.RunWorker(ctx, func(ctx context.Context) {
+		defer cw.wg.Done()
+		defer cancel()
+		f(ctx)
+	})
+	if err != nil {
+		cw.wg.Done()
+		cancel()
+	}
+	return err
+}
+
+// stopComponentWorkers stops the workers of the components in dependency
+// order. It must only be called once no more workers are started.
+func (s *Stopper) stopComponentWorkers() {
+	s.mu.Lock()
+	workers := s.mu.components
+	var components []stagedCloser
+	for _, sc := range s.mu.closers {
+		if sc.name != "" {
+			components = append(components, stagedCloser{name: sc.name, dependsOn: sc.dependsOn})
+		}
+	}
+	s.mu.Unlock()
+	if len(workers) == 0 {
+		return
+	}
+
+	// Components which only have workers depend on nothing.
+	added := map[string]bool{}
+	for _, c := range components {
+		added[c.name] = true
+	}
+	var others []string
+	for name := range workers {
+		if !added[name] {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	for _, name := range others {
+		components = append(components, stagedCloser{name: name})
+	}
+
+	for _, c := range orderByDependencies(components) {
+		cw := workers[c.name]
+		if cw == nil {
+			continue
+		}
+		for _, cancel := range cw.cancels {
+			cancel()
+		}
+		cw.wg.Wait()
+	}
+}
+
+// dependencyCycle returns a path of dependencies from name back to itself,
+// or nil if there is none.
+func dependencyCycle(deps map[string][]string, name string) []string {
+	visited := map[string]bool{}
+	var visit func(n string, path []string) []string
+	visit = func(n string, path []string) []string {
+		path = append(path, n)
+		for _, d := range deps[n] {
+			if d == name {
+				return append(path, d)
+			}
+			if !visited[d] {
+				visited[d] = true
+				if cycle := visit(d, path); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		return nil
+	}
+	return visit(name, nil)
+}
+
+// orderByDependencies returns the closers ordered such that each component is
+// closed after the components depending on it, and otherwise in the given
+// order. There must be no dependency cycles.
+func orderByDependencies(closers []stagedCloser) []stagedCloser {
+	// dependents counts the closers depending on a component which have not
+	// been ordered yet.
+	dependents := map[string]int{}
+	for _, c := range closers {
+		for _, d := range c.dependsOn {
+			dependents[d]++
+		}
+	}
+	ordered := make([]stagedCloser, 0, len(closers))
+	done := make([]bool, len(closers))
+	for len(ordered) < len(closers) {
+		for i, c := range closers {
+			if done[i] || (c.name != "" && dependents[c.name] > 0) {
+				continue
+			}
+			done[i] = true
+			ordered = append(ordered, c)
+			for _, d := range c.dependsOn {
+				dependents[d]--
+			}
+			break
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,112 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/birkelund/stop"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperAddComponent(t *testing.T) {
+	s := stop.NewStopper()
+	var closed []string
+	closer := func(name string) stop.Closer {
+		return stop.CloserFn(func() { closed = append(closed, name) })
+	}
+	add := func(name string, dependsOn ...string) {
+		if err := s.AddComponent(name, closer(name), dependsOn...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add("db")
+	add("sessions", "db")
+	s.AddCloser(closer("cache"))
+	add("server", "sessions", "cache-less")
+	s.AddCloserToStage(1, closer("log"))
+
+	if err := s.AddComponent("db", closer("db")); err == nil {
+		t.Error("expected error adding a component twice")
+	}
+	err := s.AddComponent("cache-less", closer("cache-less"), "server")
+	if err == nil || !strings.Contains(err.Error(), "cache-less -> server -> cache-less") {
+		t.Errorf("expected dependency cycle error, got %v", err)
+	}
+	if err := s.AddComponent("self", closer("self"), "self"); err == nil {
+		t.Error("expected error for a component depending on itself")
+	}
+
+	s.Stop(context.Background())
+
+	expected := []string{"cache", "server", "sessions", "db", "log"}
+	if !reflect.DeepEqual(closed, expected) {
+		t.Errorf("expected closers to run in order %v, got %v", expected, closed)
+	}
+}
+
+func TestStopperRunComponentWorker(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+	if err := s.AddComponent("db", stop.CloserFn(func() {})); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddComponent("sessions", stop.CloserFn(func() {}), "db"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddComponent("server", stop.CloserFn(func() {}), "sessions"); err != nil {
+		t.Fatal(err)
+	}
+
+	stopped := make(chan string, 4)
+	run := func(component string) {
+		if err := s.RunComponentWorker(ctx, component, func(ctx context.Context) {
+			<-ctx.Done()
+			select {
+			case <-s.ShouldStop():
+				t.Errorf("%s: ShouldStop closed before the component workers exited", component)
+			default:
+			}
+			stopped <- component
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Start the workers in the wrong order; "db" has no worker of its own.
+	run("sessions")
+	run("server")
+	run("metrics")
+
+	s.Stop(ctx)
+	close(stopped)
+	var order []string
+	for component := range stopped {
+		order = append(order, component)
+	}
+	expected := []string{"server", "sessions", "metrics"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("expected workers to stop in order %v, got %v", expected, order)
+	}
+
+	if err := s.RunComponentWorker(ctx, "server", func(context.Context) {
+		t.Error("worker should not run")
+	}); !errors.Is(err, stop.ErrStopped) {
+		t.Fatalf("expected %v; got %v", stop.ErrStopped, err)
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// DefaultCommitInterval is the interval at which RunConsumer commits the
+// handled messages, unless the Consumer sets a CommitInterval.
+const DefaultCommitInterval = time.Second
+
+// A Consumer describes a message consumer run by RunConsumer, such as a
+// member of a Kafka consumer group or the subscriber of a queue.
+type Consumer[M any] struct {
+	// Fetch returns the next message, blocking until there is one. Its context
+	// is canceled once the stopper begins to drain, upon which Fetch should
+	// return promptly.
+	Fetch func(ctx context.Context) (M, error)
+	// Handle processes a message. It runs as an async task of the stopper.
+	Handle func(ctx context.Context, msg M) error
+	// Commit records messages as processed, for instance by committing their
+	// offsets. It is called with the messages handled without error since the
+	// previous call, in the order the handlers returned. It may be nil.
+	Commit func(ctx context.Context, msgs []M) error
+	// Concurrency bounds the number of messages handled at once. If it is not
+	// positive, messages are handled one at a time.
+	Concurrency int
+	// CommitInterval is the interval at which the handled messages are
+	// committed. If it is not positive, DefaultCommitInterval is used.
+	CommitInterval time.Duration
+}
+
+// RunConsumer runs a worker fetching messages with c.Fetch and handling each
+// in an async task with c.Handle, as limited by c.Concurrency. The options
+// apply to the handler tasks. Errors returned by the handlers, and a fetch
+// error, which ends the consumer, are handled like errors of tasks run with
+// RunAsync.
+//
+// Once the stopper begins to drain, the consumer stops fetching, waits for
+// the running handlers and commits the messages they handled. Stop waits for
+// this to finish before it begins to quiesce, at most until the drain hook
+// timeout expires (see DrainHookTimeout), so that offsets are committed
+// while the rest of the process is still running. A message fetched while
+// the stopper is quiescing is neither handled nor committed.
+//
+// Returns ErrUnavailable if the stopper is already stopping, in which case
+// the consumer is not run.
+func RunConsumer[M any](ctx context.Context, s *Stopper, c Consumer[M], opts ...TaskOption) error {
+	o := makeTaskOptions(opts)
+	key := s.makeTaskKey(&o)
+	if c.Concurrency <= 0 {
+		c.Concurrency = 1
+	}
+	if c.CommitInterval <= 0 {
+		c.CommitInterval = DefaultCommitInterval
+	}
+
+	done := make(chan struct{})
+	err := s.RunWorker(ctx, func(ctx context.Context) {
+		defer close(done)
+		cons := &consumer[M]{s: s, c: c, key: key, o: o}
+		cons.run(ctx)
+	})
+	if err != nil {
+		return err
+	}
+	s.OnDrain(func(ctx context.Context) error {
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	return nil
+}
+
+type consumer[M any] struct {
+	s   *Stopper
+	c   Consumer[M]
+	key taskKey
+	o   taskOptions
+
+	mu      sync.Mutex
+	handled []M
+}
+
+func (cons *consumer[M]) run(ctx context.Context) {
+	s := cons.s
+	fetchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer s.AfterDrain(cancel)()
+
+	var handlers sync.WaitGroup
+	fetched := make(chan struct{})
+	go func() {
+		defer close(fetched)
+		cons.fetch(ctx, fetchCtx, &handlers)
+	}()
+
+	ticker := s.clock.NewTicker(cons.c.CommitInterval)
+	defer ticker.Stop()
+loop:
+	for {
+		select {
+		case <-ticker.Chan():
+			cons.commit(ctx)
+		case <-fetched:
+			break loop
+		}
+	}
+	handlers.Wait()
+	cons.commit(ctx)
+}
+
+// fetch fetches messages and starts their handlers, until fetchCtx is done,
+// Fetch fails or the stopper refuses a handler.
+func (cons *consumer[M]) fetch(ctx, fetchCtx context.Context, handlers *sync.WaitGroup) {
+	s := cons.s
+	sem := make(chan struct{}, cons.c.Concurrency)
+	for {
+		msg, err := cons.c.Fetch(fetchCtx)
+		if err != nil {
+			if fetchCtx.Err() == nil {
+				s.asyncTaskFailed(cons.key, &cons.o, err)
+			}
+			return
+		}
+
+		sem <- struct{}{}
+		handlers.Add(1)
+		t := newAsyncTask(s, ctx, func(ctx context.Context) {
+			defer handlers.Done()
+			defer func() { <-sem }()
+			if err := cons.c.Handle(ctx, msg); err != nil {
+				s.asyncTaskFailed(cons.key, &cons.o, err)
+				return
+			}
+			cons.mu.Lock()
+			cons.handled = append(cons.handled, msg)
+			cons.mu.Unlock()
+		})
+		t.o, t.key = cons.o, cons.key
+		if err := s.startAsyncTask(t); err != nil {
+			handlers.Done()
+			<-sem
+			return
+		}
+	}
+}
+
+// commit commits the messages handled since the previous call.
+func (cons *consumer[M]) commit(ctx context.Context) {
+	cons.mu.Lock()
+	msgs := cons.handled
+	cons.handled = nil
+	cons.mu.Unlock()
+	if len(msgs) == 0 || cons.c.Commit == nil {
+		return
+	}
+	if err := cons.c.Commit(ctx, msgs); err != nil {
+		cons.s.logger.Printf("%v: commit failed: %v", cons.key, err)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+	"github.com/birkelund/stop/stoptest"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+// testQueue is a Consumer fetching from a channel and recording the commits.
+type testQueue struct {
+	msgs chan int
+
+	mu        sync.Mutex
+	committed []int
+	fetches   int
+}
+
+func (q *testQueue) fetch(ctx context.Context) (int, error) {
+	q.mu.Lock()
+	q.fetches++
+	q.mu.Unlock()
+	select {
+	case msg := <-q.msgs:
+		return msg, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+func (q *testQueue) commit(_ context.Context, msgs []int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.committed = append(q.committed, msgs...)
+	return nil
+}
+
+func (q *testQueue) numCommitted() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.committed)
+}
+
+func TestRunConsumer(t *testing.T) {
+	c := stoptest.NewFakeClock(time.Unix(0, 0))
+	s := stop.NewStopper(stop.WithClock(c))
+	ctx := context.Background()
+
+	q := &testQueue{msgs: make(chan int)}
+	release := make(chan struct{})
+	handling := make(chan int, 10)
+	if err := stop.RunConsumer(ctx, s, stop.Consumer[int]{
+		Fetch: q.fetch,
+		Handle: func(ctx context.Context, msg int) error {
+			handling <- msg
+			if msg == 3 {
+				<-release
+			}
+			if msg == 2 {
+				return errors.New("bad message")
+			}
+			return nil
+		},
+		Commit:      q.commit,
+		Concurrency: 2,
+	}, stop.TaskName("consumer")); err != nil {
+		t.Fatal(err)
+	}
+
+	q.msgs <- 1
+	<-handling
+	SucceedsSoon(t, func() error {
+		c.Advance(stop.DefaultCommitInterval)
+		if n := q.numCommitted(); n != 1 {
+			return errors.Errorf("%d messages committed", n)
+		}
+		return nil
+	})
+
+	q.msgs <- 2
+	q.msgs <- 3
+	<-handling
+	<-handling
+
+	// Stop waits for the handler of the last message before quiescing.
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		s.Stop(ctx)
+	}()
+	<-s.ShouldDrain()
+	select {
+	case <-s.ShouldQuiesce():
+		t.Fatal("quiesced with a message in flight")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	<-stopped
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.committed) != 2 || q.committed[0] != 1 || q.committed[1] != 3 {
+		t.Fatalf("expected [1 3] committed, got %v", q.committed)
+	}
+	if q.fetches != 4 {
+		t.Fatalf("expected 4 fetches, got %d", q.fetches)
+	}
+}
+
+func TestRunConsumerFetchError(t *testing.T) {
+	s := stop.NewStopper(stop.CollectTaskErrors())
+	ctx := context.Background()
+
+	errFetch := errors.New("connection lost")
+	if err := stop.RunConsumer(ctx, s, stop.Consumer[int]{
+		Fetch: func(context.Context) (int, error) {
+			return 0, errFetch
+		},
+		Handle: func(context.Context, int) error {
+			t.Error("unexpected message")
+			return nil
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	SucceedsSoon(t, func() error {
+		if len(s.TaskErrors()) == 0 {
+			return errors.New("no task errors")
+		}
+		return nil
+	})
+	s.Stop(ctx)
+	if err := s.Err(); !errors.Is(err, errFetch) {
+		t.Fatalf("expected %v, got %v", errFetch, err)
+	}
+
+	if err := stop.RunConsumer(ctx, s, stop.Consumer[int]{}); !errors.Is(err, stop.ErrUnavailable) {
+		t.Fatalf("expected %v, got %v", stop.ErrUnavailable, err)
+	}
+}
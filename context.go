@@ -0,0 +1,70 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import "golang.org/x/net/context"
+
+// Ctx returns a background context which is canceled when the stopper begins
+// to quiesce. It can be passed to APIs which take a context to tie them to the
+// lifecycle of the stopper.
+func (s *Stopper) Ctx() context.Context {
+	return s.ctx
+}
+
+type stopperKey struct{}
+
+// WithStopper returns a child context carrying the stopper, which can be
+// retrieved with FromContext. This lets code deep in a call stack, such as an
+// HTTP handler, start tracked tasks without the stopper being passed along
+// explicitly.
+func WithStopper(ctx context.Context, s *Stopper) context.Context {
+	return context.WithValue(ctx, stopperKey{}, s)
+}
+
+// FromContext returns the stopper carried by ctx (see WithStopper), or nil if
+// there is none.
+func FromContext(ctx context.Context) *Stopper {
+	s, _ := ctx.Value(stopperKey{}).(*Stopper)
+	return s
+}
+
+type optionCancelTasksOnQuiesce struct{}
+
+func (optionCancelTasksOnQuiesce) apply(stopper *Stopper) {
+	stopper.cancelOnQuiesce = true
+}
+
+// CancelTasksOnQuiesce is an option which makes the stopper pass each task a
+// context derived with QuiesceContext from the one given to the Run*Task
+// function, so that tasks which honor their context exit promptly once the
+// stopper begins to quiesce, without watching ShouldQuiesce. Tasks run with
+// HighPriority, which may be admitted while quiescing, are exempt.
+func CancelTasksOnQuiesce() Option {
+	return optionCancelTasksOnQuiesce{}
+}
+
+// QuiesceContext returns a child context of parent which is canceled when the
+// stopper begins to quiesce, or immediately if it is already quiescing. Unlike
+// WithCancel, it also returns a cancel function, which releases the resources
+// associated with the context and should be called when the context is no
+// longer needed.
+func (s *Stopper) QuiesceContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	stop := s.AfterQuiesce(cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
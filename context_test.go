@@ -0,0 +1,107 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperCtx(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := s.Ctx()
+
+	select {
+	case <-ctx.Done():
+		t.Fatal("expected context not to be canceled before quiescing")
+	default:
+	}
+
+	s.Stop(context.Background())
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected context to be canceled")
+	}
+}
+
+func TestStopperFromContext(t *testing.T) {
+	s := stop.NewStopper()
+	defer s.Stop(context.Background())
+
+	if fs := stop.FromContext(context.Background()); fs != nil {
+		t.Fatalf("expected no stopper, got %p", fs)
+	}
+
+	ctx := stop.WithStopper(context.Background(), s)
+	if fs := stop.FromContext(ctx); fs != s {
+		t.Fatalf("expected stopper %p, got %p", s, fs)
+	}
+}
+
+func TestStopperQuiesceContext(t *testing.T) {
+	s := stop.NewStopper()
+
+	ctx, cancel := s.QuiesceContext(context.Background())
+	defer cancel()
+
+	s.Stop(context.Background())
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected context to be canceled")
+	}
+
+	// A context created after quiescing is canceled right away.
+	ctx, cancel = s.QuiesceContext(context.Background())
+	defer cancel()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected context to be canceled")
+	}
+}
+
+func TestStopperCancelTasksOnQuiesce(t *testing.T) {
+	s := stop.NewStopper(stop.CancelTasksOnQuiesce())
+	ctx := context.Background()
+
+	exited := make(chan error, 1)
+	if err := s.RunAsyncTask(ctx, func(ctx context.Context) {
+		<-ctx.Done()
+		exited <- ctx.Err()
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var taskCtx context.Context
+	if err := s.RunTask(ctx, func(ctx context.Context) { taskCtx = ctx }); err != nil {
+		t.Fatal(err)
+	}
+
+	// Stop only returns once the task has exited.
+	s.Stop(ctx)
+	if err := <-exited; err != context.Canceled {
+		t.Fatalf("expected the task context to be canceled, got %v", err)
+	}
+	if err := taskCtx.Err(); err != context.Canceled {
+		t.Fatalf("expected the context of a finished task to be released, got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"runtime/debug"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+type dedupedTask struct {
+	done chan struct{} // closed when the task has run
+	err  error
+}
+
+type dedupedTasks struct {
+	sync.Mutex
+	m map[string]*dedupedTask
+}
+
+// RunDedupedTask is like RunTaskWithErr, but collapses concurrent calls with
+// the same key into one execution of f, like golang.org/x/sync/singleflight:
+// a call made while f is running for the key waits for it to finish and
+// returns the same error, instead of running f again. A waiting call returns
+// early with ctx.Err() if ctx is done, or with ErrUnavailable once the stopper
+// begins to quiesce, leaving the execution running. If f panics, waiting calls
+// return a *TaskError wrapping a *PanicError, even if the panic is recovered
+// by a panic handler for the call which ran f.
+//
+// The task is tracked under the key as its name, unless another is given
+// with the TaskName option, and is rejected like other tasks while the
+// stopper is quiescing. Note that f runs with the context of the call which
+// started it.
+func (s *Stopper) RunDedupedTask(
+	ctx context.Context, dedupKey string, f func(context.Context) error, opts ...TaskOption,
+) (err error) {
+	o := makeTaskOptions(append([]TaskOption{TaskName(dedupKey)}, opts...))
+	key := s.makeTaskKey(&o)
+
+	s.deduped.Lock()
+	if t, ok := s.deduped.m[dedupKey]; ok {
+		s.deduped.Unlock()
+		var quiesce <-chan struct{}
+		if o.priority < HighPriority {
+			quiesce = s.ShouldQuiesce()
+		}
+		select {
+		case <-t.done:
+			return t.err
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-quiesce:
+			return newTaskError(key, &o, s.errUnavailable())
+		}
+	}
+	t := &dedupedTask{done: make(chan struct{})}
+	if s.deduped.m == nil {
+		s.deduped.m = map[string]*dedupedTask{}
+	}
+	s.deduped.m[dedupKey] = t
+	s.deduped.Unlock()
+
+	// panicErr is the error waiting calls see if f panics.
+	var panicErr error
+	defer func() {
+		s.deduped.Lock()
+		delete(s.deduped.m, dedupKey)
+		s.deduped.Unlock()
+		t.err = err
+		if t.err == nil {
+			t.err = panicErr
+		}
+		close(t.done)
+	}()
+
+	if err := s.runPrelude(key, &o); err != nil {
+		return newTaskError(key, &o, err)
+	}
+
+	// Call f.
+	defer func() {
+		if r := recover(); r != nil {
+			panicErr = newTaskError(key, &o, &PanicError{r, debug.Stack(), key.String()})
+			s.taskPanicked(ctx, r, key, &o, &err)
+		}
+	}()
+	defer s.runPostlude(key)
+
+	return s.runTaskFuncWithErr(ctx, key, &o, false, f)
+}
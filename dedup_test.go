@@ -0,0 +1,166 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperRunDedupedTask(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+
+	var runs int32
+	release := make(chan struct{})
+	taskErr := errors.New("refresh failed")
+	refresh := func(context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		<-release
+		return taskErr
+	}
+
+	const callers = 5
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errs <- s.RunDedupedTask(ctx, "refresh", refresh)
+	}()
+	SucceedsSoon(t, func() error {
+		if s.RunningTasks()["refresh"] != 1 {
+			return errors.New("task not running")
+		}
+		return nil
+	})
+	for i := 1; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- s.RunDedupedTask(ctx, "refresh", refresh)
+		}()
+	}
+
+	// A waiting call gives up when its context is done.
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := s.RunDedupedTask(canceled, "refresh", refresh); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != taskErr {
+			t.Errorf("expected %v, got %v", taskErr, err)
+		}
+	}
+	if n := atomic.LoadInt32(&runs); n > callers || n < 1 {
+		t.Errorf("unexpected number of runs %d", n)
+	}
+
+	// Once the call has returned, the key runs again.
+	if err := s.RunDedupedTask(ctx, "refresh", func(context.Context) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Stop(ctx)
+	if err := s.RunDedupedTask(ctx, "refresh", refresh); !errors.Is(err, stop.ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable, got %v", err)
+	}
+}
+
+func TestStopperRunDedupedTaskQuiesce(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- s.RunDedupedTask(ctx, "refresh", func(context.Context) error {
+			<-release
+			return nil
+		})
+	}()
+	SucceedsSoon(t, func() error {
+		if s.RunningTasks()["refresh"] != 1 {
+			return errors.New("task not running")
+		}
+		return nil
+	})
+
+	// A waiting call is released once the stopper begins to quiesce, while the
+	// execution keeps running.
+	waiter := make(chan error, 1)
+	go func() {
+		waiter <- s.RunDedupedTask(ctx, "refresh", func(context.Context) error { return nil })
+	}()
+	go s.Quiesce(ctx)
+	if err := <-waiter; !errors.Is(err, stop.ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable, got %v", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	s.Stop(ctx)
+}
+
+func TestStopperRunDedupedTaskPanic(t *testing.T) {
+	s := stop.NewStopper(stop.OnPanic(func(interface{}) {}))
+	defer s.Stop(context.Background())
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- s.RunDedupedTask(ctx, "refresh", func(context.Context) error {
+			<-release
+			panic("boom")
+		})
+	}()
+	SucceedsSoon(t, func() error {
+		if s.RunningTasks()["refresh"] != 1 {
+			return errors.New("task not running")
+		}
+		return nil
+	})
+	waiter := make(chan error, 1)
+	go func() {
+		waiter <- s.RunDedupedTask(ctx, "refresh", func(context.Context) error { return nil })
+	}()
+	// Give the waiter time to join the running execution.
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	// The panic handler recovered the panic for the call which ran the task,
+	// but the waiting call still sees the failure.
+	if err := <-done; err != nil {
+		t.Errorf("expected nil error from the running call, got %v", err)
+	}
+	var pe *stop.PanicError
+	if err := <-waiter; !errors.As(err, &pe) || pe.Value != "boom" {
+		t.Errorf("expected a PanicError, got %v", err)
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// DefaultDrainHookTimeout is the time Stop waits for the drain hooks (see
+// OnDrain), unless changed with the DrainHookTimeout option.
+const DefaultDrainHookTimeout = 10 * time.Second
+
+type optionDrainDelay time.Duration
+
+func (odd optionDrainDelay) apply(stopper *Stopper) {
+	stopper.drainDelay = time.Duration(odd)
+}
+
+// WithDrainDelay is an option which makes Stop wait for d after it begins to
+// drain, and before it begins to quiesce. While draining, ShouldDrain is
+// closed and the ReadyHandler reports the stopper as not ready, but tasks are
+// still admitted and no work is canceled. This gives load balancers time to
+// stop sending traffic to a process which is shutting down.
+func WithDrainDelay(d time.Duration) Option {
+	return optionDrainDelay(d)
+}
+
+type optionDrainHookTimeout time.Duration
+
+func (odht optionDrainHookTimeout) apply(stopper *Stopper) {
+	stopper.drainTimeout = time.Duration(odht)
+}
+
+// DrainHookTimeout is an option which sets the deadline of the context passed
+// to the drain hooks (see OnDrain), after which Stop proceeds to quiesce
+// without waiting for them any longer.
+func DrainHookTimeout(d time.Duration) Option {
+	return optionDrainHookTimeout(d)
+}
+
+// OnDrain registers a hook which Stop runs when it begins to drain, before
+// the stopper begins to quiesce. It is meant for deregistering the process
+// from service discovery, such as Consul, etcd or DNS, so that no new traffic
+// arrives while tasks are being drained.
+//
+// The hooks run concurrently, and concurrently with the drain delay (see
+// WithDrainDelay). Stop waits for all of them to return, but at most until
+// the context passed to them expires (see DrainHookTimeout). Errors returned
+// by the hooks are logged. Hooks registered once the stopper is draining are
+// not run.
+func (s *Stopper) OnDrain(hook func(context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mu.drainHooks = append(s.mu.drainHooks, hook)
+}
+
+// ShouldDrain returns a channel which is closed when Stop is called, before
+// the drain delay (see WithDrainDelay), or at the latest when the stopper
+// begins to quiesce. It signals that the process should stop advertising
+// itself, for instance by failing readiness checks, while it still serves
+// traffic. Unlike Drain, it does not affect task admission.
+func (s *Stopper) ShouldDrain() <-chan struct{} {
+	if s == nil {
+		// A nil stopper will never signal ShouldDrain, but will also never panic.
+		return nil
+	}
+	return s.drainer
+}
+
+// ReadyHandler returns an http.Handler for readiness checks. It responds with
+// 200 OK while the stopper is running or paused, and with 503 Service
+// Unavailable once it has begun to drain.
+func (s *Stopper) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-s.ShouldDrain():
+			http.Error(w, "draining", http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte("ok\n"))
+		}
+	})
+}
+
+// OnServingChange drives a health check, such as the gRPC health service,
+// from the phases of the stopper. It calls set with true right away, unless
+// the stopper is already draining, and with false as soon as it begins to
+// drain (see ShouldDrain). For instance, with a grpc/health.Server:
+//
+//	hs := health.NewServer()
+//	s.OnServingChange(func(serving bool) {
+//		status := healthpb.HealthCheckResponse_NOT_SERVING
+//		if serving {
+//			status = healthpb.HealthCheckResponse_SERVING
+//		}
+//		hs.SetServingStatus("", status)
+//	})
+//
+// Combined with WithDrainDelay, clients checking the health of the server
+// stop sending it requests before it begins to reject them.
+func (s *Stopper) OnServingChange(set func(serving bool)) {
+	s.mu.Lock()
+	draining := s.mu.draining
+	s.mu.Unlock()
+	if !draining {
+		set(true)
+	}
+	s.AfterDrain(func() { set(false) })
+}
+
+// drain closes ShouldDrain, runs the drain hooks and waits for the drain
+// delay, unless the stopper is already draining.
+func (s *Stopper) drain() {
+	s.mu.Lock()
+	draining := s.mu.draining
+	s.startDrainingLocked()
+	hooks := s.mu.drainHooks
+	s.mu.Unlock()
+	if draining {
+		return
+	}
+
+	hooksDone := make(chan struct{})
+	// The deadline lets hooks pass the context on to clients which honor
+	// deadlines. It is measured by the stopper's clock, which also decides
+	// when Stop stops waiting below.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hookCtx := &clockDeadlineContext{Context: ctx, deadline: s.clock.Now().Add(s.drainTimeout)}
+	go func() {
+		var wg sync.WaitGroup
+		wg.Add(len(hooks))
+		for _, hook := range hooks {
+			go func(hook func(context.Context) error) {
+				defer wg.Done()
+				if err := hook(hookCtx); err != nil {
+					s.logger.Printf("drain hook failed: %v", err)
+				}
+			}(hook)
+		}
+		wg.Wait()
+		close(hooksDone)
+	}()
+
+	var delay <-chan time.Time
+	if s.drainDelay > 0 {
+		delay = s.clock.After(s.drainDelay)
+	}
+	var expired <-chan time.Time
+	if len(hooks) > 0 {
+		expired = s.clock.After(s.drainTimeout)
+	}
+	waiting := (<-chan struct{})(hooksDone)
+	for waiting != nil || delay != nil {
+		select {
+		case <-waiting:
+			waiting = nil
+		case <-delay:
+			delay = nil
+		case <-expired:
+			if waiting != nil {
+				s.logger.Printf("drain hooks did not return within %s", s.drainTimeout)
+				hookCtx.expired.Store(true)
+				cancel()
+				waiting = nil
+			}
+		}
+	}
+	s.mu.Lock()
+	closePhase(s.phases.drained)
+	s.mu.Unlock()
+}
+
+// clockDeadlineContext is a context whose deadline is measured by the
+// stopper's clock rather than by the wall clock, so that a fake clock (see
+// WithClock) decides when it expires. The embedded context is canceled once
+// the deadline has passed, after expired is set.
+type clockDeadlineContext struct {
+	context.Context
+	deadline time.Time
+	expired  atomic.Bool
+}
+
+func (c *clockDeadlineContext) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+func (c *clockDeadlineContext) Err() error {
+	err := c.Context.Err()
+	if err != nil && c.expired.Load() {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+func (s *Stopper) startDrainingLocked() {
+	if !s.mu.draining {
+		s.mu.draining = true
+		s.mu.since = s.clock.Now()
+		close(s.drainer)
+		s.fireLocked(&s.mu.afterDrain)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+	"github.com/birkelund/stop/stoptest"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperWithDrainDelay(t *testing.T) {
+	c := stoptest.NewFakeClock(time.Unix(0, 0))
+	s := stop.NewStopper(stop.WithClock(c), stop.WithDrainDelay(5*time.Second))
+	ctx := context.Background()
+
+	ready := func() int {
+		w := httptest.NewRecorder()
+		s.ReadyHandler().ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+		return w.Code
+	}
+	if code := ready(); code != http.StatusOK {
+		t.Fatalf("expected ready before Stop, got %d", code)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop(ctx)
+		close(stopped)
+	}()
+
+	<-s.ShouldDrain()
+	SucceedsSoon(t, func() error {
+		if c.Waiters() == 0 {
+			return errors.New("drain delay not started")
+		}
+		return nil
+	})
+	if code := ready(); code != http.StatusServiceUnavailable {
+		t.Errorf("expected not ready while draining, got %d", code)
+	}
+	if state := s.Status().State; state != "draining" {
+		t.Errorf("expected state draining, got %s", state)
+	}
+	if err := s.RunTask(ctx, func(context.Context) {}); err != nil {
+		t.Errorf("expected tasks to be admitted while draining, got %v", err)
+	}
+	select {
+	case <-s.ShouldQuiesce():
+		t.Fatal("quiescing before the drain delay expired")
+	default:
+	}
+
+	c.Advance(5 * time.Second)
+	<-stopped
+	if d := s.StopReport().Drain; d != 5*time.Second {
+		t.Errorf("expected drain to take 5s, got %s", d)
+	}
+}
+
+func TestStopperQuiesceDrains(t *testing.T) {
+	s := stop.NewStopper()
+	s.Quiesce(context.Background())
+	select {
+	case <-s.ShouldDrain():
+	default:
+		t.Fatal("expected Quiesce to close ShouldDrain")
+	}
+	s.Stop(context.Background())
+}
+
+func TestStopperOnServingChange(t *testing.T) {
+	s := stop.NewStopper()
+	serving := make(chan bool, 2)
+	s.OnServingChange(func(b bool) { serving <- b })
+	if b := <-serving; !b {
+		t.Fatal("expected serving while running")
+	}
+
+	s.Stop(context.Background())
+	if b := <-serving; b {
+		t.Fatal("expected not serving once drained")
+	}
+
+	// Once draining, the status is only set to not serving.
+	s.OnServingChange(func(b bool) { serving <- b })
+	if b := <-serving; b {
+		t.Fatal("expected not serving after Stop")
+	}
+}
+
+func TestStopperOnDrain(t *testing.T) {
+	s := stop.NewStopper(stop.DrainHookTimeout(10 * time.Millisecond))
+	ctx := context.Background()
+
+	deregistered := make(chan struct{})
+	s.OnDrain(func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("expected the context of a drain hook to have a deadline")
+		}
+		select {
+		case <-s.ShouldQuiesce():
+			t.Error("drain hook ran after quiescing began")
+		default:
+		}
+		close(deregistered)
+		return nil
+	})
+	expired := make(chan error, 1)
+	s.OnDrain(func(ctx context.Context) error {
+		<-ctx.Done()
+		expired <- ctx.Err()
+		return ctx.Err()
+	})
+
+	s.Stop(ctx)
+	select {
+	case <-deregistered:
+	default:
+		t.Error("expected drain hook to run")
+	}
+	if err := <-expired; err == nil {
+		t.Error("expected the context of a slow hook to expire")
+	}
+}
+
+func TestStopperOnDrainClock(t *testing.T) {
+	start := time.Unix(0, 0)
+	c := stoptest.NewFakeClock(start)
+	s := stop.NewStopper(stop.WithClock(c), stop.DrainHookTimeout(time.Minute))
+	ctx := context.Background()
+
+	expired := make(chan error, 1)
+	s.OnDrain(func(ctx context.Context) error {
+		if deadline, ok := ctx.Deadline(); !ok || !deadline.Equal(start.Add(time.Minute)) {
+			t.Errorf("expected a deadline a minute after %s by the stopper's clock, got %s", start, deadline)
+		}
+		<-ctx.Done()
+		expired <- ctx.Err()
+		return nil
+	})
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop(ctx)
+		close(stopped)
+	}()
+	SucceedsSoon(t, func() error {
+		if c.Waiters() == 0 {
+			return errors.New("drain hook timeout not started")
+		}
+		return nil
+	})
+
+	// The hook's context expires when the stopper's clock passes the
+	// deadline, not the wall clock.
+	c.Advance(59 * time.Second)
+	select {
+	case err := <-expired:
+		t.Fatalf("drain hook context expired early: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	c.Advance(time.Second)
+	if err := <-expired; err != context.DeadlineExceeded {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	<-stopped
+}
@@ -0,0 +1,60 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import "golang.org/x/net/context"
+
+// A Drainable is a resource, such as a pool of Redis or gRPC client
+// connections, which stops taking new work and finishes the work in flight
+// when drained, before it is closed.
+type Drainable interface {
+	Drain(ctx context.Context) error
+	Close() error
+}
+
+// AddDrainable adds d to be drained once the stopper begins to quiesce and
+// closed with the closers, like an io.Closer added with AddIOCloser. The
+// context passed to Drain is canceled once the workers have exited (see
+// WorkersDone), and Close is called only after Drain has returned. An error
+// returned by Drain is logged.
+func (s *Stopper) AddDrainable(d Drainable) {
+	drained := make(chan struct{})
+	s.AfterQuiesce(func() {
+		defer close(drained)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			select {
+			case <-s.WorkersDone():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		if err := d.Drain(ctx); err != nil {
+			s.logger.Printf("drain of %T failed: %v", d, err)
+		}
+	})
+	s.AddIOCloser(drainableCloser{d, drained})
+}
+
+type drainableCloser struct {
+	d       Drainable
+	drained chan struct{}
+}
+
+func (dc drainableCloser) Close() error {
+	<-dc.drained
+	return dc.d.Close()
+}
@@ -0,0 +1,88 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/birkelund/stop"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+// testPool is a Drainable recording the calls made to it.
+type testPool struct {
+	s *stop.Stopper
+
+	mu    sync.Mutex
+	calls []string
+
+	// block makes Drain wait for its context to be done.
+	block bool
+}
+
+func (p *testPool) record(call string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = append(p.calls, call)
+}
+
+func (p *testPool) Drain(ctx context.Context) error {
+	select {
+	case <-p.s.ShouldQuiesce():
+	default:
+		p.record("drain before quiesce")
+	}
+	if p.block {
+		<-ctx.Done()
+		p.record("drain canceled")
+		return ctx.Err()
+	}
+	p.record("drain")
+	return nil
+}
+
+func (p *testPool) Close() error {
+	p.record("close")
+	return errors.New("close failed")
+}
+
+func TestAddDrainable(t *testing.T) {
+	for _, block := range []bool{false, true} {
+		s := stop.NewStopper()
+		p := &testPool{s: s, block: block}
+		s.AddDrainable(p)
+		s.Stop(context.Background())
+
+		want := []string{"drain", "close"}
+		if block {
+			want[0] = "drain canceled"
+		}
+		if len(p.calls) != len(want) || p.calls[0] != want[0] || p.calls[1] != want[1] {
+			t.Fatalf("expected %v, got %v", want, p.calls)
+		}
+
+		errs := s.CloserErrors()
+		if len(errs) != 1 {
+			t.Fatalf("expected 1 closer error, got %v", errs)
+		}
+		var ce *stop.CloserError
+		if !errors.As(errs[0], &ce) || ce.Closer != "*stop_test.testPool" {
+			t.Fatalf("unexpected closer error %v", errs[0])
+		}
+	}
+}
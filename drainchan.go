@@ -0,0 +1,87 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import "sync/atomic"
+
+// A ChannelDrain drains a channel once the stopper begins to quiesce (see
+// DrainOnQuiesce).
+type ChannelDrain struct {
+	n    atomic.Int64
+	done chan struct{}
+}
+
+// DrainOnQuiesce arranges for ch to be drained once the stopper begins to
+// quiesce, so that tasks and workers blocked sending on ch, after its
+// consumer has exited, don't hold up Stop. Each value received is passed to
+// sink or, if sink is nil, dropped, and the number of dropped values is
+// logged. Draining ends once ch is closed or, after taking the values left in
+// its buffer, once the workers of the stopper have exited (see WorkersDone).
+//
+// sink is called from a single goroutine, and must not block on the
+// stopper.
+func DrainOnQuiesce[T any](s *Stopper, ch <-chan T, sink func(T)) *ChannelDrain {
+	d := &ChannelDrain{done: make(chan struct{})}
+	s.AfterQuiesce(func() {
+		defer close(d.done)
+		if sink == nil {
+			defer func() {
+				if n := d.n.Load(); n > 0 {
+					s.logger.Printf("dropped %d values on quiesce", n)
+				}
+			}()
+		}
+		receive := func(v T) {
+			d.n.Add(1)
+			if sink != nil {
+				sink(v)
+			}
+		}
+		for {
+			select {
+			case v, ok := <-ch:
+				if !ok {
+					return
+				}
+				receive(v)
+			case <-s.WorkersDone():
+				// Take the values left in the buffer of ch.
+				for {
+					select {
+					case v, ok := <-ch:
+						if !ok {
+							return
+						}
+						receive(v)
+					default:
+						return
+					}
+				}
+			}
+		}
+	})
+	return d
+}
+
+// Count returns the number of values drained so far, which are the values
+// dropped if there is no sink.
+func (d *ChannelDrain) Count() int {
+	return int(d.n.Load())
+}
+
+// Done returns a channel which is closed once draining has ended.
+func (d *ChannelDrain) Done() <-chan struct{} {
+	return d.done
+}
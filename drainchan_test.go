@@ -0,0 +1,89 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"testing"
+
+	"github.com/birkelund/stop"
+
+	"golang.org/x/net/context"
+)
+
+func TestDrainOnQuiesce(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+
+	// The producer blocks on the full channel, as there is no consumer.
+	ch := make(chan int, 1)
+	if err := s.RunAsyncTask(ctx, func(context.Context) {
+		for i := 0; i < 10; i++ {
+			ch <- i
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []int
+	d := stop.DrainOnQuiesce(s, ch, func(v int) {
+		got = append(got, v)
+	})
+	s.Stop(ctx)
+	<-d.Done()
+
+	if len(got) != 10 || d.Count() != 10 {
+		t.Fatalf("expected 10 values, got %v (count %d)", got, d.Count())
+	}
+	for i, v := range got {
+		if v != i {
+			t.Fatalf("expected %d, got %d", i, v)
+		}
+	}
+}
+
+func TestDrainOnQuiesceDrop(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+
+	ch := make(chan string)
+	if err := s.RunWorker(ctx, func(context.Context) {
+		defer close(ch)
+		for i := 0; i < 3; i++ {
+			ch <- "x"
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	d := stop.DrainOnQuiesce(s, ch, nil)
+	s.Stop(ctx)
+	<-d.Done()
+	if n := d.Count(); n != 3 {
+		t.Fatalf("expected 3 dropped values, got %d", n)
+	}
+}
+
+func TestDrainOnQuiesceUnclosed(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+
+	// Draining a channel which is never closed ends once the workers exit.
+	d := stop.DrainOnQuiesce(s, make(chan int), nil)
+	s.Stop(ctx)
+	<-d.Done()
+	if n := d.Count(); n != 0 {
+		t.Fatalf("expected no values, got %d", n)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// The environment variables read by ConfigFromEnv, each holding a duration
+// as accepted by time.ParseDuration, such as "30s".
+const (
+	EnvBackgroundGracePeriod = "STOP_BACKGROUND_GRACE_PERIOD" // see BackgroundGracePeriod
+	EnvDrainDelay            = "STOP_DRAIN_DELAY"             // see WithDrainDelay
+	EnvDrainHookTimeout      = "STOP_DRAIN_HOOK_TIMEOUT"      // see DrainHookTimeout
+	EnvCloserTimeout         = "STOP_CLOSER_TIMEOUT"          // see CloserTimeout
+	EnvFlushTimeout          = "STOP_FLUSH_TIMEOUT"           // see FlushTimeout
+	EnvShutdownWarnInterval  = "STOP_SHUTDOWN_WARN_INTERVAL"  // see ShutdownWarnInterval
+	EnvShutdownTimeout       = "STOP_SHUTDOWN_TIMEOUT"        // see ShutdownTimeout
+)
+
+type optionConfigFromEnv struct{}
+
+func (optionConfigFromEnv) apply(stopper *Stopper) {
+	for _, v := range []struct {
+		env string
+		d   *time.Duration
+	}{
+		{EnvBackgroundGracePeriod, &stopper.backgroundGrace},
+		{EnvDrainDelay, &stopper.drainDelay},
+		{EnvDrainHookTimeout, &stopper.drainTimeout},
+		{EnvCloserTimeout, &stopper.closerTimeout},
+		{EnvFlushTimeout, &stopper.flushTimeout},
+		{EnvShutdownWarnInterval, &stopper.shutdownWarn},
+		{EnvShutdownTimeout, &stopper.shutdownTimeout},
+	} {
+		s, ok := os.LookupEnv(v.env)
+		if !ok || s == "" {
+			continue
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			if stopper.envErr == nil {
+				stopper.envErr = errors.Wrapf(err, "stop: ConfigFromEnv: %s", v.env)
+			}
+			continue
+		}
+		*v.d = d
+	}
+}
+
+// ConfigFromEnv is an option which reads the shutdown timing of the stopper
+// from the environment variables above, so that operators can tune it without
+// recompiling. Variables which are unset or empty leave the timing as it is.
+// Options are applied in order, so ConfigFromEnv should be passed last to let
+// the environment override the options given in code. An invalid value makes
+// New return an error.
+func ConfigFromEnv() Option {
+	return optionConfigFromEnv{}
+}
@@ -0,0 +1,58 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+
+	"golang.org/x/net/context"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv(stop.EnvDrainHookTimeout, "2s")
+	t.Setenv(stop.EnvShutdownTimeout, "5m")
+	t.Setenv(stop.EnvCloserTimeout, "")
+
+	s := stop.NewStopper(
+		stop.DrainHookTimeout(time.Second),
+		stop.CloserTimeout(time.Second),
+		stop.ConfigFromEnv(),
+	)
+	defer s.Stop(context.Background())
+
+	cfg := s.Config()
+	if cfg.DrainHookTimeout != 2*time.Second || cfg.ShutdownTimeout != 5*time.Minute {
+		t.Errorf("expected timing from environment in config %+v", cfg)
+	}
+	if cfg.CloserTimeout != time.Second || cfg.ShutdownWarnInterval != stop.DefaultShutdownWarnInterval {
+		t.Errorf("expected unset variables to leave timing in config %+v", cfg)
+	}
+}
+
+func TestConfigFromEnvInvalid(t *testing.T) {
+	t.Setenv(stop.EnvBackgroundGracePeriod, "soon")
+
+	s, err := stop.New(stop.ConfigFromEnv())
+	if err == nil || !strings.Contains(err.Error(), stop.EnvBackgroundGracePeriod) {
+		t.Fatalf("expected error mentioning %s, got %v", stop.EnvBackgroundGracePeriod, err)
+	}
+	if s != nil {
+		t.Errorf("expected no stopper, got %s", s)
+	}
+}
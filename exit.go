@@ -0,0 +1,76 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"os"
+	"sync"
+)
+
+// exitStatus holds the status recorded with SetExitStatus. It has its own
+// lock, so that closers and flushers can record a failed shutdown while Stop
+// holds the stopper lock.
+type exitStatus struct {
+	sync.Mutex
+	code int
+	err  error
+}
+
+// SetExitStatus records the exit status with which the process should exit
+// once the stopper has stopped (see ExitStatus), along with the error which
+// caused it, so that subsystems can report fatal conditions without calling
+// os.Exit themselves. The first status with a non-zero code is kept; later
+// ones are logged and otherwise ignored. It may be called at any time,
+// including from a closer or flusher run by Stop.
+func (s *Stopper) SetExitStatus(code int, err error) {
+	if code == 0 {
+		return
+	}
+	s.exit.Lock()
+	defer s.exit.Unlock()
+	if s.exit.code != 0 {
+		s.logger.Printf("ignoring exit status %d (%v), already exiting with %d", code, err, s.exit.code)
+		return
+	}
+	s.exit.code, s.exit.err = code, err
+}
+
+// ExitStatus returns the exit code and error recorded with SetExitStatus. If
+// none was recorded, but the stopper was stopped with a reason (see
+// StopWithReason), it returns 1 and the reason. Otherwise it returns 0 and
+// nil.
+func (s *Stopper) ExitStatus() (code int, err error) {
+	s.exit.Lock()
+	code, err = s.exit.code, s.exit.err
+	s.exit.Unlock()
+	if code != 0 {
+		return code, err
+	}
+	if reason := s.StopReason(); reason != nil {
+		return 1, reason
+	}
+	return 0, nil
+}
+
+// Exit logs the error, if any, and terminates the process with the code
+// returned by ExitStatus. It is meant to be called at the end of main, after
+// Stop has returned.
+func (s *Stopper) Exit() {
+	code, err := s.ExitStatus()
+	if err != nil {
+		s.logger.Printf("exiting with status %d: %v", code, err)
+	}
+	os.Exit(code)
+}
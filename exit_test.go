@@ -0,0 +1,76 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperExitStatus(t *testing.T) {
+	s := stop.NewStopper()
+	if code, err := s.ExitStatus(); code != 0 || err != nil {
+		t.Errorf("expected 0, nil; got %d, %v", code, err)
+	}
+
+	errDisk := errors.New("disk full")
+	s.SetExitStatus(0, errors.New("ignored"))
+	s.SetExitStatus(3, errDisk)
+	s.SetExitStatus(4, errors.New("later"))
+	s.StopWithReason(context.Background(), errors.New("reason"))
+	if code, err := s.ExitStatus(); code != 3 || err != errDisk {
+		t.Errorf("expected 3, %v; got %d, %v", errDisk, code, err)
+	}
+}
+
+func TestStopperExitStatusFromStopReason(t *testing.T) {
+	s := stop.NewStopper()
+	reason := errors.New("reason")
+	s.StopWithReason(context.Background(), reason)
+	if code, err := s.ExitStatus(); code != 1 || err != reason {
+		t.Errorf("expected 1, %v; got %d, %v", reason, code, err)
+	}
+}
+
+func TestStopperExitStatusFromCloser(t *testing.T) {
+	s := stop.NewStopper()
+	errFlush := errors.New("flush failed")
+	s.AddFlusher(flusherFunc(func() error {
+		s.SetExitStatus(2, errFlush)
+		return nil
+	}))
+	s.AddCloser(stop.CloserFn(func() {
+		s.SetExitStatus(3, errors.New("close failed"))
+	}))
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		s.Stop(context.Background())
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Stop deadlocked")
+	}
+	if code, err := s.ExitStatus(); code != 2 || err != errFlush {
+		t.Errorf("expected 2, %v; got %d, %v", errFlush, code, err)
+	}
+}
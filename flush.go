@@ -0,0 +1,98 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultFlushTimeout is the time Stop waits for the flushers (see
+// AddFlusher), unless changed with the FlushTimeout option.
+const DefaultFlushTimeout = 5 * time.Second
+
+// A Flusher writes out buffered data. It is implemented by *bufio.Writer,
+// among others.
+type Flusher interface {
+	Flush() error
+}
+
+type optionFlushTimeout time.Duration
+
+func (oft optionFlushTimeout) apply(stopper *Stopper) {
+	stopper.flushTimeout = time.Duration(oft)
+}
+
+// FlushTimeout is an option which bounds the time Stop waits for the
+// flushers (see AddFlusher). Flushers which have not returned when the
+// timeout expires are left running, and Stop carries on with the closers. A
+// zero timeout makes Stop wait for the flushers without bound.
+func FlushTimeout(d time.Duration) Option {
+	return optionFlushTimeout(d)
+}
+
+// AddFlusher adds a flusher, such as the buffered writer of a log file or a
+// metrics exporter, which Stop calls once the workers have exited, before
+// any of the closers, so that buffered data is written out while the files
+// and connections it goes to are still open. The flushers run concurrently,
+// bounded by the flush timeout (see FlushTimeout). An error returned by a
+// flusher is recorded as a CloserError (see CloserErrors) and logged.
+func (s *Stopper) AddFlusher(f Flusher) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mu.flushers = append(s.mu.flushers, f)
+}
+
+// flush runs the flushers and waits for them, at most until the flush timeout
+// expires. The flushers run without the stopper lock held, so they may call
+// methods of the stopper, such as Status or SetExitStatus.
+func (s *Stopper) flush() {
+	s.mu.Lock()
+	flushers := append([]Flusher(nil), s.mu.flushers...)
+	s.mu.Unlock()
+	if len(flushers) == 0 {
+		return
+	}
+	type result struct {
+		f   Flusher
+		err error
+	}
+	results := make(chan result, len(flushers))
+	for _, f := range flushers {
+		go func(f Flusher) {
+			results <- result{f, f.Flush()}
+		}(f)
+	}
+
+	var expired <-chan time.Time
+	if s.flushTimeout > 0 {
+		expired = s.clock.After(s.flushTimeout)
+	}
+	for n := len(flushers); n > 0; n-- {
+		select {
+		case r := <-results:
+			if r.err != nil {
+				err := &CloserError{Closer: fmt.Sprintf("%T", r.f), Err: r.err}
+				s.logger.Printf("%v", err)
+				s.mu.Lock()
+				s.mu.closeErrors = append(s.mu.closeErrors, err)
+				s.mu.Unlock()
+			}
+		case <-expired:
+			s.logger.Printf("%d flushers did not return within %s", n, s.flushTimeout)
+			return
+		}
+	}
+}
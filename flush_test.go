@@ -0,0 +1,100 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+// closeRecorder is a buffer which records its contents when closed.
+type closeRecorder struct {
+	bytes.Buffer
+	closed string
+}
+
+func (cr *closeRecorder) Close() error {
+	cr.closed = cr.String()
+	return nil
+}
+
+type flusherFunc func() error
+
+func (f flusherFunc) Flush() error {
+	return f()
+}
+
+func TestAddFlusher(t *testing.T) {
+	s := stop.NewStopper()
+
+	// The file is added before its buffered writer, but is closed after it
+	// has been flushed.
+	var file closeRecorder
+	s.AddIOCloser(&file)
+	w := bufio.NewWriter(&file)
+	s.AddFlusher(w)
+	if _, err := w.WriteString("last words"); err != nil {
+		t.Fatal(err)
+	}
+
+	errFlush := errors.New("flush failed")
+	s.AddFlusher(flusherFunc(func() error { return errFlush }))
+
+	// Flushers may call into the stopper.
+	var state string
+	s.AddFlusher(flusherFunc(func() error {
+		state = s.Status().State
+		return nil
+	}))
+
+	s.Stop(context.Background())
+	if file.closed != "last words" {
+		t.Fatalf("expected flushed data before close, got %q", file.closed)
+	}
+	errs := s.CloserErrors()
+	if len(errs) != 1 || !errors.Is(errs[0], errFlush) {
+		t.Fatalf("expected %v, got %v", errFlush, errs)
+	}
+	if state != "stopping" {
+		t.Fatalf("expected the flusher to see the stopper stopping, got %q", state)
+	}
+}
+
+func TestFlushTimeout(t *testing.T) {
+	s := stop.NewStopper(stop.FlushTimeout(10 * time.Millisecond))
+	release := make(chan struct{})
+	defer close(release)
+	s.AddFlusher(flusherFunc(func() error {
+		<-release
+		return nil
+	}))
+	closed := false
+	s.AddCloser(stop.CloserFn(func() { closed = true }))
+
+	s.Stop(context.Background())
+	if !closed {
+		t.Fatal("expected closers to run after the flush timeout")
+	}
+	if d := s.StopReport().Flush; d < 10*time.Millisecond {
+		t.Fatalf("expected flush to take at least the timeout, took %s", d)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+type optionCancelTasksOnForce struct{}
+
+func (optionCancelTasksOnForce) apply(*Stopper) {}
+
+// CancelTasksOnForce is an option kept for compatibility. Every task is
+// passed a context which is canceled once the stopper is forced to stop (see
+// StopWithGrace and ForceCancel), so the option has no effect. Unlike
+// CancelTasksOnQuiesce, forcing leaves tasks to finish undisturbed while the
+// stopper quiesces politely.
+func CancelTasksOnForce() Option {
+	return optionCancelTasksOnForce{}
+}
+
+// forceState cancels the contexts of the tasks once the stopper is forced to
+// stop. It has a lock of its own, rather than using the stopper's, as every
+// task run with a cancelable context registers with it.
+type forceState struct {
+	sync.Mutex
+	ctx    context.Context    // canceled once forced, set before tasks run
+	cancel context.CancelFunc // cancels ctx
+	fns    afterFuncs         // functions registered by ForceContext()
+}
+
+// reset makes the state ready for a stopper which has not been forced.
+func (fs *forceState) reset() {
+	fs.Lock()
+	defer fs.Unlock()
+	if fs.cancel != nil {
+		fs.cancel()
+	}
+	fs.ctx, fs.cancel = context.WithCancel(context.Background())
+	fs.fns = afterFuncs{}
+}
+
+// ForceContext returns a child context of parent which is canceled when the
+// stopper is forced to stop (see StopWithGrace), or immediately if it already
+// has been. Like QuiesceContext, it also returns a cancel function, which
+// should be called when the context is no longer needed.
+func (s *Stopper) ForceContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	stop := s.forced.fns.add(&s.forced, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// taskContext returns the context passed to a task run with ctx, which is
+// canceled once the stopper is forced to stop, and the function releasing it,
+// if any. A task run with a background context is passed the stopper's own
+// force context, which is just as empty, so that running it doesn't allocate.
+func (s *Stopper) taskContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == context.Background() || ctx == context.TODO() {
+		return s.forced.ctx, nil
+	}
+	return s.ForceContext(ctx)
+}
+
+// An AbandonedError is returned by StopWithGrace when tasks were still running
+// after the grace period.
+type AbandonedError struct {
+	// Grace is the grace period given to StopWithGrace.
+	Grace time.Duration
+	// Tasks are the tasks which were running when the grace period expired.
+	Tasks TaskMap
+}
+
+func (e *AbandonedError) Error() string {
+	n := 0
+	for _, num := range e.Tasks {
+		n += num
+	}
+	return fmt.Sprintf("abandoned %d tasks after grace period of %s:\n%s", n, e.Grace, e.Tasks)
+}
+
+// StopWithGrace is like Stop, but gives up waiting once the grace period has
+// expired, so that a process never hangs on a misbehaving task. It first
+// quiesces politely. If the stopper has not stopped within the grace period,
+// it cancels the contexts of all tasks still running and returns an
+// *AbandonedError listing them, leaving Stop to complete in the background
+// once they exit. See BeginStop for controlling the phases separately.
+func (s *Stopper) StopWithGrace(ctx context.Context, grace time.Duration) error {
+	sc := s.beginStop(ctx, 1)
+	if sc.WaitGraceful(grace) {
+		return nil
+	}
+	err := &AbandonedError{Grace: grace, Tasks: sc.ForceCancel()}
+	s.logger.Printf("%v", err)
+	return err
+}
+
+// A StopController controls a stop begun with BeginStop.
+type StopController struct {
+	s       *Stopper
+	stopped <-chan struct{} // closed when the stopper has stopped
+}
+
+// BeginStop begins to stop the stopper, calling Stop in the background, and
+// returns a controller for the phases of the stop. An orchestrator can thus
+// map its own shutdown budget, such as the termination grace period of a
+// Kubernetes pod, onto the stopper: wait for a graceful stop for most of the
+// budget with WaitGraceful, then cancel the remaining tasks with ForceCancel
+// and wait for them with the rest.
+func (s *Stopper) BeginStop(ctx context.Context) *StopController {
+	return s.beginStop(ctx, 1)
+}
+
+// beginStop implements BeginStop, logging the caller at the given depth, as
+// in caller.Lookup, from the caller of beginStop as the stop site.
+func (s *Stopper) beginStop(ctx context.Context, depth int) *StopController {
+	if s.nop {
+		// Stop is a no-op, so there is nothing to wait for.
+		stopped := make(chan struct{})
+		close(stopped)
+		return &StopController{s: s, stopped: stopped}
+	}
+	return &StopController{s: s, stopped: s.stopAsync(ctx, nil, depth+1)}
+}
+
+// Done returns a channel which is closed once the stopper has stopped.
+func (sc *StopController) Done() <-chan struct{} {
+	return sc.stopped
+}
+
+// WaitGraceful waits for up to d for the stopper to stop, and reports whether
+// it did.
+func (sc *StopController) WaitGraceful(d time.Duration) bool {
+	select {
+	case <-sc.stopped:
+		return true
+	case <-sc.s.clock.After(d):
+		return false
+	}
+}
+
+// ForceCancel cancels the contexts of all running tasks, and those derived
+// with ForceContext, and returns the tasks which were still running. It does
+// not wait for them to exit; use Done or WaitGraceful to do so.
+func (sc *StopController) ForceCancel() TaskMap {
+	tasks := sc.s.RunningTasks()
+	sc.s.force()
+	return tasks
+}
+
+// force cancels the contexts of the tasks and those returned by ForceContext.
+func (s *Stopper) force() {
+	s.forced.Lock()
+	defer s.forced.Unlock()
+	if !s.forced.fns.fired {
+		s.forced.cancel()
+		s.fireLocked(&s.forced.fns)
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/birkelund/caller"
+	"github.com/birkelund/stop"
+	"github.com/birkelund/stop/stoptest"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperStopWithGrace(t *testing.T) {
+	c := stoptest.NewFakeClock(time.Unix(0, 0))
+	s := stop.NewStopper(stop.WithClock(c), stop.CancelTasksOnForce())
+	ctx := context.Background()
+
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	if err := s.RunAsyncTask(ctx, func(ctx context.Context) {
+		close(started)
+		// Ignore quiescing; only exit when forced to.
+		<-s.ShouldQuiesce()
+		<-ctx.Done()
+		close(canceled)
+	}, stop.TaskName("stubborn")); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.StopWithGrace(ctx, time.Minute)
+	}()
+	SucceedsSoon(t, func() error {
+		if c.Waiters() == 0 {
+			return errors.New("waiting for grace period timer")
+		}
+		return nil
+	})
+	select {
+	case <-canceled:
+		t.Fatal("task canceled before grace period expired")
+	default:
+	}
+
+	c.Advance(time.Minute)
+	err := <-errCh
+	var ae *stop.AbandonedError
+	if !errors.As(err, &ae) || ae.Tasks["stubborn"] != 1 {
+		t.Fatalf("expected stubborn task to be abandoned, got %v", err)
+	}
+	<-canceled
+	<-s.IsStopped()
+}
+
+// TestStopperStopWithGraceDefault verifies that the contexts of all tasks
+// are canceled once the grace period has expired, including on a stopper
+// created without options and for tasks run with a context of their own.
+func TestStopperStopWithGraceDefault(t *testing.T) {
+	c := stoptest.NewFakeClock(time.Unix(0, 0))
+	s := stop.NewStopper(stop.WithClock(c))
+	type key struct{}
+	ctxs := map[string]context.Context{
+		"background": context.Background(),
+		"value":      context.WithValue(context.Background(), key{}, "v"),
+	}
+
+	var wg sync.WaitGroup
+	canceled := make(chan string, len(ctxs))
+	for name, ctx := range ctxs {
+		wg.Add(1)
+		if err := s.RunAsyncTask(ctx, func(ctx context.Context) {
+			wg.Done()
+			// Ignore quiescing; only exit when the context is canceled.
+			<-ctx.Done()
+			if ctx.Value(key{}) != ctxs[name].Value(key{}) {
+				t.Errorf("%s: expected the values of the context to be kept", name)
+			}
+			canceled <- name
+		}, stop.TaskName(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.StopWithGrace(context.Background(), time.Minute)
+	}()
+	SucceedsSoon(t, func() error {
+		if c.Waiters() == 0 {
+			return errors.New("waiting for grace period timer")
+		}
+		return nil
+	})
+	select {
+	case name := <-canceled:
+		t.Fatalf("%s: task canceled before grace period expired", name)
+	default:
+	}
+
+	c.Advance(time.Minute)
+	err := <-errCh
+	var ae *stop.AbandonedError
+	if !errors.As(err, &ae) || ae.Tasks["background"] != 1 || ae.Tasks["value"] != 1 {
+		t.Fatalf("expected both tasks to be abandoned, got %v", err)
+	}
+	for range ctxs {
+		<-canceled
+	}
+	<-s.IsStopped()
+}
+
+func TestStopperStopWithGraceClean(t *testing.T) {
+	s := stop.NewStopper()
+	if err := s.StopWithGrace(context.Background(), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	<-s.IsStopped()
+}
+
+func TestStopperBeginStop(t *testing.T) {
+	c := stoptest.NewFakeClock(time.Unix(0, 0))
+	s := stop.NewStopper(stop.WithClock(c))
+	ctx := context.Background()
+
+	started := make(chan struct{})
+	if err := s.RunAsyncTask(ctx, func(context.Context) {
+		close(started)
+		// Only exit when forced to.
+		ctx, cancel := s.ForceContext(context.Background())
+		defer cancel()
+		<-ctx.Done()
+	}, stop.TaskName("stubborn")); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	sc := s.BeginStop(ctx)
+	graceful := make(chan bool, 1)
+	go func() {
+		graceful <- sc.WaitGraceful(time.Minute)
+	}()
+	SucceedsSoon(t, func() error {
+		if c.Waiters() == 0 {
+			return errors.New("waiting for graceful wait timer")
+		}
+		return nil
+	})
+	c.Advance(time.Minute)
+	if <-graceful {
+		t.Fatal("expected graceful stop to time out")
+	}
+
+	if tasks := sc.ForceCancel(); tasks["stubborn"] != 1 {
+		t.Errorf("expected stubborn task to be running, got:\n%s", tasks)
+	}
+	<-sc.Done()
+	select {
+	case <-s.IsStopped():
+	default:
+		t.Error("expected stopper to be stopped")
+	}
+}
+
+func TestStopperBeginStopSite(t *testing.T) {
+	var buf bytes.Buffer
+	s := stop.NewStopper(stop.WithLogger(log.New(&buf, "", 0)))
+	_, line, _ := caller.Lookup(0)
+	sc := s.BeginStop(context.Background())
+	<-sc.Done()
+	site := fmt.Sprintf("grace_test.go:%d,", line+1)
+	if !strings.Contains(buf.String(), site) {
+		t.Errorf("expected stop from %s to be logged, got %q", site, buf.String())
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// WriteGraph writes the stopper and what it is tracking, namely its running
+// tasks, background tasks and workers, to w as a Graphviz DOT digraph. Tasks
+// are grouped by name or call site (see TaskName and TrackTasks), and workers
+// are listed by name if started with RunWorkerWithHeartbeat and otherwise
+// only counted. Render it with, for instance, "dot -Tsvg".
+func (s *Stopper) WriteGraph(w io.Writer) error {
+	return writeGraph(w, []*Stopper{s})
+}
+
+// WriteGraph is like Stopper.WriteGraph, but writes all the stoppers which
+// have been created by NewStopper and have not yet stopped into one graph.
+// It is also served by HandleDebug when the format=dot query parameter is
+// given.
+func WriteGraph(w io.Writer) error {
+	trackedStoppers.Lock()
+	stoppers := append([]*Stopper(nil), trackedStoppers.stoppers...)
+	trackedStoppers.Unlock()
+	return writeGraph(w, stoppers)
+}
+
+func writeGraph(w io.Writer, stoppers []*Stopper) error {
+	var buf bytes.Buffer
+	buf.WriteString("digraph stoppers {\n\tnode [shape=box];\n")
+	for i, s := range stoppers {
+		s.writeGraphNodes(&buf, fmt.Sprintf("s%d", i))
+	}
+	buf.WriteString("}\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeGraphNodes writes the nodes and edges for the stopper, prefixing the
+// node identifiers with id.
+func (s *Stopper) writeGraphNodes(buf *bytes.Buffer, id string) {
+	s.mu.Lock()
+	name := s.name
+	if name == "" {
+		name = fmt.Sprintf("%p", s)
+	}
+	label := fmt.Sprintf("%s\n%s, %d workers", name, s.stateLocked(), s.mu.numWorkers)
+	tasks := s.runningTasksLocked()
+	background := s.backgroundTasksLocked()
+	s.mu.Unlock()
+
+	var workers []string
+	s.heartbeats.Lock()
+	for h := range s.heartbeats.m {
+		workers = append(workers, h.name)
+	}
+	s.heartbeats.Unlock()
+	sort.Strings(workers)
+
+	fmt.Fprintf(buf, "\t%s [label=%s, shape=ellipse];\n", id, dotQuote(label))
+	n := 0
+	edge := func(label, style string) {
+		child := fmt.Sprintf("%s_%d", id, n)
+		n++
+		fmt.Fprintf(buf, "\t%s [label=%s, style=%s];\n", child, dotQuote(label), style)
+		fmt.Fprintf(buf, "\t%s -> %s;\n", id, child)
+	}
+	for _, task := range sortedTasks(tasks) {
+		edge(fmt.Sprintf("%s\n%d tasks", task, tasks[task]), "solid")
+	}
+	for _, task := range sortedTasks(background) {
+		edge(fmt.Sprintf("%s\n%d background tasks", task, background[task]), "dashed")
+	}
+	for _, worker := range workers {
+		edge(fmt.Sprintf("%s\nworker", worker), "bold")
+	}
+}
+
+func sortedTasks(tm TaskMap) []string {
+	tasks := make([]string, 0, len(tm))
+	for task := range tm {
+		tasks = append(tasks, task)
+	}
+	sort.Strings(tasks)
+	return tasks
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// dotQuote returns s as a quoted DOT string.
+func dotQuote(s string) string {
+	return `"` + dotEscaper.Replace(s) + `"`
+}
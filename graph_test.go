@@ -0,0 +1,73 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperWriteGraph(t *testing.T) {
+	s := stop.NewStopper(stop.WithName("kv-server"))
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	block := make(chan struct{})
+	defer close(block)
+	for i := 0; i < 2; i++ {
+		if err := s.RunAsyncTask(ctx, func(context.Context) { <-block }, stop.TaskName("raft")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.RunBackgroundTask(ctx, func(ctx context.Context) { <-ctx.Done() }, stop.TaskName("gossip")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RunWorkerWithHeartbeat(ctx, "scanner", time.Minute, func(ctx context.Context, h *stop.Heartbeat) {
+		<-s.ShouldStop()
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := s.WriteGraph(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, expected := range []string{
+		"digraph stoppers {",
+		`s0 [label="kv-server\nrunning, 1 workers", shape=ellipse];`,
+		`[label="raft\n2 tasks", style=solid];`,
+		`[label="gossip\n1 background tasks", style=dashed];`,
+		`[label="scanner\nworker", style=bold];`,
+		"s0 -> s0_2;",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in graph:\n%s", expected, out)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	stop.HandleDebug(w, httptest.NewRequest("GET", "/debug/stopper?format=dot", nil))
+	if out := w.Body.String(); !strings.HasPrefix(out, "digraph stoppers {") || !strings.Contains(out, "kv-server") {
+		t.Errorf("unexpected debug graph:\n%s", out)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// A Heartbeat is handed to workers started with RunWorkerWithHeartbeat. The
+// worker must call Beat at least once per deadline, or it is considered
+// wedged by the watchdog.
+type Heartbeat struct {
+	name     string
+	clock    Clock
+	deadline time.Duration
+	last     int64 // unix nanoseconds of the last beat, accessed atomically
+	reported int32 // 1 if reported as wedged since the last beat
+}
+
+// Beat records that the worker is alive.
+func (h *Heartbeat) Beat() {
+	atomic.StoreInt64(&h.last, h.clock.Now().UnixNano())
+	atomic.StoreInt32(&h.reported, 0)
+}
+
+func (h *Heartbeat) lastBeat() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&h.last))
+}
+
+// A WedgedWorker describes a worker which has not called Heartbeat.Beat within
+// its deadline.
+type WedgedWorker struct {
+	Name          string
+	LastHeartbeat time.Time
+	Deadline      time.Duration
+}
+
+type optionWatchdog struct {
+	interval time.Duration
+	report   func(WedgedWorker)
+}
+
+func (ow optionWatchdog) apply(stopper *Stopper) {
+	stopper.watchdog = ow
+}
+
+// Watchdog is an option which makes the Stopper check the heartbeats of
+// workers started with RunWorkerWithHeartbeat every interval. The report
+// function is called once for each worker that missed its deadline; it is
+// called again only if the worker beats and then misses its deadline anew.
+func Watchdog(interval time.Duration, report func(WedgedWorker)) Option {
+	return optionWatchdog{interval, report}
+}
+
+type heartbeats struct {
+	sync.Mutex
+	m map[*Heartbeat]struct{}
+}
+
+// RunWorkerWithHeartbeat runs the supplied function as a worker (see
+// RunWorker), handing it a Heartbeat on which it must call Beat at least once
+// per deadline. Workers that fail to do so are returned by WedgedWorkers and
+// reported by the Watchdog option, if configured.
+//
+// Returns ErrUnavailable if the stopper is already stopping, in which case
+// function f is not called.
+func (s *Stopper) RunWorkerWithHeartbeat(
+	ctx context.Context, name string, deadline time.Duration, f func(context.Context, *Heartbeat),
+) error {
+	h := &Heartbeat{name: name, clock: s.clock, deadline: deadline}
+	h.Beat()
+
+	s.heartbeats.Lock()
+	s.heartbeats.m[h] = struct{}{}
+	s.heartbeats.Unlock()
+
+	unregister := func() {
+		s.heartbeats.Lock()
+		delete(s.heartbeats.m, h)
+		s.heartbeats.Unlock()
+	}
+
+	err := s.RunWorker(ctx, func(ctx context.Context) {
+		defer unregister()
+		f(ctx, h)
+	})
+	if err != nil {
+		unregister()
+	}
+	return err
+}
+
+// WedgedWorkers returns the workers which have not heartbeated within their
+// deadline, sorted by name.
+func (s *Stopper) WedgedWorkers() []WedgedWorker {
+	var wedged []WedgedWorker
+	s.checkHeartbeats(func(h *Heartbeat) {
+		wedged = append(wedged, WedgedWorker{h.name, h.lastBeat(), h.deadline})
+	})
+	sort.Slice(wedged, func(i, j int) bool { return wedged[i].Name < wedged[j].Name })
+	return wedged
+}
+
+func (s *Stopper) checkHeartbeats(fn func(*Heartbeat)) {
+	now := s.clock.Now()
+	s.heartbeats.Lock()
+	defer s.heartbeats.Unlock()
+	for h := range s.heartbeats.m {
+		if now.Sub(h.lastBeat()) > h.deadline {
+			fn(h)
+		}
+	}
+}
+
+func (s *Stopper) runWatchdog() {
+	s.RunWorker(context.Background(), func(context.Context) {
+		ticker := s.clock.NewTicker(s.watchdog.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.Chan():
+				var wedged []WedgedWorker
+				s.checkHeartbeats(func(h *Heartbeat) {
+					if atomic.CompareAndSwapInt32(&h.reported, 0, 1) {
+						wedged = append(wedged, WedgedWorker{h.name, h.lastBeat(), h.deadline})
+					}
+				})
+				for _, w := range wedged {
+					s.watchdog.report(w)
+				}
+			case <-s.ShouldStop():
+				return
+			}
+		}
+	})
+}
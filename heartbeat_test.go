@@ -0,0 +1,77 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperWatchdog(t *testing.T) {
+	reported := make(chan stop.WedgedWorker, 10)
+	s := stop.NewStopper(stop.Watchdog(time.Millisecond, func(w stop.WedgedWorker) {
+		reported <- w
+	}))
+	ctx := context.Background()
+
+	s.RunWorkerWithHeartbeat(ctx, "healthy", time.Second, func(ctx context.Context, h *stop.Heartbeat) {
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				h.Beat()
+			case <-s.ShouldStop():
+				return
+			}
+		}
+	})
+
+	wedge := make(chan struct{})
+	s.RunWorkerWithHeartbeat(ctx, "wedged", 5*time.Millisecond, func(context.Context, *stop.Heartbeat) {
+		<-wedge
+	})
+
+	select {
+	case w := <-reported:
+		if w.Name != "wedged" {
+			t.Fatalf("expected worker %q to be reported, got %q", "wedged", w.Name)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected wedged worker to be reported")
+	}
+
+	if ws := s.WedgedWorkers(); len(ws) != 1 || ws[0].Name != "wedged" {
+		t.Fatalf("expected only the wedged worker, got %+v", ws)
+	}
+
+	// The worker is reported only once per missed deadline.
+	select {
+	case w := <-reported:
+		t.Fatalf("unexpected second report: %+v", w)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(wedge)
+	s.Stop(ctx)
+
+	if ws := s.WedgedWorkers(); len(ws) != 0 {
+		t.Fatalf("expected no wedged workers after stop, got %+v", ws)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import "time"
+
+// A TaskEnd describes the outcome of a task to the handler set with
+// OnTaskEnd.
+type TaskEnd struct {
+	TaskInfo
+	// Duration is the time the task ran for.
+	Duration time.Duration
+	// Err is the error returned by a task run with RunTaskWithErr.
+	Err error
+	// Panic is the value the task panicked with, or nil.
+	Panic interface{}
+}
+
+type optionTaskStartHandler func(TaskInfo)
+
+func (otsh optionTaskStartHandler) apply(stopper *Stopper) {
+	stopper.onTaskStart = otsh
+}
+
+// OnTaskStart is an option which sets a handler called whenever a task
+// begins to run, after it has been admitted. The handler is called by the
+// goroutine running the task and should not block. See WithTaskInterceptor
+// for wrapping the execution of tasks instead.
+func OnTaskStart(handler func(TaskInfo)) Option {
+	return optionTaskStartHandler(handler)
+}
+
+type optionTaskEndHandler func(TaskEnd)
+
+func (oteh optionTaskEndHandler) apply(stopper *Stopper) {
+	stopper.onTaskEnd = oteh
+}
+
+// OnTaskEnd is an option which sets a handler called whenever a task has run,
+// including when it panicked, with its outcome. The handler is called by the
+// goroutine running the task, before the panic, if any, is handled, and
+// should not block.
+func OnTaskEnd(handler func(TaskEnd)) Option {
+	return optionTaskEndHandler(handler)
+}
@@ -0,0 +1,65 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"testing"
+
+	"github.com/birkelund/stop"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperTaskHooks(t *testing.T) {
+	var started []string
+	var ended []stop.TaskEnd
+	s := stop.NewStopper(
+		stop.OnTaskStart(func(info stop.TaskInfo) { started = append(started, info.Task) }),
+		stop.OnTaskEnd(func(end stop.TaskEnd) { ended = append(ended, end) }),
+		stop.OnPanic(func(interface{}) {}),
+	)
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	if err := s.RunTask(ctx, func(context.Context) {}, stop.TaskName("ok")); err != nil {
+		t.Fatal(err)
+	}
+	taskErr := errors.New("task failed")
+	if err := s.RunTaskWithErr(ctx, func(context.Context) error { return taskErr }, stop.TaskName("failing")); err != taskErr {
+		t.Fatalf("expected %v, got %v", taskErr, err)
+	}
+	if err := s.RunTask(ctx, func(context.Context) { panic("boom") }, stop.TaskName("panicking")); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(started) != 3 || len(ended) != 3 {
+		t.Fatalf("expected 3 starts and ends, got %v and %+v", started, ended)
+	}
+	for i, name := range []string{"ok", "failing", "panicking"} {
+		if started[i] != name || ended[i].Task != name {
+			t.Errorf("expected task %s, got %s and %s", name, started[i], ended[i].Task)
+		}
+	}
+	if ended[0].Err != nil || ended[0].Panic != nil {
+		t.Errorf("expected successful outcome, got %+v", ended[0])
+	}
+	if ended[1].Err != taskErr {
+		t.Errorf("expected error outcome, got %+v", ended[1])
+	}
+	if ended[2].Panic != "boom" {
+		t.Errorf("expected panic outcome, got %+v", ended[2])
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import "golang.org/x/net/context"
+
+// A TaskInfo describes a task to a TaskInterceptor.
+type TaskInfo struct {
+	// Task identifies the task by name or call site.
+	Task string
+	// Site is the call site the task was submitted from. It is empty if task
+	// tracking is disabled (see TrackTasks).
+	Site string
+	// Async is true if the task runs in its own goroutine, or on a pool
+	// worker, rather than in the goroutine which submitted it.
+	Async bool
+}
+
+// A TaskInterceptor wraps the execution of tasks. It must call next, with ctx
+// or a context derived from it, to run the task, and may do work before and
+// after, such as recording metrics or starting a tracing span.
+type TaskInterceptor func(ctx context.Context, info TaskInfo, next func(context.Context))
+
+type optionTaskInterceptor TaskInterceptor
+
+func (oti optionTaskInterceptor) apply(stopper *Stopper) {
+	stopper.interceptors = append(stopper.interceptors, TaskInterceptor(oti))
+}
+
+// WithTaskInterceptor is an option which makes the stopper run every task,
+// synchronous or asynchronous, through the interceptor, so that cross-cutting
+// concerns need not be handled at each call site. If the option is given more
+// than once, the first interceptor is the outermost.
+//
+// Interceptors run once a task has been admitted, and a panic in an
+// interceptor is handled like a panic in the task.
+func WithTaskInterceptor(interceptor TaskInterceptor) Option {
+	return optionTaskInterceptor(interceptor)
+}
+
+// runTaskFunc runs f through the interceptors and task hooks, and records its
+// latency. f is passed a context which is canceled once the stopper is forced
+// to stop (see taskContext).
+func (s *Stopper) runTaskFunc(
+	ctx context.Context, key taskKey, o *taskOptions, async bool, f func(context.Context),
+) {
+	ctx, cancel := s.taskContext(ctx)
+	if cancel != nil {
+		defer cancel()
+	}
+	if s.plainTasks() {
+		f(ctx)
+		return
+	}
+	s.wrapTaskFunc(ctx, key, o, async, func(ctx context.Context) error {
+		f(ctx)
+		return nil
+	})
+}
+
+// runTaskFuncWithErr is like runTaskFunc, but returns the error returned by f.
+func (s *Stopper) runTaskFuncWithErr(
+	ctx context.Context, key taskKey, o *taskOptions, async bool, f func(context.Context) error,
+) error {
+	ctx, cancel := s.taskContext(ctx)
+	if cancel != nil {
+		defer cancel()
+	}
+	var err error
+	if s.plainTasks() {
+		err = f(ctx)
+	} else {
+		err = s.wrapTaskFunc(ctx, key, o, async, f)
+	}
+	if err != nil && s.failFast {
+		s.failFastStop(runError(key, o, err))
+	}
+	return err
+}
+
+// plainTasks reports whether tasks are run without any of the options
+// handled by wrapTaskFunc, in which case calling the task function directly
+// avoids allocating.
+func (s *Stopper) plainTasks() bool {
+	return !s.cancelOnQuiesce && len(s.interceptors) == 0 && s.onTaskStart == nil &&
+		s.onTaskEnd == nil && s.latencies.bounds == nil && s.watched.m == nil &&
+		s.tracer == nil && s.metrics == nil
+}
+
+func (s *Stopper) wrapTaskFunc(
+	ctx context.Context, key taskKey, o *taskOptions, async bool, f func(context.Context) error,
+) (err error) {
+	if s.cancelOnQuiesce && o.priority < HighPriority && !o.longLived {
+		var cancel context.CancelFunc
+		ctx, cancel = s.QuiesceContext(ctx)
+		defer cancel()
+	}
+	info := TaskInfo{Task: key.String(), Site: o.site(), Async: async}
+	if s.tracer != nil {
+		var finish func()
+		ctx, finish = s.tracer.StartSpan(ctx, info.Task)
+		defer finish()
+	}
+	if s.watched.m != nil && !o.longLived {
+		defer s.watch(ctx, info)()
+	}
+	if s.onTaskStart != nil {
+		s.onTaskStart(info)
+	}
+	if s.metrics != nil {
+		s.metrics.TaskStarted(info)
+	}
+	if s.onTaskEnd != nil || s.metrics != nil || s.latencies.bounds != nil {
+		start := s.clock.Now()
+		defer func() {
+			r := recover()
+			d := s.clock.Now().Sub(start)
+			s.latencies.record(info.Task, d)
+			end := TaskEnd{info, d, err, r}
+			if s.onTaskEnd != nil {
+				s.onTaskEnd(end)
+			}
+			if s.metrics != nil {
+				s.metrics.TaskEnded(end)
+			}
+			if r != nil {
+				panic(r)
+			}
+		}()
+	}
+
+	next := func(ctx context.Context) {
+		err = f(ctx)
+	}
+	for i := len(s.interceptors) - 1; i >= 0; i-- {
+		interceptor, inner := s.interceptors[i], next
+		next = func(ctx context.Context) {
+			interceptor(ctx, info, inner)
+		}
+	}
+	next(ctx)
+	return err
+}
@@ -0,0 +1,80 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/birkelund/stop"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+type ctxKey struct{}
+
+func TestStopperWithTaskInterceptor(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	record := func(name string) stop.TaskInterceptor {
+		return func(ctx context.Context, info stop.TaskInfo, next func(context.Context)) {
+			mu.Lock()
+			calls = append(calls, name+" "+info.Task)
+			mu.Unlock()
+			next(context.WithValue(ctx, ctxKey{}, name))
+		}
+	}
+	s := stop.NewStopper(stop.WithTaskInterceptor(record("outer")), stop.WithTaskInterceptor(record("inner")))
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	if err := s.RunTask(ctx, func(ctx context.Context) {
+		if v := ctx.Value(ctxKey{}); v != "inner" {
+			t.Errorf("expected the context from the innermost interceptor, got %v", v)
+		}
+	}, stop.TaskName("sync")); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedErr := errors.New("task failed")
+	if err := s.RunTaskWithErr(ctx, func(context.Context) error {
+		return expectedErr
+	}, stop.TaskName("witherr")); err != expectedErr {
+		t.Fatalf("expected %v, got %v", expectedErr, err)
+	}
+
+	var async stop.TaskInfo
+	done := make(chan struct{})
+	s2 := stop.NewStopper(stop.WithTaskInterceptor(
+		func(ctx context.Context, info stop.TaskInfo, next func(context.Context)) {
+			async = info
+			next(ctx)
+		}))
+	defer s2.Stop(ctx)
+	if err := s2.RunAsyncTask(ctx, func(context.Context) { close(done) }, stop.TaskName("async")); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if !async.Async || async.Task != "async" || async.Site == "" {
+		t.Errorf("unexpected task info %+v", async)
+	}
+
+	expected := []string{"outer sync", "inner sync", "outer witherr", "inner witherr"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected calls %v, got %v", expected, calls)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are the upper bounds of the buckets of the latency
+// histograms, unless others are given to TrackTaskLatencies.
+var DefaultLatencyBuckets = []time.Duration{
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+	time.Minute,
+}
+
+// A LatencyHistogram counts the run durations of tasks.
+type LatencyHistogram struct {
+	// Bounds are the inclusive upper bounds of the buckets, in increasing
+	// order.
+	Bounds []time.Duration
+	// Counts holds the number of durations in each bucket, with an extra
+	// last bucket for those above the last bound.
+	Counts []int64
+	// Count, Sum and Max describe all the durations.
+	Count int64
+	Sum   time.Duration
+	Max   time.Duration
+}
+
+// Mean returns the mean duration, or zero if there are none.
+func (h LatencyHistogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+func (h *LatencyHistogram) record(d time.Duration) {
+	i := sort.Search(len(h.Bounds), func(i int) bool { return d <= h.Bounds[i] })
+	h.Counts[i]++
+	h.Count++
+	h.Sum += d
+	if d > h.Max {
+		h.Max = d
+	}
+}
+
+// A LatencyMap is returned by TaskLatencies.
+type LatencyMap map[string]LatencyHistogram
+
+// String implements fmt.Stringer and returns a multi-line listing of the
+// LatencyMap, with the task count, mean and maximum duration per task, sorted
+// by total duration.
+func (lm LatencyMap) String() string {
+	tasks := make([]string, 0, len(lm))
+	for task := range lm {
+		tasks = append(tasks, task)
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return lm[tasks[i]].Sum > lm[tasks[j]].Sum
+	})
+	var lines []string
+	for _, task := range tasks {
+		h := lm[task]
+		lines = append(lines, fmt.Sprintf("%-6d %-10s %-10s %s", h.Count, h.Mean(), h.Max, task))
+	}
+	return strings.Join(lines, "\n")
+}
+
+type taskLatencies struct {
+	bounds []time.Duration // nil if latencies are not tracked
+
+	sync.Mutex
+	m map[string]*LatencyHistogram
+}
+
+func (tl *taskLatencies) record(task string, d time.Duration) {
+	if tl.bounds == nil {
+		return
+	}
+	tl.Lock()
+	defer tl.Unlock()
+	h, ok := tl.m[task]
+	if !ok {
+		h = &LatencyHistogram{Bounds: tl.bounds, Counts: make([]int64, len(tl.bounds)+1)}
+		tl.m[task] = h
+	}
+	h.record(d)
+}
+
+type optionTrackTaskLatencies []time.Duration
+
+func (ottl optionTrackTaskLatencies) apply(stopper *Stopper) {
+	stopper.latencies.bounds = ottl
+	stopper.latencies.m = map[string]*LatencyHistogram{}
+}
+
+// TrackTaskLatencies is an option which makes the stopper record the run
+// durations of tasks in a histogram per task name or call site (see
+// TaskLatencies). The buckets are given by their upper bounds, in increasing
+// order; if none are given, DefaultLatencyBuckets are used.
+func TrackTaskLatencies(buckets ...time.Duration) Option {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	return optionTrackTaskLatencies(buckets)
+}
+
+// TaskLatencies returns the latency histograms of the tasks which have run,
+// keyed by task name or call site. It is empty unless the stopper was created
+// with the TrackTaskLatencies option.
+func (s *Stopper) TaskLatencies() LatencyMap {
+	s.latencies.Lock()
+	defer s.latencies.Unlock()
+	lm := LatencyMap{}
+	for task, h := range s.latencies.m {
+		c := *h
+		c.Counts = append([]int64(nil), h.Counts...)
+		lm[task] = c
+	}
+	return lm
+}
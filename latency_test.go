@@ -0,0 +1,62 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+	"github.com/birkelund/stop/stoptest"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperTaskLatencies(t *testing.T) {
+	c := stoptest.NewFakeClock(time.Unix(0, 0))
+	s := stop.NewStopper(stop.WithClock(c), stop.TrackTaskLatencies(time.Second, time.Minute))
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	for _, d := range []time.Duration{time.Second, 2 * time.Second, time.Hour} {
+		if err := s.RunTask(ctx, func(context.Context) { c.Advance(d) }, stop.TaskName("raft-apply")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.RunTask(ctx, func(context.Context) {}, stop.TaskName("gossip")); err != nil {
+		t.Fatal(err)
+	}
+
+	lm := s.TaskLatencies()
+	h := lm["raft-apply"]
+	if !reflect.DeepEqual(h.Counts, []int64{1, 1, 1}) {
+		t.Errorf("expected one duration per bucket, got %v", h.Counts)
+	}
+	if h.Count != 3 || h.Max != time.Hour || h.Mean() != (time.Hour+3*time.Second)/3 {
+		t.Errorf("unexpected histogram %+v", h)
+	}
+	if g := lm["gossip"]; g.Count != 1 || g.Sum != 0 {
+		t.Errorf("unexpected histogram %+v", g)
+	}
+	if lines := strings.Split(lm.String(), "\n"); len(lines) != 2 || !strings.HasSuffix(lines[0], "raft-apply") {
+		t.Errorf("expected raft-apply to be listed first:\n%s", lm)
+	}
+
+	if lm := stop.NewNopStopper().TaskLatencies(); len(lm) != 0 {
+		t.Errorf("expected no latencies unless tracked, got %v", lm)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"runtime/debug"
+	"sync/atomic"
+)
+
+var leakTracking int32 // 1 if creation stacks are recorded
+
+// EnableLeakTracking makes NewStopper record the stack trace of its caller,
+// which is reported by LeakedStoppers. It is meant to be called from TestMain
+// before any stoppers are created.
+func EnableLeakTracking() {
+	SetLeakTracking(true)
+}
+
+// SetLeakTracking turns the recording of creation stacks (see
+// EnableLeakTracking) on or off, and returns whether it was on, so that a
+// test which turns it on can restore the previous state in a cleanup.
+func SetLeakTracking(enabled bool) (previous bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	return atomic.SwapInt32(&leakTracking, v) != 0
+}
+
+// A LeakedStopper is a stopper which has been created but not stopped.
+type LeakedStopper struct {
+	Stopper *Stopper
+	// Stack is the stack trace of the creation of the stopper, if leak
+	// tracking was enabled at the time (see EnableLeakTracking).
+	Stack []byte
+}
+
+// LeakedStoppers returns the stoppers created by NewStopper whose Stop method
+// has not been called or has not yet returned. Checking for leaked stoppers at
+// the end of a test suite catches lifecycle bugs, such as goroutines which are
+// never told to exit.
+func LeakedStoppers() []LeakedStopper {
+	trackedStoppers.Lock()
+	defer trackedStoppers.Unlock()
+	var leaked []LeakedStopper
+	for _, s := range trackedStoppers.stoppers {
+		leaked = append(leaked, LeakedStopper{s, s.creationStack})
+	}
+	return leaked
+}
+
+func recordCreationStack(s *Stopper) {
+	if atomic.LoadInt32(&leakTracking) != 0 {
+		s.creationStack = debug.Stack()
+	}
+}
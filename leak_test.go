@@ -0,0 +1,58 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/birkelund/stop"
+
+	"golang.org/x/net/context"
+)
+
+func TestLeakedStoppers(t *testing.T) {
+	prev := stop.SetLeakTracking(false)
+	t.Cleanup(func() { stop.SetLeakTracking(prev) })
+
+	leaked := func(s *stop.Stopper) (stop.LeakedStopper, bool) {
+		for _, l := range stop.LeakedStoppers() {
+			if l.Stopper == s {
+				return l, true
+			}
+		}
+		return stop.LeakedStopper{}, false
+	}
+
+	untracked := stop.NewStopper()
+	defer untracked.Stop(context.Background())
+	if l, ok := leaked(untracked); !ok || l.Stack != nil {
+		t.Fatalf("expected leaked stopper without stack, got %+v (%t)", l, ok)
+	}
+
+	if stop.SetLeakTracking(true) {
+		t.Fatal("expected leak tracking to have been off")
+	}
+	s := stop.NewStopper()
+	l, ok := leaked(s)
+	if !ok || !strings.Contains(string(l.Stack), "leak_test.go") {
+		t.Fatalf("expected creation stack of leaked stopper, got %q", l.Stack)
+	}
+
+	s.Stop(context.Background())
+	if _, ok := leaked(s); ok {
+		t.Fatal("expected stopped stopper not to be reported")
+	}
+}
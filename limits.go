@@ -0,0 +1,59 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import "sync"
+
+type taskLimits struct {
+	sync.Mutex
+	m map[string]*WeightedSemaphore
+}
+
+// SetTaskLimit sets the maximum number of async tasks with the given name (see
+// TaskName) that may run concurrently. Once the limit is reached, RunAsyncTask
+// waits for one of the running tasks to finish, as if it was
+// RunLimitedAsyncTask with wait set to true, while TryRunAsyncTask returns
+// ErrThrottled. A non-positive n removes the limit.
+//
+// Changing the limit of a name affects running tasks; if the limit is
+// lowered below the number of running tasks, no new task with the name is
+// started until enough of them have finished.
+func (s *Stopper) SetTaskLimit(name string, n int) {
+	s.limits.Lock()
+	defer s.limits.Unlock()
+	if n <= 0 {
+		delete(s.limits.m, name)
+		return
+	}
+	if ws, ok := s.limits.m[name]; ok {
+		ws.resize(int64(n))
+		return
+	}
+	s.limits.m[name] = NewWeightedSemaphore(int64(n))
+}
+
+// taskLimit returns the semaphore enforcing the limit for tasks with the given
+// name, or nil if there is no limit.
+func (s *Stopper) taskLimit(name string) Semaphore {
+	if name == "" {
+		return nil
+	}
+	s.limits.Lock()
+	defer s.limits.Unlock()
+	if ws, ok := s.limits.m[name]; ok {
+		return ws
+	}
+	return nil
+}
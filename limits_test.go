@@ -0,0 +1,87 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperSetTaskLimit(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	s.SetTaskLimit("snapshot", 2)
+
+	var running, maxRunning int32
+	block := make(chan struct{})
+	f := func(context.Context) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		<-block
+		atomic.AddInt32(&running, -1)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.RunAsyncTask(ctx, f, stop.TaskName("snapshot")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.TryRunAsyncTask(ctx, f, stop.TaskName("snapshot")); !errors.Is(err, stop.ErrThrottled) {
+		t.Fatalf("expected %v; got %v", stop.ErrThrottled, err)
+	}
+
+	// Tasks with other names are not limited.
+	done := make(chan struct{})
+	if err := s.RunAsyncTask(ctx, func(context.Context) { close(done) }); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.RunAsyncTask(ctx, f, stop.TaskName("snapshot"))
+	}()
+	select {
+	case err := <-errCh:
+		t.Fatalf("expected RunAsyncTask to wait for the limit, got %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(block)
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+	if m := atomic.LoadInt32(&maxRunning); m > 2 {
+		t.Fatalf("expected at most 2 concurrent tasks, got %d", m)
+	}
+
+	s.SetTaskLimit("snapshot", 0)
+	if err := s.TryRunAsyncTask(ctx, func(context.Context) {}, stop.TaskName("snapshot")); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	// ListenFDsEnv is the environment variable through which HandOff passes
+	// the listening sockets to the new process. It holds a "network:address"
+	// entry per socket, separated by ";", for consecutive file descriptors
+	// starting at the one given by ListenFDStartEnv.
+	ListenFDsEnv = "STOP_LISTEN_FDS"
+	// ListenFDStartEnv is the environment variable holding the first file
+	// descriptor of the sockets listed in ListenFDsEnv, which follow the
+	// ExtraFiles of the command passed to HandOff. If it is not set, the
+	// sockets start at 3.
+	ListenFDStartEnv = "STOP_LISTEN_FD_START"
+	// ReadyFDEnv is the environment variable through which HandOff passes
+	// the file descriptor the new process signals readiness on (see Ready).
+	ReadyFDEnv = "STOP_READY_FD"
+)
+
+// A handOffListener is a listener created by Listen, along with the network
+// and address it was requested for.
+type handOffListener struct {
+	network, addr string
+	l             net.Listener
+}
+
+// A filer is a listener whose socket can be passed to another process.
+type filer interface {
+	File() (*os.File, error)
+}
+
+var inherited struct {
+	once      sync.Once
+	mu        sync.Mutex
+	listeners map[string]net.Listener
+	err       error
+}
+
+// inheritedListener returns the listener for the network and address passed
+// by the process which started this one, if any. Each inherited listener is
+// returned only once.
+func inheritedListener(network, addr string) (net.Listener, error) {
+	inherited.once.Do(func() {
+		inherited.listeners, inherited.err = parseListenFDs(os.Getenv(ListenFDsEnv), os.Getenv(ListenFDStartEnv))
+		os.Unsetenv(ListenFDsEnv)
+		os.Unsetenv(ListenFDStartEnv)
+	})
+	inherited.mu.Lock()
+	defer inherited.mu.Unlock()
+	if inherited.err != nil {
+		return nil, inherited.err
+	}
+	key := network + ":" + addr
+	l := inherited.listeners[key]
+	delete(inherited.listeners, key)
+	return l, nil
+}
+
+func parseListenFDs(env, startEnv string) (map[string]net.Listener, error) {
+	if env == "" {
+		return nil, nil
+	}
+	start := 3
+	if startEnv != "" {
+		var err error
+		if start, err = strconv.Atoi(startEnv); err != nil || start < 3 {
+			return nil, errors.Errorf("%s: invalid file descriptor %q", ListenFDStartEnv, startEnv)
+		}
+	}
+	m := map[string]net.Listener{}
+	for i, key := range strings.Split(env, ";") {
+		f := os.NewFile(uintptr(start+i), key)
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s: inheriting %s", ListenFDsEnv, key)
+		}
+		m[key] = l
+	}
+	return m, nil
+}
+
+// Listen is like net.Listen, but the listener is closed by Stop and passed to
+// the new process by HandOff. If this process was started by HandOff, the
+// listener for the same network and address is inherited from the old
+// process instead of created, so that no connections are refused while the
+// processes change over.
+func (s *Stopper) Listen(network, addr string) (net.Listener, error) {
+	l, err := inheritedListener(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil {
+		if l, err = net.Listen(network, addr); err != nil {
+			return nil, err
+		}
+	}
+	s.mu.Lock()
+	s.mu.listeners = append(s.mu.listeners, handOffListener{network, addr, l})
+	s.mu.Unlock()
+	s.AddIOCloser(l)
+	return l, nil
+}
+
+// HandOff starts cmd, typically a new instance of the running executable,
+// passing it the listeners created by Listen, and waits until it calls Ready.
+// The listeners are passed after the ExtraFiles of cmd, which keep their file
+// descriptors. The new process inherits the listeners by calling Listen with
+// the same network and address. Meanwhile, this process keeps serving; once
+// HandOff returns, it should stop, draining its connections, while the new
+// process accepts new ones.
+//
+// If the new process exits before calling Ready, or ctx is done first, the
+// new process is killed and an error is returned.
+func (s *Stopper) HandOff(ctx context.Context, cmd *exec.Cmd) error {
+	s.mu.Lock()
+	listeners := append([]handOffListener(nil), s.mu.listeners...)
+	s.mu.Unlock()
+
+	var keys []string
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, hl := range listeners {
+		fl, ok := hl.l.(filer)
+		if !ok {
+			return errors.Errorf("cannot hand off %s listener on %s", hl.network, hl.addr)
+		}
+		f, err := fl.File()
+		if err != nil {
+			return errors.Wrapf(err, "handing off %s listener on %s", hl.network, hl.addr)
+		}
+		keys = append(keys, hl.network+":"+hl.addr)
+		files = append(files, f)
+	}
+
+	ready, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer ready.Close()
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = nil
+	for _, kv := range env {
+		if !strings.HasPrefix(kv, ListenFDsEnv+"=") && !strings.HasPrefix(kv, ListenFDStartEnv+"=") &&
+			!strings.HasPrefix(kv, ReadyFDEnv+"=") {
+			cmd.Env = append(cmd.Env, kv)
+		}
+	}
+	// The descriptors of the caller's ExtraFiles are kept, and the listeners
+	// and the readiness pipe follow them.
+	start := 3 + len(cmd.ExtraFiles)
+	cmd.Env = append(cmd.Env,
+		ListenFDsEnv+"="+strings.Join(keys, ";"),
+		ListenFDStartEnv+"="+strconv.Itoa(start),
+		ReadyFDEnv+"="+strconv.Itoa(start+len(files)),
+	)
+	// Copy the caller's ExtraFiles, so that appending does not write into the
+	// spare capacity of their backing array.
+	extra := make([]*os.File, 0, len(cmd.ExtraFiles)+len(files)+1)
+	extra = append(extra, cmd.ExtraFiles...)
+	cmd.ExtraFiles = append(append(extra, files...), w)
+
+	err = cmd.Start()
+	// The new process has its own copy of the write end, so that reading
+	// ready returns EOF if it exits without calling Ready.
+	w.Close()
+	if err != nil {
+		return errors.Wrap(err, "starting new process")
+	}
+	go cmd.Wait()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := ready.Read(make([]byte, 1))
+		if err == io.EOF {
+			err = errors.New("new process exited before becoming ready")
+		}
+		done <- err
+	}()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		return err
+	}
+	s.logger.Printf("handed off %d listeners to process %d", len(files), cmd.Process.Pid)
+	return nil
+}
+
+type optionHandOffOnRestart struct{}
+
+func (optionHandOffOnRestart) apply(stopper *Stopper) {
+	stopper.restart = func(ctx context.Context) error {
+		exe, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		cmd := exec.Command(exe, os.Args[1:]...)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		return stopper.HandOff(ctx, cmd)
+	}
+}
+
+// HandOffOnRestart is an option which enables restart mode (see OnRestart)
+// with HandOff as the restart function, re-executing the running executable
+// with the same arguments.
+func HandOffOnRestart() Option {
+	return optionHandOffOnRestart{}
+}
+
+// Ready tells the process which started this one with HandOff that it is
+// ready to take over. It does nothing if the process was not started by
+// HandOff, or if Ready was already called.
+func Ready() error {
+	env := os.Getenv(ReadyFDEnv)
+	if env == "" {
+		return nil
+	}
+	os.Unsetenv(ReadyFDEnv)
+	fd, err := strconv.Atoi(env)
+	if err != nil {
+		return errors.Wrapf(err, "%s", ReadyFDEnv)
+	}
+	f := os.NewFile(uintptr(fd), "ready")
+	defer f.Close()
+	_, err = f.Write([]byte{1})
+	return err
+}
@@ -0,0 +1,133 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+
+	"golang.org/x/net/context"
+)
+
+const handOffHelperEnv = "STOP_TEST_HANDOFF_HELPER"
+
+// TestHandOffHelper is run as the new process by TestStopperHandOff.
+func TestHandOffHelper(t *testing.T) {
+	if os.Getenv(handOffHelperEnv) == "" {
+		t.Skip("only run by TestStopperHandOff")
+	}
+	s := stop.NewStopper()
+	defer s.Stop(context.Background())
+
+	l, err := s.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stop.Ready(); err != nil {
+		t.Fatal(err)
+	}
+	c, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Write([]byte("new")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStopperHandOff(t *testing.T) {
+	t.Run("plain", func(t *testing.T) {
+		testHandOff(t, nil)
+	})
+	t.Run("extra files", func(t *testing.T) {
+		// The listeners follow the files passed by the caller.
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		defer w.Close()
+		// Spare capacity in the caller's slice is not written to.
+		files := make([]*os.File, 2, 4)
+		files[0], files[1] = r, w
+		testHandOff(t, files)
+		if spare := files[:4]; spare[2] != nil || spare[3] != nil {
+			t.Error("HandOff wrote into the backing array of ExtraFiles")
+		}
+	})
+}
+
+func testHandOff(t *testing.T, extraFiles []*os.File) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+
+	l, err := s.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHandOffHelper$")
+	cmd.Env = append(os.Environ(), handOffHelperEnv+"=1")
+	cmd.ExtraFiles = extraFiles
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if err := s.HandOff(ctx, cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	// Once the old process has stopped, connections are accepted by the new
+	// one on the same socket.
+	s.Stop(ctx)
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	b, err := ioutil.ReadAll(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "new" {
+		t.Fatalf("expected response from new process, got %q", b)
+	}
+}
+
+func TestStopperHandOffNotReady(t *testing.T) {
+	s := stop.NewStopper()
+	defer s.Stop(context.Background())
+
+	if _, err := s.Listen("tcp", "127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	// The helper is skipped, so the process exits without calling Ready.
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHandOffHelper$")
+	if err := s.HandOff(context.Background(), cmd); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestReadyWithoutHandOff(t *testing.T) {
+	if err := stop.Ready(); err != nil {
+		t.Fatal(err)
+	}
+}
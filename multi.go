@@ -0,0 +1,58 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// QuiesceAll quiesces the stoppers concurrently and returns once all of them
+// have quiesced. Unlike quiescing them one by one, no stopper keeps accepting
+// tasks while another is draining, so tasks that call into other stoppers
+// cannot hold up the shutdown.
+func QuiesceAll(ctx context.Context, stoppers ...*Stopper) {
+	var wg sync.WaitGroup
+	wg.Add(len(stoppers))
+	for _, s := range stoppers {
+		go func(s *Stopper) {
+			defer wg.Done()
+			s.Quiesce(ctx)
+		}(s)
+	}
+	wg.Wait()
+}
+
+// StopAll drains the stoppers concurrently (see WithDrainDelay and OnDrain),
+// quiesces them with QuiesceAll and then stops them in the order given, so
+// that the workers and closers of a stopper are done before those of the next
+// one run. List stoppers before the stoppers they depend on, such as a server
+// before its storage.
+func StopAll(ctx context.Context, stoppers ...*Stopper) {
+	var wg sync.WaitGroup
+	wg.Add(len(stoppers))
+	for _, s := range stoppers {
+		go func(s *Stopper) {
+			defer wg.Done()
+			s.drain()
+		}(s)
+	}
+	wg.Wait()
+	QuiesceAll(ctx, stoppers...)
+	for _, s := range stoppers {
+		s.Stop(ctx)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/birkelund/stop"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+func TestQuiesceAll(t *testing.T) {
+	ctx := context.Background()
+	a, b := stop.NewStopper(), stop.NewStopper()
+	defer a.Stop(ctx)
+	defer b.Stop(ctx)
+
+	// A task on a which only finishes once b is quiescing would deadlock if
+	// the stoppers were quiesced one by one.
+	if err := a.RunAsyncTask(ctx, func(context.Context) { <-b.ShouldQuiesce() }); err != nil {
+		t.Fatal(err)
+	}
+	stop.QuiesceAll(ctx, a, b)
+
+	for _, s := range []*stop.Stopper{a, b} {
+		if err := s.RunTask(ctx, func(context.Context) {}); !errors.Is(err, stop.ErrUnavailable) {
+			t.Errorf("expected ErrUnavailable, got %v", err)
+		}
+	}
+}
+
+func TestStopAll(t *testing.T) {
+	ctx := context.Background()
+	var mu sync.Mutex
+	var closed []string
+	var stoppers []*stop.Stopper
+	for _, name := range []string{"server", "sessions", "db"} {
+		name := name
+		s := stop.NewStopper()
+		s.AddCloser(stop.CloserFn(func() {
+			mu.Lock()
+			closed = append(closed, name)
+			mu.Unlock()
+		}))
+		stoppers = append(stoppers, s)
+	}
+
+	stop.StopAll(ctx, stoppers...)
+
+	for _, s := range stoppers {
+		select {
+		case <-s.IsStopped():
+		default:
+			t.Errorf("%s not stopped", s)
+		}
+	}
+	if expected := []string{"server", "sessions", "db"}; !reflect.DeepEqual(closed, expected) {
+		t.Errorf("expected closers to run in order %v, got %v", expected, closed)
+	}
+}
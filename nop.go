@@ -0,0 +1,39 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+// NewNopStopper returns a Stopper for use in tests and by code which accepts
+// an optional stopper. It never stops: Stop is a no-op, so tasks are always
+// admitted. Async and background tasks are run synchronously, before the
+// Run* function returns, while workers are run in goroutines as usual.
+//
+// Options affecting panic handling and task admission are honored.
+func NewNopStopper(options ...Option) *Stopper {
+	s, err := newStopper(options)
+	if err != nil {
+		panic(err)
+	}
+	s.nop = true
+	return s
+}
+
+// goTask runs f in a new goroutine, or synchronously for a nop stopper.
+func (s *Stopper) goTask(f func()) {
+	if s.nop {
+		f()
+		return
+	}
+	go f()
+}
@@ -0,0 +1,54 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"testing"
+
+	"github.com/birkelund/stop"
+
+	"golang.org/x/net/context"
+)
+
+func TestNopStopper(t *testing.T) {
+	s := stop.NewNopStopper()
+	ctx := context.Background()
+
+	ran := 0
+	if err := s.RunAsyncTask(ctx, func(context.Context) { ran++ }); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RunLimitedAsyncTask(ctx, make(chan struct{}, 1), true, func(context.Context) {
+		ran++
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RunBackgroundTask(ctx, func(context.Context) { ran++ }); err != nil {
+		t.Fatal(err)
+	}
+	if ran != 3 {
+		t.Fatalf("expected tasks to run synchronously, %d of 3 ran", ran)
+	}
+
+	s.Stop(ctx)
+	select {
+	case <-s.ShouldQuiesce():
+		t.Fatal("expected Stop to be a no-op")
+	default:
+	}
+	if err := s.RunTask(ctx, func(context.Context) {}); err != nil {
+		t.Fatal(err)
+	}
+}
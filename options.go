@@ -0,0 +1,206 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+// A Logger is used by the stopper to log what it is doing, such as which
+// tasks it is waiting for while quiescing. It is satisfied by *log.Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+type stdLogger struct{}
+
+func (stdLogger) Printf(format string, v ...interface{}) {
+	log.Printf(format, v...)
+}
+
+type optionLogger struct {
+	logger Logger
+}
+
+func (ol optionLogger) apply(stopper *Stopper) {
+	stopper.logger = ol.logger
+}
+
+// WithLogger is an option which makes the stopper log to the given logger
+// instead of the standard logger of package log.
+func WithLogger(logger Logger) Option {
+	return optionLogger{logger}
+}
+
+// A Tracer starts a tracing span for each task run by the stopper.
+type Tracer interface {
+	// StartSpan starts a span for the named task, and returns a context
+	// carrying the span, which is passed to the task, and a function which
+	// finishes the span when the task has run.
+	StartSpan(ctx context.Context, task string) (context.Context, func())
+}
+
+type optionTracer struct {
+	tracer Tracer
+}
+
+func (ot optionTracer) apply(stopper *Stopper) {
+	stopper.tracer = ot.tracer
+}
+
+// WithTracer is an option which makes the stopper run every task in a span
+// started by the tracer. The span encloses any task interceptors.
+func WithTracer(tracer Tracer) Option {
+	return optionTracer{tracer}
+}
+
+// Metrics receives the events a stopper reports for each task run, to be
+// exported to a metrics system.
+type Metrics interface {
+	// TaskStarted is called when a task begins to run.
+	TaskStarted(TaskInfo)
+	// TaskEnded is called when a task has run, with its outcome.
+	TaskEnded(TaskEnd)
+}
+
+type optionMetrics struct {
+	metrics Metrics
+}
+
+func (om optionMetrics) apply(stopper *Stopper) {
+	stopper.metrics = om.metrics
+}
+
+// WithMetrics is an option which makes the stopper report every task run to
+// the given Metrics. It is called like the handlers of OnTaskStart and
+// OnTaskEnd, which it does not replace.
+func WithMetrics(metrics Metrics) Option {
+	return optionMetrics{metrics}
+}
+
+// Config describes how a stopper is configured by the options passed to
+// NewStopper, with the defaults filled in for the options not given.
+type Config struct {
+	Name    string  // see WithName
+	Clock   Clock   // see WithClock
+	Logger  Logger  // see WithLogger
+	Tracer  Tracer  // see WithTracer, or nil
+	Metrics Metrics // see WithMetrics, or nil
+
+	MaxTasks             int  // see MaxTasks, or zero if unlimited
+	MaxTasksWait         bool // see MaxTasks
+	TrackTasks           bool // see TrackTasks
+	UntrackedTasks       bool // see UntrackedTasks
+	CancelTasksOnQuiesce bool // see CancelTasksOnQuiesce
+	CancelTasksOnForce   bool // always set, see CancelTasksOnForce
+	CollectTaskErrors    bool // see CollectTaskErrors
+	FailFast             bool // see WithFailFast
+
+	BackgroundGracePeriod time.Duration // see BackgroundGracePeriod
+	CloserTimeout         time.Duration // see CloserTimeout, or zero
+	DrainDelay            time.Duration // see WithDrainDelay
+	DrainHookTimeout      time.Duration // see DrainHookTimeout
+	FlushTimeout          time.Duration // see FlushTimeout, or zero
+	SlowTaskThreshold     time.Duration // see WithSlowTaskThreshold, or zero
+	ShutdownWarnInterval  time.Duration // see ShutdownWarnInterval
+	ShutdownTimeout       time.Duration // see ShutdownTimeout
+	WatchdogInterval      time.Duration // see Watchdog, or zero
+}
+
+// Config returns the configuration of the stopper.
+func (s *Stopper) Config() Config {
+	c := Config{
+		Name:    s.name,
+		Clock:   s.clock,
+		Logger:  s.logger,
+		Tracer:  s.tracer,
+		Metrics: s.metrics,
+
+		MaxTasks:             s.maxTasks,
+		MaxTasksWait:         s.maxTasksWait,
+		TrackTasks:           s.trackTasks,
+		UntrackedTasks:       s.untracked,
+		CancelTasksOnQuiesce: s.cancelOnQuiesce,
+		CancelTasksOnForce:   true,
+		CollectTaskErrors:    s.collectErrors,
+		FailFast:             s.failFast,
+
+		BackgroundGracePeriod: s.backgroundGrace,
+		CloserTimeout:         s.closerTimeout,
+		DrainDelay:            s.drainDelay,
+		DrainHookTimeout:      s.drainTimeout,
+		FlushTimeout:          s.flushTimeout,
+		SlowTaskThreshold:     s.slowTasks.threshold,
+		ShutdownWarnInterval:  s.shutdownWarn,
+		ShutdownTimeout:       s.shutdownTimeout,
+	}
+	if s.watchdog.report != nil {
+		c.WatchdogInterval = s.watchdog.interval
+	}
+	return c
+}
+
+// validate returns an error describing the first invalid option the stopper
+// was created with, if any.
+func (s *Stopper) validate() error {
+	switch {
+	case s.envErr != nil:
+		return s.envErr
+	case s.clock == nil:
+		return errors.New("stop: WithClock: nil clock")
+	case s.logger == nil:
+		return errors.New("stop: WithLogger: nil logger")
+	case s.maxTasks < 0:
+		return errors.Errorf("stop: MaxTasks: negative limit %d", s.maxTasks)
+	case s.backgroundGrace < 0:
+		return errors.Errorf("stop: BackgroundGracePeriod: negative duration %s", s.backgroundGrace)
+	case s.closerTimeout < 0:
+		return errors.Errorf("stop: CloserTimeout: negative duration %s", s.closerTimeout)
+	case s.drainDelay < 0:
+		return errors.Errorf("stop: WithDrainDelay: negative duration %s", s.drainDelay)
+	case s.drainTimeout < 0:
+		return errors.Errorf("stop: DrainHookTimeout: negative duration %s", s.drainTimeout)
+	case s.flushTimeout < 0:
+		return errors.Errorf("stop: FlushTimeout: negative duration %s", s.flushTimeout)
+	case s.watchdog.report != nil && s.watchdog.interval <= 0:
+		return errors.Errorf("stop: Watchdog: non-positive interval %s", s.watchdog.interval)
+	case s.quiesceProgress.report != nil && s.quiesceProgress.interval <= 0:
+		return errors.Errorf("stop: OnQuiesceProgress: non-positive interval %s", s.quiesceProgress.interval)
+	case s.shutdownWarn <= 0:
+		return errors.Errorf("stop: ShutdownWarnInterval: non-positive interval %s", s.shutdownWarn)
+	case s.shutdownTimeout <= 0:
+		return errors.Errorf("stop: ShutdownTimeout: non-positive duration %s", s.shutdownTimeout)
+	case s.slowTasks.threshold < 0:
+		return errors.Errorf("stop: WithSlowTaskThreshold: negative threshold %s", s.slowTasks.threshold)
+	case s.slowTasks.threshold > 0 && s.slowTasks.threshold < 2*time.Nanosecond:
+		// Tasks are checked every threshold/2, which must not round to zero.
+		return errors.Errorf("stop: WithSlowTaskThreshold: threshold %s is shorter than 2ns", s.slowTasks.threshold)
+	case s.ignoredCancellation.after < 0:
+		return errors.Errorf("stop: DetectIgnoredCancellation: negative duration %s", s.ignoredCancellation.after)
+	case s.blockingTasks.after < 0:
+		return errors.Errorf("stop: ReportBlockingTasks: negative duration %s", s.blockingTasks.after)
+	}
+	for i, b := range s.latencies.bounds {
+		if b <= 0 || i > 0 && b <= s.latencies.bounds[i-1] {
+			return errors.Errorf("stop: TrackTaskLatencies: buckets %v are not positive and increasing", s.latencies.bounds)
+		}
+	}
+	return nil
+}
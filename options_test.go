@@ -0,0 +1,160 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+	"github.com/birkelund/stop/stoptest"
+
+	"golang.org/x/net/context"
+)
+
+func TestNewInvalidOptions(t *testing.T) {
+	testCases := []struct {
+		opt      stop.Option
+		expected string
+	}{
+		{stop.WithClock(nil), "WithClock"},
+		{stop.WithLogger(nil), "WithLogger"},
+		{stop.MaxTasks(-1, false), "MaxTasks"},
+		{stop.WithDrainDelay(-time.Second), "WithDrainDelay"},
+		{stop.CloserTimeout(-time.Second), "CloserTimeout"},
+		{stop.FlushTimeout(-time.Second), "FlushTimeout"},
+		{stop.ShutdownTimeout(0), "ShutdownTimeout"},
+		{stop.Watchdog(0, func(stop.WedgedWorker) {}), "Watchdog"},
+		{stop.WithSlowTaskThreshold(time.Nanosecond, nil), "WithSlowTaskThreshold"},
+		{stop.TrackTaskLatencies(time.Second, time.Millisecond), "TrackTaskLatencies"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			s, err := stop.New(tc.opt)
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Fatalf("expected error mentioning %s, got %v", tc.expected, err)
+			}
+			if s != nil {
+				t.Errorf("expected no stopper, got %s", s)
+			}
+		})
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected NewStopper to panic on invalid options")
+		}
+	}()
+	stop.NewStopper(stop.MaxTasks(-1, false))
+}
+
+func TestStopperConfig(t *testing.T) {
+	c := stoptest.NewFakeClock(time.Unix(0, 0))
+	s := stop.NewStopper(
+		stop.WithName("server"),
+		stop.WithClock(c),
+		stop.MaxTasks(10, true),
+		stop.DrainHookTimeout(time.Second),
+	)
+	defer s.Stop(context.Background())
+
+	cfg := s.Config()
+	if cfg.Name != "server" || cfg.Clock != c || cfg.MaxTasks != 10 || !cfg.MaxTasksWait ||
+		cfg.DrainHookTimeout != time.Second {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	if cfg.Logger == nil || !cfg.TrackTasks || cfg.BackgroundGracePeriod != stop.DefaultBackgroundGracePeriod {
+		t.Errorf("expected defaults in config %+v", cfg)
+	}
+}
+
+func TestStopperWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	s := stop.NewStopper(stop.WithLogger(log.New(&buf, "", 0)))
+	s.Stop(context.Background())
+	if !strings.Contains(buf.String(), "stop has been called") {
+		t.Errorf("expected stop to be logged, got %q", buf.String())
+	}
+}
+
+type testTracer struct {
+	mu    sync.Mutex
+	spans []string
+}
+
+func (tt *testTracer) StartSpan(ctx context.Context, task string) (context.Context, func()) {
+	return context.WithValue(ctx, ctxKey{}, task), func() {
+		tt.mu.Lock()
+		defer tt.mu.Unlock()
+		tt.spans = append(tt.spans, task)
+	}
+}
+
+func TestStopperWithTracer(t *testing.T) {
+	tracer := &testTracer{}
+	s := stop.NewStopper(stop.WithTracer(tracer))
+	ctx := context.Background()
+	var span interface{}
+	if err := s.RunTask(ctx, func(ctx context.Context) {
+		span = ctx.Value(ctxKey{})
+	}, stop.TaskName("traced")); err != nil {
+		t.Fatal(err)
+	}
+	s.Stop(ctx)
+	if span != "traced" {
+		t.Errorf("expected task to run in span, got %v", span)
+	}
+	if len(tracer.spans) != 1 || tracer.spans[0] != "traced" {
+		t.Errorf("expected one finished span, got %v", tracer.spans)
+	}
+}
+
+type testMetrics struct {
+	mu      sync.Mutex
+	started []string
+	ended   []stop.TaskEnd
+}
+
+func (tm *testMetrics) TaskStarted(info stop.TaskInfo) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.started = append(tm.started, info.Task)
+}
+
+func (tm *testMetrics) TaskEnded(end stop.TaskEnd) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.ended = append(tm.ended, end)
+}
+
+func TestStopperWithMetrics(t *testing.T) {
+	metrics := &testMetrics{}
+	s := stop.NewStopper(stop.WithMetrics(metrics))
+	ctx := context.Background()
+	if err := s.RunTask(ctx, func(context.Context) {}, stop.TaskName("measured")); err != nil {
+		t.Fatal(err)
+	}
+	s.Stop(ctx)
+	if len(metrics.started) != 1 || metrics.started[0] != "measured" {
+		t.Errorf("expected one started task, got %v", metrics.started)
+	}
+	if len(metrics.ended) != 1 || metrics.ended[0].Task != "measured" {
+		t.Errorf("expected one ended task, got %+v", metrics.ended)
+	}
+}
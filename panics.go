@@ -0,0 +1,116 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import "sync"
+
+// MaxRecentPanics is the number of recovered panics retained by a stopper for
+// RecentPanics.
+const MaxRecentPanics = 10
+
+// panicState holds the handlers of panics recovered by the stopper, and a
+// record of the panics. The handlers are set by the OnPanic and
+// OnPanicWithInfo options, and may be changed or added to while the stopper is
+// running.
+type panicState struct {
+	sync.Mutex
+	onPanic         func(interface{}) // see OnPanic and SetOnPanic
+	onPanicWithInfo func(PanicInfo)   // see OnPanicWithInfo
+	added           []func(PanicInfo) // see AddPanicHandler
+
+	counts map[string]int // recovered panics per task
+	recent []PanicInfo    // the last MaxRecentPanics panics, oldest first
+}
+
+// SetOnPanic replaces the panic handler set by the OnPanic option, for
+// instance once the error reporter of a service has been constructed after
+// the stopper. A nil handler removes it. The handler set by OnPanicWithInfo,
+// if any, still takes precedence.
+func (s *Stopper) SetOnPanic(handler func(interface{})) {
+	s.panics.Lock()
+	defer s.panics.Unlock()
+	s.panics.onPanic = handler
+}
+
+// AddPanicHandler installs an additional panic handler on a running stopper.
+// A recovered panic is passed to every added handler, in the order they were
+// added, as well as to the handler set by OnPanic or OnPanicWithInfo, if any,
+// so that reporters can be chained.
+func (s *Stopper) AddPanicHandler(handler func(PanicInfo)) {
+	s.panics.Lock()
+	defer s.panics.Unlock()
+	s.panics.added = append(s.panics.added, handler)
+}
+
+// PanicCounts returns the number of panics recovered by the stopper, keyed by
+// the name or call site of the panicking task, as in PanicInfo. This includes
+// panics handled by a task's own panic handler and panics returned as errors,
+// so that recovered panics don't go unnoticed.
+func (s *Stopper) PanicCounts() TaskMap {
+	s.panics.Lock()
+	defer s.panics.Unlock()
+	m := TaskMap{}
+	for task, n := range s.panics.counts {
+		m[task] = n
+	}
+	return m
+}
+
+// RecentPanics returns the last MaxRecentPanics panics recovered by the
+// stopper, most recent first.
+func (s *Stopper) RecentPanics() []PanicInfo {
+	s.panics.Lock()
+	defer s.panics.Unlock()
+	recent := make([]PanicInfo, len(s.panics.recent))
+	for i, info := range s.panics.recent {
+		recent[len(recent)-1-i] = info
+	}
+	return recent
+}
+
+// recordPanic records a recovered panic for PanicCounts and RecentPanics.
+func (s *Stopper) recordPanic(info PanicInfo) {
+	s.panics.Lock()
+	defer s.panics.Unlock()
+	if s.panics.counts == nil {
+		s.panics.counts = map[string]int{}
+	}
+	s.panics.counts[info.Task]++
+	if len(s.panics.recent) == MaxRecentPanics {
+		copy(s.panics.recent, s.panics.recent[1:])
+		s.panics.recent = s.panics.recent[:MaxRecentPanics-1]
+	}
+	s.panics.recent = append(s.panics.recent, info)
+}
+
+// reportPanic records the recovered panic and passes it to the panic
+// handlers of the stopper, and reports whether there were any.
+func (s *Stopper) reportPanic(info PanicInfo) bool {
+	s.recordPanic(info)
+
+	s.panics.Lock()
+	onPanic, onPanicWithInfo, added := s.panics.onPanic, s.panics.onPanicWithInfo, s.panics.added
+	s.panics.Unlock()
+
+	if onPanicWithInfo != nil {
+		onPanicWithInfo(info)
+	} else if onPanic != nil {
+		onPanic(info.Value)
+	}
+	for _, handler := range added {
+		handler(info)
+	}
+	return onPanic != nil || onPanicWithInfo != nil || len(added) > 0
+}
@@ -0,0 +1,107 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"testing"
+
+	"github.com/birkelund/stop"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperSetOnPanic(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	recovered := make(chan interface{}, 1)
+	s.SetOnPanic(func(r interface{}) { recovered <- r })
+	if err := s.RunAsyncTask(ctx, func(context.Context) {
+		panic("boom")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if r := <-recovered; r != "boom" {
+		t.Errorf("expected boom, got %v", r)
+	}
+}
+
+func TestStopperAddPanicHandler(t *testing.T) {
+	var calls []string
+	s := stop.NewStopper(stop.OnPanic(func(interface{}) {
+		calls = append(calls, "option")
+	}))
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	s.AddPanicHandler(func(info stop.PanicInfo) {
+		if info.Value != "boom" || info.Task != "panicky" || len(info.Stack) == 0 {
+			t.Errorf("unexpected panic info %+v", info)
+		}
+		calls = append(calls, "first")
+	})
+	s.AddPanicHandler(func(stop.PanicInfo) {
+		calls = append(calls, "second")
+	})
+	if err := s.RunTask(ctx, func(context.Context) {
+		panic("boom")
+	}, stop.TaskName("panicky")); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 3 || calls[0] != "option" || calls[1] != "first" || calls[2] != "second" {
+		t.Errorf("expected all handlers to be called in order, got %v", calls)
+	}
+}
+
+func TestStopperPanicCounts(t *testing.T) {
+	s := stop.NewStopper(stop.OnPanic(func(interface{}) {}))
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	for i := 0; i < stop.MaxRecentPanics+2; i++ {
+		i := i
+		if err := s.RunTask(ctx, func(context.Context) {
+			panic(i)
+		}, stop.TaskName("panicky")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Panics returned as errors are counted as well.
+	if err := s.RunTask(ctx, func(context.Context) {
+		panic("error")
+	}, stop.TaskName("erring"), stop.PanicsAsErrors()); err == nil {
+		t.Fatal("expected error")
+	}
+
+	counts := s.PanicCounts()
+	if counts["panicky"] != stop.MaxRecentPanics+2 || counts["erring"] != 1 {
+		t.Errorf("unexpected panic counts:\n%s", counts)
+	}
+	if status := s.Status(); status.Panics["erring"] != 1 {
+		t.Errorf("expected panics in status, got %+v", status.Panics)
+	}
+
+	recent := s.RecentPanics()
+	if len(recent) != stop.MaxRecentPanics {
+		t.Fatalf("expected %d recent panics, got %d", stop.MaxRecentPanics, len(recent))
+	}
+	if recent[0].Value != "error" || recent[0].Task != "erring" || len(recent[0].Stack) == 0 {
+		t.Errorf("unexpected most recent panic %+v", recent[0])
+	}
+	if last := recent[len(recent)-1]; last.Value != 3 {
+		t.Errorf("expected oldest retained panic to be 3, got %v", last.Value)
+	}
+}
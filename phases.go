@@ -0,0 +1,101 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+// phases holds the channels closed at the transitions of Stop between those
+// signaled by ShouldDrain, ShouldQuiesce, ShouldStop and IsStopped. The
+// channels are closed with the stopper lock held.
+type phases struct {
+	drained     chan struct{}
+	quiesced    chan struct{}
+	workersDone chan struct{}
+	closersDone chan struct{}
+}
+
+func makePhases() phases {
+	return phases{
+		drained:     make(chan struct{}),
+		quiesced:    make(chan struct{}),
+		workersDone: make(chan struct{}),
+		closersDone: make(chan struct{}),
+	}
+}
+
+// closeAll closes the channels which are not closed yet.
+func (p phases) closeAll() {
+	closePhase(p.drained)
+	closePhase(p.quiesced)
+	closePhase(p.workersDone)
+	closePhase(p.closersDone)
+}
+
+// closePhase closes ch, unless it is closed already.
+func closePhase(ch chan struct{}) {
+	select {
+	case <-ch:
+	default:
+		close(ch)
+	}
+}
+
+// Drained returns a channel which is closed once the stopper has drained:
+// the drain hooks have returned and the drain delay has elapsed (see
+// OnDrain and WithDrainDelay), right before it begins to quiesce.
+//
+// Together with ShouldDrain, ShouldQuiesce, ShouldStop and IsStopped, the
+// channels returned by Drained, Quiesced, WorkersDone and ClosersDone are
+// closed in this order as Stop progresses:
+//
+//	ShouldDrain    draining has begun
+//	Drained        draining is done
+//	ShouldQuiesce  quiescing has begun
+//	Quiesced       the running tasks have finished
+//	ShouldStop     the workers are told to exit
+//	WorkersDone    the workers have exited
+//	ClosersDone    the closers have been closed
+//	IsStopped      the stopper has stopped
+func (s *Stopper) Drained() <-chan struct{} {
+	if s == nil {
+		return nil
+	}
+	return s.phases.drained
+}
+
+// Quiesced returns a channel which is closed once the running tasks have
+// finished after the stopper began to quiesce.
+func (s *Stopper) Quiesced() <-chan struct{} {
+	if s == nil {
+		return nil
+	}
+	return s.phases.quiesced
+}
+
+// WorkersDone returns a channel which is closed once the workers have exited
+// after ShouldStop() was closed.
+func (s *Stopper) WorkersDone() <-chan struct{} {
+	if s == nil {
+		return nil
+	}
+	return s.phases.workersDone
+}
+
+// ClosersDone returns a channel which is closed once the closers have been
+// closed, right before IsStopped() is closed.
+func (s *Stopper) ClosersDone() <-chan struct{} {
+	if s == nil {
+		return nil
+	}
+	return s.phases.closersDone
+}
@@ -0,0 +1,91 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"testing"
+
+	"github.com/birkelund/stop"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperPhases(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+
+	releaseTask := make(chan struct{})
+	if err := s.RunAsyncTask(ctx, func(context.Context) { <-releaseTask }); err != nil {
+		t.Fatal(err)
+	}
+	releaseWorker := make(chan struct{})
+	if err := s.RunWorker(ctx, func(context.Context) { <-releaseWorker }); err != nil {
+		t.Fatal(err)
+	}
+	releaseCloser := make(chan struct{})
+	s.AddCloser(stop.CloserFn(func() { <-releaseCloser }))
+
+	closed := func(ch <-chan struct{}) bool {
+		select {
+		case <-ch:
+			return true
+		default:
+			return false
+		}
+	}
+	// expect waits for the channels up to the current phase to be closed, and
+	// checks that the later ones are not.
+	phases := []<-chan struct{}{
+		s.ShouldDrain(), s.Drained(), s.ShouldQuiesce(), s.Quiesced(),
+		s.ShouldStop(), s.WorkersDone(), s.ClosersDone(), s.IsStopped(),
+	}
+	expect := func(n int) {
+		t.Helper()
+		for _, ch := range phases[:n] {
+			<-ch
+		}
+		for i, ch := range phases[n:] {
+			if closed(ch) {
+				t.Fatalf("expected phase %d not to be reached", n+i)
+			}
+		}
+	}
+
+	expect(0)
+	go s.Stop(ctx)
+	expect(3)
+	close(releaseTask)
+	expect(5)
+	close(releaseWorker)
+	expect(6)
+	close(releaseCloser)
+	expect(8)
+
+	if err := s.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if closed(s.Drained()) || closed(s.ClosersDone()) {
+		t.Fatal("expected phases to be re-armed by Reset")
+	}
+	s.Stop(ctx)
+	<-s.ClosersDone()
+}
+
+func TestStopperPhasesNil(t *testing.T) {
+	var s *stop.Stopper
+	if s.Drained() != nil || s.Quiesced() != nil || s.WorkersDone() != nil || s.ClosersDone() != nil {
+		t.Fatal("expected nil channels from nil stopper")
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+// A Stage is a step of a Pipeline.
+type Stage struct {
+	// Name is the task name of the workers of the stage (see TaskName).
+	Name string
+	// Workers is the number of workers of the stage, at least 1. Each worker
+	// is a task for the lifetime of the pipeline, so it counts against
+	// MaxTasks until the pipeline has drained.
+	Workers int
+	// Buffer is the capacity of the channel feeding the stage.
+	Buffer int
+	// Func handles an item, passing the items for the next stage to emit. The
+	// items emitted by the last stage are discarded.
+	Func func(ctx context.Context, item interface{}, emit func(interface{}))
+}
+
+// A Pipeline passes items through stages connected by channels, each stage
+// run by workers which are tasks of a stopper.
+//
+// When the stopper begins to quiesce, the pipeline stops accepting items and
+// drains the stages in order, from source to sink: each stage handles the
+// items left in its channel, and once its workers have exited, the next stage
+// is closed. Since the workers are tasks, Quiesce waits for the pipeline to
+// drain, and no item accepted by Submit is dropped. The workers are exempt
+// from CancelTasksOnQuiesce, so the stages drain with a live context, and
+// from slow and stuck task reporting, as they run for as long as the
+// pipeline.
+type Pipeline struct {
+	s    *Stopper
+	in   chan interface{} // channel feeding the first stage
+	done chan struct{}    // closed once the last stage has drained
+
+	mu     sync.RWMutex // held for reading while submitting
+	closed bool         // true once in is closed
+}
+
+// NewPipeline starts the workers of a pipeline with the given stages. If the
+// stopper is quiescing, it returns an error, along with a pipeline which
+// accepts no items.
+func (s *Stopper) NewPipeline(ctx context.Context, stages ...Stage) (*Pipeline, error) {
+	if len(stages) == 0 {
+		return nil, errors.New("stop: NewPipeline: no stages")
+	}
+	chans := make([]chan interface{}, len(stages)+1)
+	for i, stage := range stages {
+		chans[i] = make(chan interface{}, stage.Buffer)
+	}
+	p := &Pipeline{s: s, in: chans[0], done: make(chan struct{})}
+
+	// Start the stages from the sink, so that if the stopper begins to
+	// quiesce meanwhile, the stages which were started can drain.
+	var err error
+	for i := len(stages) - 1; i >= 0; i-- {
+		stage, in, out := stages[i], chans[i], chans[i+1]
+		emit := func(item interface{}) { out <- item }
+		if out == nil {
+			emit = func(interface{}) {}
+		}
+
+		var wg sync.WaitGroup
+		workers := stage.Workers
+		if workers < 1 {
+			workers = 1
+		}
+		for j := 0; j < workers && err == nil; j++ {
+			wg.Add(1)
+			if err = s.RunAsyncTask(ctx, func(ctx context.Context) {
+				defer wg.Done()
+				for item := range in {
+					stage.Func(ctx, item, emit)
+				}
+			}, TaskName(stage.Name), optionLongLived{}); err != nil {
+				wg.Done()
+			}
+		}
+
+		// Close the next stage once the workers of this one have exited.
+		go func() {
+			wg.Wait()
+			if out != nil {
+				close(out)
+			} else {
+				close(p.done)
+			}
+		}()
+	}
+
+	s.AfterQuiesce(p.close)
+	if err != nil {
+		p.close()
+	}
+	return p, err
+}
+
+// Submit passes item to the first stage, waiting for room in its channel. It
+// returns an error, and the item is not passed, if ctx is done first or if
+// the stopper is quiescing.
+func (p *Pipeline) Submit(ctx context.Context, item interface{}) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	select {
+	case <-p.s.ShouldQuiesce():
+		return p.s.errUnavailable()
+	default:
+	}
+	if p.closed {
+		return p.s.errUnavailable()
+	}
+	select {
+	case p.in <- item:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Done returns a channel which is closed once the pipeline has drained.
+func (p *Pipeline) Done() <-chan struct{} {
+	return p.done
+}
+
+// close stops accepting items, once the items being submitted are passed, so
+// that the stages drain.
+func (p *Pipeline) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		p.closed = true
+		close(p.in)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+	"github.com/birkelund/stop/stoptest"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+func TestPipeline(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var sunk []int
+	p, err := s.NewPipeline(ctx,
+		stop.Stage{
+			Name:    "double",
+			Workers: 4,
+			Buffer:  10,
+			Func: func(ctx context.Context, item interface{}, emit func(interface{})) {
+				// Slow down the stage, so that items are in flight when the
+				// stopper quiesces.
+				time.Sleep(time.Millisecond)
+				emit(item.(int) * 2)
+			},
+		},
+		stop.Stage{
+			Name: "sink",
+			Func: func(ctx context.Context, item interface{}, emit func(interface{})) {
+				mu.Lock()
+				defer mu.Unlock()
+				sunk = append(sunk, item.(int))
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 100
+	for i := 0; i < n; i++ {
+		if err := p.Submit(ctx, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tasks := s.RunningTasks(); tasks["double"] != 4 || tasks["sink"] != 1 {
+		t.Errorf("expected stage workers to run as tasks, got %v", tasks)
+	}
+
+	// Stop drains the pipeline, so no item is dropped.
+	s.Stop(ctx)
+	<-p.Done()
+	sort.Ints(sunk)
+	if len(sunk) != n {
+		t.Fatalf("expected %d items, got %d", n, len(sunk))
+	}
+	for i, v := range sunk {
+		if v != 2*i {
+			t.Fatalf("expected item %d to be %d, got %d", i, 2*i, v)
+		}
+	}
+
+	if err := p.Submit(ctx, 0); !errors.Is(err, stop.ErrUnavailable) {
+		t.Fatalf("expected %v, got %v", stop.ErrUnavailable, err)
+	}
+}
+
+func TestPipelineAfterStop(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+	s.Stop(ctx)
+
+	p, err := s.NewPipeline(ctx, stop.Stage{
+		Func: func(context.Context, interface{}, func(interface{})) {},
+	})
+	if !errors.Is(err, stop.ErrUnavailable) {
+		t.Fatalf("expected %v, got %v", stop.ErrUnavailable, err)
+	}
+	<-p.Done()
+	if err := p.Submit(ctx, 0); !errors.Is(err, stop.ErrUnavailable) {
+		t.Fatalf("expected %v, got %v", stop.ErrUnavailable, err)
+	}
+
+	if _, err := s.NewPipeline(ctx); err == nil {
+		t.Fatal("expected error for pipeline without stages")
+	}
+}
+
+func TestPipelineCancelTasksOnQuiesce(t *testing.T) {
+	c := stoptest.NewFakeClock(time.Unix(0, 0))
+	slow := make(chan stop.SlowTask, 1)
+	s := stop.NewStopper(
+		stop.WithClock(c),
+		stop.CancelTasksOnQuiesce(),
+		stop.WithSlowTaskThreshold(time.Minute, func(st stop.SlowTask) { slow <- st }),
+	)
+	ctx := context.Background()
+
+	started := make(chan struct{})
+	var mu sync.Mutex
+	var errs []error
+	p, err := s.NewPipeline(ctx, stop.Stage{
+		Name:   "sink",
+		Buffer: 3,
+		Func: func(ctx context.Context, item interface{}, emit func(interface{})) {
+			if item.(int) == 0 {
+				close(started)
+				<-s.ShouldQuiesce()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, ctx.Err())
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := p.Submit(ctx, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-started
+
+	// The worker has been running for longer than the threshold, but is not
+	// reported as slow.
+	c.Advance(2 * time.Minute)
+	select {
+	case st := <-slow:
+		t.Errorf("stage worker reported as slow: %+v", st.TaskInfo)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	// The stage drains with a live context.
+	s.Stop(ctx)
+	<-p.Done()
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 items, got %d", len(errs))
+	}
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("item %d: expected a live context, got %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// DefaultPoolIdleTimeout is the time an elastic pool worker above the minimum
+// worker count waits for a new task before exiting.
+const DefaultPoolIdleTimeout = 10 * time.Second
+
+type poolTask struct {
+	ctx  context.Context
+	key  taskKey
+	f    func(context.Context)
+	opts taskOptions
+}
+
+// A WorkerPool runs tasks on a set of long-lived workers registered with a
+// Stopper, instead of spawning a goroutine per task like RunAsyncTask does.
+//
+// Tasks submitted to the pool are tracked by the stopper exactly like async
+// tasks, so Quiesce waits for queued tasks to run. The pool workers exit when
+// the stopper signals ShouldStop.
+type WorkerPool struct {
+	s     *Stopper
+	queue chan poolTask
+
+	min, max    int
+	idleTimeout time.Duration
+
+	mu struct {
+		sync.Mutex
+		workers int // number of live workers
+		waiting int // number of submitters blocked on a busy pool
+	}
+}
+
+// A PoolOption can be passed to NewWorkerPool.
+type PoolOption interface {
+	apply(*WorkerPool)
+}
+
+type optionPoolSize int
+
+func (ops optionPoolSize) apply(p *WorkerPool) {
+	p.min, p.max = int(ops), int(ops)
+}
+
+// PoolSize is an option which makes the pool run a fixed number of workers.
+// This is the default, using runtime.NumCPU() workers, if neither PoolSize
+// or ElasticPool is given.
+func PoolSize(n int) PoolOption {
+	return optionPoolSize(n)
+}
+
+type optionElasticPool struct {
+	min, max    int
+	idleTimeout time.Duration
+}
+
+func (oep optionElasticPool) apply(p *WorkerPool) {
+	p.min, p.max, p.idleTimeout = oep.min, oep.max, oep.idleTimeout
+}
+
+// ElasticPool is an option which makes the pool start min workers and grow to
+// at most max workers when tasks are submitted faster than they are handled.
+// Workers above min exit after being idle for idleTimeout. A zero idleTimeout
+// means DefaultPoolIdleTimeout.
+func ElasticPool(min, max int, idleTimeout time.Duration) PoolOption {
+	if idleTimeout == 0 {
+		idleTimeout = DefaultPoolIdleTimeout
+	}
+	return optionElasticPool{min, max, idleTimeout}
+}
+
+// NewWorkerPool returns a WorkerPool whose workers are registered with the
+// stopper.
+func (s *Stopper) NewWorkerPool(ctx context.Context, options ...PoolOption) *WorkerPool {
+	p := &WorkerPool{
+		s:     s,
+		queue: make(chan poolTask),
+	}
+	PoolSize(runtime.NumCPU()).apply(p)
+
+	for _, opt := range options {
+		opt.apply(p)
+	}
+
+	if p.max < 1 {
+		p.max = 1
+	}
+	if p.min > p.max {
+		p.min = p.max
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := 0; i < p.min; i++ {
+		p.startWorkerLocked(ctx, nil)
+	}
+	return p
+}
+
+// NumWorkers returns the number of live workers in the pool.
+func (p *WorkerPool) NumWorkers() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.mu.workers
+}
+
+// RunAsyncTask queues function f to be run by one of the pool workers. If all
+// workers are busy and the pool cannot grow, RunAsyncTask blocks until a
+// worker becomes available or ctx is done. It returns an error when the
+// Stopper is quiescing, in which case the function is not executed.
+func (p *WorkerPool) RunAsyncTask(
+	ctx context.Context, f func(context.Context), opts ...TaskOption,
+) error {
+	o := makeTaskOptions(opts)
+	key := p.s.makeTaskKey(&o)
+	if err := p.s.runPrelude(key, &o); err != nil {
+		return newTaskError(key, &o, err)
+	}
+
+	t := poolTask{ctx, key, f, o}
+
+	// Prefer handing the task to an idle worker.
+	select {
+	case p.queue <- t:
+		return nil
+	default:
+	}
+
+	p.mu.Lock()
+	if p.mu.workers < p.max {
+		p.startWorkerLocked(ctx, &t)
+		p.mu.Unlock()
+		return nil
+	}
+	p.mu.waiting++
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		p.mu.waiting--
+		p.mu.Unlock()
+	}()
+
+	select {
+	case p.queue <- t:
+		return nil
+	case <-ctx.Done():
+		p.s.runPostlude(key)
+		return newTaskError(key, &o, ctx.Err())
+	}
+}
+
+func (p *WorkerPool) startWorkerLocked(ctx context.Context, first *poolTask) {
+	// Workers are only started from NewWorkerPool and while a task is
+	// holding up quiescing, so the only error is creating a pool on a stopped
+	// stopper. Such a pool never runs any tasks. Unlike RunWorker, the worker
+	// is started while quiescing, as the task it is started for has been
+	// admitted already.
+	err := p.s.runWorker(ctx, func(ctx context.Context) {
+		if first != nil {
+			p.run(*first)
+		}
+
+		var idle <-chan time.Time
+		for {
+			if p.idleTimeout > 0 {
+				idle = p.s.clock.After(p.idleTimeout)
+			}
+
+			select {
+			case t := <-p.queue:
+				p.run(t)
+			case <-idle:
+				if p.retire() {
+					return
+				}
+			case <-p.s.ShouldStop():
+				p.mu.Lock()
+				p.mu.workers--
+				p.mu.Unlock()
+				return
+			}
+		}
+	}, nil, true)
+	if err == nil {
+		p.mu.workers++
+	}
+}
+
+// retire removes an idle worker from the pool if there are more than the
+// minimum number of workers and no submitters are waiting for a worker.
+func (p *WorkerPool) retire() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.mu.workers <= p.min || p.mu.waiting > 0 {
+		return false
+	}
+	p.mu.workers--
+	return true
+}
+
+func (p *WorkerPool) run(t poolTask) {
+	defer p.s.recoverTask(t.ctx, t.key, &t.opts, nil)
+	defer p.s.runPostlude(t.key)
+
+	p.s.runTaskFunc(t.ctx, t.key, &t.opts, true, t.f)
+}
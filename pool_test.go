@@ -0,0 +1,120 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+func TestWorkerPoolFixed(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+
+	const poolSize = 3
+	const numTasks = poolSize * 10
+	p := s.NewWorkerPool(ctx, stop.PoolSize(poolSize))
+
+	var mu sync.Mutex
+	concurrency, peakConcurrency := 0, 0
+	var ran int32
+
+	for i := 0; i < numTasks; i++ {
+		if err := p.RunAsyncTask(ctx, func(context.Context) {
+			mu.Lock()
+			concurrency++
+			if concurrency > peakConcurrency {
+				peakConcurrency = concurrency
+			}
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			concurrency--
+			mu.Unlock()
+			atomic.AddInt32(&ran, 1)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := p.NumWorkers(); n != poolSize {
+		t.Errorf("expected %d workers, got %d", poolSize, n)
+	}
+
+	// Stop must wait for all submitted tasks to run.
+	s.Stop(ctx)
+
+	if n := atomic.LoadInt32(&ran); n != numTasks {
+		t.Errorf("expected %d tasks to run, got %d", numTasks, n)
+	}
+	if peakConcurrency > poolSize {
+		t.Errorf("expected peak concurrency <= %d, got %d", poolSize, peakConcurrency)
+	}
+	if n := p.NumWorkers(); n != 0 {
+		t.Errorf("expected all workers to exit, got %d", n)
+	}
+
+	if err := p.RunAsyncTask(ctx, func(context.Context) {}); !errors.Is(err, stop.ErrStopped) {
+		t.Fatalf("expected %v; got %v", stop.ErrStopped, err)
+	}
+}
+
+func TestWorkerPoolElastic(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	p := s.NewWorkerPool(ctx, stop.ElasticPool(1, 4, 10*time.Millisecond))
+	if n := p.NumWorkers(); n != 1 {
+		t.Fatalf("expected 1 worker, got %d", n)
+	}
+
+	block := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		if err := p.RunAsyncTask(ctx, func(context.Context) { <-block }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := p.NumWorkers(); n != 4 {
+		t.Fatalf("expected pool to grow to 4 workers, got %d", n)
+	}
+
+	// The pool is at capacity; the next submission blocks until ctx is done.
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := p.RunAsyncTask(cctx, func(context.Context) {}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v; got %v", context.DeadlineExceeded, err)
+	}
+
+	close(block)
+
+	// Idle workers above the minimum retire.
+	SucceedsSoon(t, func() error {
+		if n := p.NumWorkers(); n != 1 {
+			return errors.Errorf("expected pool to shrink to 1 worker, got %d", n)
+		}
+		return nil
+	})
+	if n := s.NumTasks(); n != 0 {
+		t.Fatalf("expected no running tasks, got %d", n)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import "time"
+
+// A QuiesceProgress is reported periodically while Quiesce waits for running
+// tasks (see OnQuiesceProgress).
+type QuiesceProgress struct {
+	// Remaining is the number of tasks still running.
+	Remaining int
+	// Tasks is the count of running tasks keyed by name or call site.
+	Tasks TaskMap
+	// Elapsed is the time Quiesce has been waiting.
+	Elapsed time.Duration
+}
+
+type optionQuiesceProgress struct {
+	interval time.Duration
+	report   func(QuiesceProgress)
+}
+
+func (oqp optionQuiesceProgress) apply(stopper *Stopper) {
+	stopper.quiesceProgress = oqp
+}
+
+// OnQuiesceProgress is an option which makes Quiesce, and hence Stop, call
+// report every interval for as long as it is waiting for running tasks, so
+// that a service can log what its shutdown is waiting on. The report function
+// is called from a separate goroutine and should not block.
+func OnQuiesceProgress(interval time.Duration, report func(QuiesceProgress)) Option {
+	return optionQuiesceProgress{interval, report}
+}
+
+// reportQuiesceProgress calls the progress report function every interval
+// until done is closed.
+func (s *Stopper) reportQuiesceProgress(start time.Time, done <-chan struct{}) {
+	ticker := s.clock.NewTicker(s.quiesceProgress.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.Chan():
+		case <-done:
+			return
+		}
+
+		s.mu.Lock()
+		progress := QuiesceProgress{
+			Remaining: s.NumTasks(),
+			Tasks:     s.runningTasksLocked(),
+			Elapsed:   s.clock.Now().Sub(start),
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-done:
+			return
+		default:
+		}
+		s.quiesceProgress.report(progress)
+	}
+}
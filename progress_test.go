@@ -0,0 +1,66 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+	"github.com/birkelund/stop/stoptest"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperOnQuiesceProgress(t *testing.T) {
+	c := stoptest.NewFakeClock(time.Unix(0, 0))
+	progress := make(chan stop.QuiesceProgress, 1)
+	s := stop.NewStopper(
+		stop.WithClock(c),
+		stop.OnQuiesceProgress(time.Second, func(p stop.QuiesceProgress) { progress <- p }),
+	)
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	for i := 0; i < 3; i++ {
+		if err := s.RunAsyncTask(ctx, func(context.Context) { <-release }, stop.TaskName("raft-apply")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	quiesced := make(chan struct{})
+	go func() {
+		s.Quiesce(ctx)
+		close(quiesced)
+	}()
+
+	// Wait for the progress reporter to start its ticker.
+	SucceedsSoon(t, func() error {
+		if c.Waiters() == 0 {
+			return errors.New("progress ticker not started")
+		}
+		return nil
+	})
+	c.Advance(time.Second)
+	p := <-progress
+	if p.Remaining != 3 || p.Tasks["raft-apply"] != 3 || p.Elapsed != time.Second {
+		t.Errorf("unexpected progress %+v", p)
+	}
+
+	close(release)
+	<-quiesced
+	s.Stop(ctx)
+}
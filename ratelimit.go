@@ -0,0 +1,133 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+// A RateLimiter limits the rate at which rate-limited tasks are started (see
+// RunRateLimitedTask), using a token bucket which holds up to burst tokens
+// and is refilled at a given rate. Each task takes one token, or the number
+// given by the Weight option.
+//
+// A RateLimiter is a Semaphore whose units are tokens: Release does nothing,
+// as tokens are only returned by the passing of time, as measured by the
+// clock of the stopper it was created by.
+type RateLimiter struct {
+	s     *Stopper
+	rate  float64 // tokens added per second
+	burst float64 // capacity of the bucket
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time // time tokens was last updated
+}
+
+// NewRateLimiter returns a RateLimiter allowing r tasks per second on
+// average, and bursts of up to burst tasks. The bucket starts full. A rate of
+// zero or less allows no more than the initial burst.
+func (s *Stopper) NewRateLimiter(r float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		s:      s,
+		rate:   r,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   s.clock.Now(),
+	}
+}
+
+// refillLocked adds the tokens accrued since the last update, and returns the
+// time until n tokens are available, or a negative duration if they never
+// will be.
+func (l *RateLimiter) refillLocked(n float64) time.Duration {
+	now := l.s.clock.Now()
+	if l.rate > 0 {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+	if l.tokens >= n {
+		return 0
+	}
+	if l.rate <= 0 || n > l.burst {
+		return -1
+	}
+	if d := time.Duration((n - l.tokens) / l.rate * float64(time.Second)); d > 0 {
+		return d
+	}
+	return 1
+}
+
+// Acquire implements the Semaphore interface. It returns an error without
+// blocking if n tokens will never be available.
+func (l *RateLimiter) Acquire(ctx context.Context, n int64) error {
+	for {
+		l.mu.Lock()
+		d := l.refillLocked(float64(n))
+		if d == 0 {
+			l.tokens -= float64(n)
+		}
+		l.mu.Unlock()
+		switch {
+		case d == 0:
+			return nil
+		case d < 0:
+			return errors.Errorf("rate limiter cannot supply %d tokens", n)
+		}
+
+		select {
+		case <-l.s.clock.After(d):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// TryAcquire implements the Semaphore interface.
+func (l *RateLimiter) TryAcquire(n int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.refillLocked(float64(n)) != 0 {
+		return false
+	}
+	l.tokens -= float64(n)
+	return true
+}
+
+// Release implements the Semaphore interface. It does nothing.
+func (l *RateLimiter) Release(n int64) {}
+
+// RunRateLimitedTask is like RunLimitedAsyncTaskWithSemaphore, but limits the
+// rate at which tasks are started rather than the number of tasks running
+// concurrently. If wait is true, it waits for the limiter to allow the task,
+// giving up if ctx is done or the stopper begins to quiesce; otherwise it
+// returns ErrThrottled right away. The MaxQueueDepth, AcquireTimeout and
+// Weight options apply as for limited tasks.
+func (s *Stopper) RunRateLimitedTask(
+	ctx context.Context, limiter *RateLimiter, wait bool, f func(context.Context),
+	opts ...TaskOption,
+) error {
+	o := makeTaskOptions(opts)
+	key := s.makeTaskKey(&o)
+	return newTaskError(key, &o, s.runLimitedAsyncTask(ctx, limiter, wait, f, key, &o))
+}
@@ -0,0 +1,94 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+	"github.com/birkelund/stop/stoptest"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperRunRateLimitedTask(t *testing.T) {
+	c := stoptest.NewFakeClock(time.Unix(0, 0))
+	s := stop.NewStopper(stop.WithClock(c))
+	ctx := context.Background()
+	lim := s.NewRateLimiter(10, 2)
+
+	ran := make(chan struct{}, 10)
+	f := func(context.Context) { ran <- struct{}{} }
+
+	// The burst is allowed right away.
+	for i := 0; i < 2; i++ {
+		if err := s.RunRateLimitedTask(ctx, lim, false, f); err != nil {
+			t.Fatal(err)
+		}
+		<-ran
+	}
+	if err := s.RunRateLimitedTask(ctx, lim, false, f); !errors.Is(err, stop.ErrThrottled) {
+		t.Fatalf("expected %v, got %v", stop.ErrThrottled, err)
+	}
+
+	// A waiting task is started once a token has accrued.
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.RunRateLimitedTask(ctx, lim, true, f)
+	}()
+	SucceedsSoon(t, func() error {
+		if c.Waiters() == 0 {
+			return errors.New("task not waiting")
+		}
+		return nil
+	})
+	select {
+	case <-ran:
+		t.Fatal("expected task to wait for a token")
+	default:
+	}
+	c.Advance(100 * time.Millisecond)
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+	<-ran
+
+	// A waiting task gives up when the stopper quiesces.
+	go func() {
+		errCh <- s.RunRateLimitedTask(ctx, lim, true, f)
+	}()
+	SucceedsSoon(t, func() error {
+		if c.Waiters() == 0 {
+			return errors.New("task not waiting")
+		}
+		return nil
+	})
+	s.Stop(ctx)
+	if err := <-errCh; !errors.Is(err, stop.ErrUnavailable) {
+		t.Fatalf("expected %v, got %v", stop.ErrUnavailable, err)
+	}
+}
+
+func TestRateLimiterTooManyTokens(t *testing.T) {
+	s := stop.NewStopper()
+	defer s.Stop(context.Background())
+
+	lim := s.NewRateLimiter(10, 2)
+	if err := lim.Acquire(context.Background(), 3); err == nil {
+		t.Fatal("expected error for tokens exceeding burst")
+	}
+}
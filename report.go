@@ -0,0 +1,108 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"sort"
+	"time"
+)
+
+// A StopReport describes where the time went during Stop, so that shutdown
+// latency can be tracked and budgeted. The phases are consecutive and add up
+// to about Total.
+type StopReport struct {
+	// Drain is the time spent draining before quiescing (see WithDrainDelay).
+	Drain time.Duration
+	// Quiesce is the time spent waiting for running tasks.
+	Quiesce time.Duration
+	// BackgroundTasks is the time spent waiting for background tasks, at most
+	// the background grace period.
+	BackgroundTasks time.Duration
+	// Workers is the time spent waiting for workers to exit.
+	Workers time.Duration
+	// Flush is the time spent waiting for the flushers (see AddFlusher).
+	Flush time.Duration
+	// Closers lists the closers in the order they were closed.
+	Closers []CloserTiming
+	// Tasks lists the tasks which were running when the stopper began to
+	// quiesce, by name or call site, slowest first. The time of a task is from
+	// the start of quiescing until the last task of that name finished.
+	Tasks []TaskTiming
+	// TaskErrors lists the errors returned by async tasks during the run, if
+	// collected (see CollectTaskErrors).
+	TaskErrors []error
+	// Total is the time from the call to Stop until the stopper stopped.
+	Total time.Duration
+}
+
+// A CloserTiming is the time a closer took to close. The closer is
+// identified as in CloserOverrun. Err is the *CloserError, if the closer was
+// added as an io.Closer and returned an error.
+type CloserTiming struct {
+	Closer   string
+	Duration time.Duration
+	Err      error
+}
+
+// A TaskTiming is the time a task took to finish once quiescing began.
+type TaskTiming struct {
+	Task     string
+	Duration time.Duration
+}
+
+type optionStopReportHandler func(StopReport)
+
+func (osrh optionStopReportHandler) apply(stopper *Stopper) {
+	stopper.onStopReport = osrh
+}
+
+// OnStopReport is an option which sets a handler called with the StopReport
+// once Stop has stopped the stopper. It is not called if Stop recovers from a
+// panic.
+func OnStopReport(handler func(StopReport)) Option {
+	return optionStopReportHandler(handler)
+}
+
+// StopReport returns the report of the last Stop, or the zero StopReport if
+// the stopper has not stopped.
+func (s *Stopper) StopReport() StopReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mu.stopReport
+}
+
+// recordDrainLocked records that a task finished while quiescing.
+func (s *Stopper) recordDrainLocked(key taskKey) {
+	if s.mu.drainTimes == nil {
+		s.mu.drainTimes = map[string]time.Duration{}
+	}
+	s.mu.drainTimes[key.String()] = s.clock.Now().Sub(s.mu.quiesceStart)
+}
+
+// drainTimesLocked returns the times recorded by recordDrainLocked, slowest
+// first.
+func (s *Stopper) drainTimesLocked() []TaskTiming {
+	var timings []TaskTiming
+	for task, d := range s.mu.drainTimes {
+		timings = append(timings, TaskTiming{task, d})
+	}
+	sort.Slice(timings, func(i, j int) bool {
+		if timings[i].Duration != timings[j].Duration {
+			return timings[i].Duration > timings[j].Duration
+		}
+		return timings[i].Task < timings[j].Task
+	})
+	return timings
+}
@@ -0,0 +1,65 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperStopReport(t *testing.T) {
+	reports := make(chan stop.StopReport, 1)
+	s := stop.NewStopper(stop.OnStopReport(func(r stop.StopReport) { reports <- r }))
+	ctx := context.Background()
+
+	if err := s.AddComponent("db", stop.CloserFn(func() { time.Sleep(10 * time.Millisecond) })); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RunAsyncTask(ctx, func(context.Context) {
+		<-s.ShouldQuiesce()
+		time.Sleep(20 * time.Millisecond)
+	}, stop.TaskName("flush")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RunAsyncTask(ctx, func(context.Context) { <-s.ShouldQuiesce() }, stop.TaskName("fast")); err != nil {
+		t.Fatal(err)
+	}
+
+	if r := s.StopReport(); r.Total != 0 {
+		t.Fatalf("expected empty report before Stop, got %+v", r)
+	}
+	s.Stop(ctx)
+	r := <-reports
+
+	if r.Quiesce < 20*time.Millisecond {
+		t.Errorf("expected quiesce to take at least 20ms, got %s", r.Quiesce)
+	}
+	if len(r.Tasks) != 2 || r.Tasks[0].Task != "flush" || r.Tasks[1].Task != "fast" {
+		t.Errorf("expected flush to be the slowest task, got %+v", r.Tasks)
+	}
+	if len(r.Closers) != 1 || r.Closers[0].Closer != "db" || r.Closers[0].Duration < 10*time.Millisecond {
+		t.Errorf("unexpected closer timings %+v", r.Closers)
+	}
+	if r.Total < r.Quiesce+r.BackgroundTasks+r.Workers+r.Closers[0].Duration {
+		t.Errorf("expected total to cover the phases, got %+v", r)
+	}
+	if sr := s.StopReport(); sr.Total != r.Total {
+		t.Errorf("expected StopReport to return the last report, got %+v", sr)
+	}
+}
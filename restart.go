@@ -0,0 +1,47 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+// ErrRestarting is the error wrapped in the StopError returned by Wait when
+// the process is stopping to make way for a new one (see OnRestart).
+var ErrRestarting = errors.New("restarting")
+
+type optionRestart func(context.Context) error
+
+func (or optionRestart) apply(stopper *Stopper) {
+	stopper.restart = or
+}
+
+// OnRestart is an option which enables restart mode, for daemons performing
+// classic zero-downtime restarts: when Wait receives SIGHUP, it calls restart
+// and, if that succeeds, stops the stopper, draining first (see
+// WithDrainDelay and OnDrain), and returns a StopError wrapping ErrRestarting.
+// The signals passed to Wait, such as SIGTERM, still stop the stopper as
+// usual.
+//
+// The restart function is called before the stopper begins to drain. It
+// should start the new process, for instance by re-executing the binary and
+// handing over its listening sockets (see HandOff and HandOffOnRestart), and
+// return once the new process is ready to take over. If it returns an error,
+// the old process keeps serving.
+func OnRestart(restart func(context.Context) error) Option {
+	return optionRestart(restart)
+}
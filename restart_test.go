@@ -0,0 +1,90 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+
+	"github.com/birkelund/stop"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperWaitRestart(t *testing.T) {
+	// Keep SIGHUP from terminating the test before Wait has registered for it.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	restarts := make(chan struct{}, 1)
+	succeed := make(chan struct{})
+	s := stop.NewStopper(stop.OnRestart(func(context.Context) error {
+		select {
+		case restarts <- struct{}{}:
+		default:
+		}
+		select {
+		case <-succeed:
+			return nil
+		default:
+			return errors.New("new process failed to start")
+		}
+	}))
+	ctx := context.Background()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.Wait(ctx, func(ctx context.Context) {
+			t.Error("unexpected interrupt")
+		}, stop.DefaultSignals)
+	}()
+
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Failed restarts leave the stopper running.
+	SucceedsSoon(t, func() error {
+		if err := p.Signal(syscall.SIGHUP); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-restarts:
+			return nil
+		default:
+			return errors.New("waiting for restart")
+		}
+	})
+	select {
+	case <-s.ShouldDrain():
+		t.Fatal("expected stopper to keep running after failed restart")
+	default:
+	}
+
+	close(succeed)
+	if err := p.Signal(syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	err = <-errCh
+	var se stop.StopError
+	if !errors.As(err, &se) || se.Err != stop.ErrRestarting {
+		t.Fatalf("expected restart, got %v", err)
+	}
+	<-s.IsStopped()
+}
@@ -0,0 +1,121 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"math/rand"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// RetryOptions controls how Retry retries a function.
+//
+// The delay before the first retry is InitialBackoff. Each retry multiplies
+// the delay by Multiplier, up to MaxBackoff. Each delay is randomized by up
+// to RandomizationFactor of it in either direction, so that processes
+// retrying the same operation spread out.
+//
+// If MaxRetries is positive, Retry gives up after that many retries. If
+// MaxDuration is positive, Retry gives up once that much time has passed
+// since the first call.
+type RetryOptions struct {
+	InitialBackoff      time.Duration
+	MaxBackoff          time.Duration
+	Multiplier          float64
+	RandomizationFactor float64
+
+	MaxRetries  int
+	MaxDuration time.Duration
+}
+
+// DefaultRetryOptions is used by Retry for zero fields of the given
+// RetryOptions, except the limits.
+var DefaultRetryOptions = RetryOptions{
+	InitialBackoff: 50 * time.Millisecond,
+	MaxBackoff:     time.Second,
+	Multiplier:     2,
+}
+
+func (ro RetryOptions) withDefaults() RetryOptions {
+	if ro.InitialBackoff <= 0 {
+		ro.InitialBackoff = DefaultRetryOptions.InitialBackoff
+	}
+	if ro.MaxBackoff <= 0 {
+		ro.MaxBackoff = DefaultRetryOptions.MaxBackoff
+	}
+	if ro.MaxBackoff < ro.InitialBackoff {
+		ro.MaxBackoff = ro.InitialBackoff
+	}
+	if ro.Multiplier < 1 {
+		ro.Multiplier = DefaultRetryOptions.Multiplier
+	}
+	if ro.RandomizationFactor > 1 {
+		ro.RandomizationFactor = 1
+	}
+	return ro
+}
+
+// Retry calls fn until it returns nil, with exponential backoff between the
+// calls, as described by opts. If fn still fails when Retry gives up, the
+// last error is returned.
+//
+// Retry stops retrying as soon as ctx is done, returning ctx.Err(), or the
+// stopper begins to quiesce, returning ErrQuiescing or ErrStopped, so that
+// retry loops don't hold up shutdown. If the stopper is already quiescing, fn
+// is not called. The stopper may be nil, in which case only ctx is watched.
+func Retry(ctx context.Context, s *Stopper, opts RetryOptions, fn func(context.Context) error) error {
+	opts = opts.withDefaults()
+	clock := RealClock
+	if s != nil {
+		clock = s.clock
+	}
+
+	start := clock.Now()
+	backoff := opts.InitialBackoff
+	for retries := 0; ; retries++ {
+		select {
+		case <-s.ShouldQuiesce():
+			return s.errUnavailable()
+		default:
+		}
+
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		if opts.MaxRetries > 0 && retries >= opts.MaxRetries ||
+			opts.MaxDuration > 0 && clock.Now().Sub(start) >= opts.MaxDuration {
+			return err
+		}
+
+		wait := backoff
+		if f := opts.RandomizationFactor; f > 0 {
+			wait = time.Duration(float64(wait) * (1 + f*(2*rand.Float64()-1)))
+		}
+		select {
+		case <-clock.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.ShouldQuiesce():
+			return s.errUnavailable()
+		}
+
+		backoff = time.Duration(float64(backoff) * opts.Multiplier)
+		if backoff > opts.MaxBackoff {
+			backoff = opts.MaxBackoff
+		}
+	}
+}
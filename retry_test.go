@@ -0,0 +1,109 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+	"github.com/birkelund/stop/stoptest"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+func TestRetry(t *testing.T) {
+	s := stop.NewStopper()
+	defer s.Stop(context.Background())
+	ctx := context.Background()
+	opts := stop.RetryOptions{InitialBackoff: time.Microsecond, MaxBackoff: time.Millisecond}
+
+	calls := 0
+	if err := stop.Retry(ctx, s, opts, func(context.Context) error {
+		if calls++; calls < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	}); err != nil || calls != 3 {
+		t.Fatalf("expected success on third call, got %v after %d calls", err, calls)
+	}
+
+	errFailed := errors.New("failed")
+	calls = 0
+	opts.MaxRetries = 2
+	if err := stop.Retry(ctx, s, opts, func(context.Context) error {
+		calls++
+		return errFailed
+	}); err != errFailed || calls != 3 {
+		t.Fatalf("expected last error after 3 calls, got %v after %d calls", err, calls)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	c := stoptest.NewFakeClock(time.Unix(0, 0))
+	s := stop.NewStopper(stop.WithClock(c))
+	ctx := context.Background()
+
+	calls := make(chan struct{}, 10)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- stop.Retry(ctx, s, stop.RetryOptions{
+			InitialBackoff: time.Second,
+			MaxBackoff:     3 * time.Second,
+		}, func(context.Context) error {
+			calls <- struct{}{}
+			return errors.New("failed")
+		})
+	}()
+
+	// The delays are 1s, 2s and then capped at 3s.
+	for _, d := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second} {
+		<-calls
+		SucceedsSoon(t, func() error {
+			if c.Waiters() == 0 {
+				return errors.New("not waiting")
+			}
+			return nil
+		})
+		c.Advance(d - time.Nanosecond)
+		select {
+		case <-calls:
+			t.Fatalf("expected retry to wait %s", d)
+		default:
+		}
+		c.Advance(time.Nanosecond)
+	}
+	<-calls
+
+	// Quiescing aborts the retry loop.
+	s.Stop(ctx)
+	if err := <-errCh; !errors.Is(err, stop.ErrUnavailable) {
+		t.Fatalf("expected %v, got %v", stop.ErrUnavailable, err)
+	}
+}
+
+func TestRetryContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := stop.Retry(ctx, nil, stop.RetryOptions{}, func(context.Context) error {
+		calls++
+		cancel()
+		return errors.New("failed")
+	})
+	if err != context.Canceled || calls != 1 {
+		t.Fatalf("expected %v after 1 call, got %v after %d calls", context.Canceled, err, calls)
+	}
+}
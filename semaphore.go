@@ -0,0 +1,405 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+// A Semaphore limits the number of concurrently running limited async tasks
+// (see RunLimitedAsyncTaskWithSemaphore). It is satisfied by
+// *semaphore.Weighted from golang.org/x/sync/semaphore.
+//
+// The stopper counts the tasks waiting for each semaphore (see MaxQueueDepth)
+// in a map keyed by the semaphore, so implementations must be comparable, as
+// pointer and channel types are. Submitting a task with a semaphore of a
+// non-comparable type, such as a struct holding a slice, panics.
+type Semaphore interface {
+	// Acquire blocks until n units are available or ctx is done.
+	Acquire(ctx context.Context, n int64) error
+	// TryAcquire acquires n units without blocking, reporting whether it
+	// succeeded.
+	TryAcquire(n int64) bool
+	// Release returns n units.
+	Release(n int64)
+}
+
+type chanSemaphore chan struct{}
+
+// ChanSemaphore returns a Semaphore using the given channel, whose capacity is
+// the number of available units, as used by RunLimitedAsyncTask. It makes no
+// fairness guarantees; use a WeightedSemaphore with the Fair option for FIFO
+// acquisition.
+func ChanSemaphore(ch chan struct{}) Semaphore {
+	return chanSemaphore(ch)
+}
+
+func (cs chanSemaphore) Acquire(ctx context.Context, n int64) error {
+	for i := int64(0); i < n; i++ {
+		select {
+		case cs <- struct{}{}:
+		case <-ctx.Done():
+			cs.Release(i)
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (cs chanSemaphore) TryAcquire(n int64) bool {
+	for i := int64(0); i < n; i++ {
+		select {
+		case cs <- struct{}{}:
+		default:
+			cs.Release(i)
+			return false
+		}
+	}
+	return true
+}
+
+func (cs chanSemaphore) Release(n int64) {
+	for i := int64(0); i < n; i++ {
+		<-cs
+	}
+}
+
+// A WeightedSemaphore is a Semaphore with a fixed number of units which can be
+// acquired in arbitrary amounts. Units are handed to waiters as soon as enough
+// become available, so a large request may wait while smaller ones proceed,
+// unless the semaphore is created with the Fair option.
+type WeightedSemaphore struct {
+	size int64
+	fair bool
+
+	mu      sync.Mutex
+	cur     int64
+	waiters list.List // of *semaphoreWaiter
+}
+
+type semaphoreWaiter struct {
+	n     int64
+	ready chan struct{} // closed when the units have been acquired
+}
+
+// A SemaphoreOption can be passed to NewWeightedSemaphore.
+type SemaphoreOption interface {
+	apply(*WeightedSemaphore)
+}
+
+type optionFair struct{}
+
+func (optionFair) apply(ws *WeightedSemaphore) {
+	ws.fair = true
+}
+
+// Fair is an option which makes the semaphore hand out units in the order
+// they were requested. A waiter is only granted units once all earlier
+// waiters have been, and TryAcquire fails while there are waiters, so old
+// requests are not starved by a stream of newer ones. This comes at the cost
+// of leaving units idle while the first waiter needs more than are available.
+func Fair() SemaphoreOption {
+	return optionFair{}
+}
+
+// NewWeightedSemaphore returns a WeightedSemaphore with n units.
+func NewWeightedSemaphore(n int64, opts ...SemaphoreOption) *WeightedSemaphore {
+	ws := &WeightedSemaphore{size: n}
+	for _, opt := range opts {
+		opt.apply(ws)
+	}
+	return ws
+}
+
+// Acquire implements the Semaphore interface. It returns an error without
+// blocking if n exceeds the size of the semaphore.
+func (ws *WeightedSemaphore) Acquire(ctx context.Context, n int64) error {
+	ws.mu.Lock()
+	if ws.availableLocked(n) {
+		ws.cur += n
+		ws.mu.Unlock()
+		return nil
+	}
+	if n > ws.size {
+		ws.mu.Unlock()
+		return errors.Errorf("cannot acquire %d units of semaphore of size %d", n, ws.size)
+	}
+
+	w := &semaphoreWaiter{n: n, ready: make(chan struct{})}
+	elem := ws.waiters.PushBack(w)
+	ws.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		ws.mu.Lock()
+		select {
+		case <-w.ready:
+			// Acquired after ctx was done; keep the units rather than
+			// reporting failure after the fact.
+			ws.mu.Unlock()
+			return nil
+		default:
+		}
+		ws.waiters.Remove(elem)
+		ws.notifyWaitersLocked()
+		ws.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// TryAcquire implements the Semaphore interface.
+func (ws *WeightedSemaphore) TryAcquire(n int64) bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.availableLocked(n) {
+		ws.cur += n
+		return true
+	}
+	return false
+}
+
+// Release implements the Semaphore interface.
+func (ws *WeightedSemaphore) Release(n int64) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.cur -= n
+	if ws.cur < 0 {
+		panic("semaphore released more units than held")
+	}
+	ws.notifyWaitersLocked()
+}
+
+// resize changes the number of units of the semaphore.
+func (ws *WeightedSemaphore) resize(n int64) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.size = n
+	ws.notifyWaitersLocked()
+}
+
+// availableLocked reports whether n units can be acquired without waiting.
+func (ws *WeightedSemaphore) availableLocked(n int64) bool {
+	if ws.fair && ws.waiters.Len() > 0 {
+		return false
+	}
+	return ws.cur+n <= ws.size
+}
+
+func (ws *WeightedSemaphore) notifyWaitersLocked() {
+	for elem := ws.waiters.Front(); elem != nil; {
+		next := elem.Next()
+		w := elem.Value.(*semaphoreWaiter)
+		if ws.cur+w.n <= ws.size {
+			ws.cur += w.n
+			ws.waiters.Remove(elem)
+			close(w.ready)
+		} else if ws.fair {
+			return
+		}
+		elem = next
+	}
+}
+
+// ThrottleInfo describes a limited async task which could not acquire its
+// semaphore immediately.
+type ThrottleInfo struct {
+	// Task identifies the task by name or call site.
+	Task string
+	// Semaphore is the semaphore the task was limited by. For
+	// RunLimitedAsyncTask, it is ChanSemaphore of the given channel.
+	Semaphore Semaphore
+	// Waited is the time spent waiting for the semaphore, which is zero if
+	// the task was rejected without waiting.
+	Waited time.Duration
+	// Err is nil if the task acquired the semaphore after waiting, and
+	// otherwise the error returned to the caller, such as ErrThrottled or
+	// ErrQueueFull.
+	Err error
+}
+
+type optionThrottleHandler func(ThrottleInfo)
+
+func (oth optionThrottleHandler) apply(stopper *Stopper) {
+	stopper.onThrottle = oth
+}
+
+// OnThrottle is an option which sets a handler called whenever a limited
+// async task is rejected or has to wait because its semaphore is exhausted.
+// The handler is called synchronously by the submitting goroutine, after the
+// task was rejected or acquired the semaphore, and should not block.
+func OnThrottle(handler func(ThrottleInfo)) Option {
+	return optionThrottleHandler(handler)
+}
+
+// RunLimitedAsyncTaskWithSemaphore is like RunLimitedAsyncTask, but uses the
+// given Semaphore to limit the number of tasks that are run concurrently. Each
+// task acquires one unit of the semaphore, or the number given by the Weight
+// option.
+func (s *Stopper) RunLimitedAsyncTaskWithSemaphore(
+	ctx context.Context, sem Semaphore, wait bool, f func(context.Context), opts ...TaskOption,
+) error {
+	o := makeTaskOptions(opts)
+	key := s.makeTaskKey(&o)
+	return newTaskError(key, &o, s.runLimitedAsyncTask(ctx, sem, wait, f, key, &o))
+}
+
+func (s *Stopper) runLimitedAsyncTask(
+	ctx context.Context, sem Semaphore, wait bool, f func(context.Context),
+	key taskKey, o *taskOptions,
+) error {
+	if o.priority < HighPriority {
+		select {
+		case <-s.ShouldQuiesce():
+			return s.errUnavailable()
+		default:
+		}
+	}
+
+	// Wait for permission to run from the semaphore.
+	if !sem.TryAcquire(o.weight) {
+		if err := s.throttle(ctx, sem, wait, key, o); err != nil {
+			return err
+		}
+	}
+
+	// Check for canceled context: it's possible to get the semaphore even
+	// if the context is canceled.
+	select {
+	case <-ctx.Done():
+		sem.Release(o.weight)
+		return &phaseError{PhaseThrottle, ctx.Err()}
+	default:
+	}
+
+	if err := s.runPrelude(key, o); err != nil {
+		sem.Release(o.weight)
+		return err
+	}
+
+	//ctx, span := tracing.ForkCtxSpan(ctx, key.String())
+
+	s.goTask(func() {
+		defer s.recoverTask(ctx, key, o, nil)
+		defer s.runPostlude(key)
+		defer sem.Release(o.weight)
+		//defer tracing.FinishSpan(span)
+
+		s.runTaskFunc(ctx, key, o, true, f)
+	})
+	return nil
+}
+
+// throttle handles a limited async task which could not acquire its semaphore
+// immediately, by either rejecting it or waiting for the semaphore, and
+// reports the outcome to the throttle handler, if any.
+func (s *Stopper) throttle(
+	ctx context.Context, sem Semaphore, wait bool, key taskKey, o *taskOptions,
+) error {
+	start := s.clock.Now()
+	var err error
+	switch {
+	case !wait:
+		err = ErrThrottled
+	case !s.enqueue(sem, o.maxQueueDepth):
+		err = ErrQueueFull
+	default:
+		s.logger.Printf("stopper throttling task from %s due to semaphore", key)
+		err = s.acquire(ctx, sem, o.weight, o.acquireTimeout)
+		s.dequeue(sem)
+	}
+
+	if s.onThrottle != nil {
+		s.onThrottle(ThrottleInfo{
+			Task:      key.String(),
+			Semaphore: sem,
+			Waited:    s.clock.Now().Sub(start),
+			Err:       err,
+		})
+	}
+	if err != nil && err == ctx.Err() {
+		// The caller gave up waiting, which is not a refusal by the stopper.
+		return &phaseError{PhaseThrottle, err}
+	}
+	return err
+}
+
+// acquire acquires n units of the semaphore, giving up with ErrUnavailable if
+// the stopper begins to quiesce, with ErrThrottled if timeout is positive and
+// expires, or with ctx.Err() if ctx is done.
+func (s *Stopper) acquire(
+	ctx context.Context, sem Semaphore, n int64, timeout time.Duration,
+) error {
+	acquireCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var expired <-chan time.Time
+	if timeout > 0 {
+		expired = s.clock.After(timeout)
+	}
+	var timedOut int32
+	go func() {
+		select {
+		case <-s.ShouldQuiesce():
+			cancel()
+		case <-expired:
+			atomic.StoreInt32(&timedOut, 1)
+			cancel()
+		case <-acquireCtx.Done():
+		}
+	}()
+
+	if err := sem.Acquire(acquireCtx, n); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if atomic.LoadInt32(&timedOut) == 1 {
+			return ErrThrottled
+		}
+		if acquireCtx.Err() != nil {
+			return s.errUnavailable()
+		}
+		return err
+	}
+	return nil
+}
+
+// enqueue registers a submission waiting for the semaphore, unless max is
+// positive and there are already max submissions waiting.
+func (s *Stopper) enqueue(sem Semaphore, max int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if max > 0 && s.mu.queued[sem] >= max {
+		return false
+	}
+	s.mu.queued[sem]++
+	return true
+}
+
+// dequeue unregisters a submission previously registered with enqueue.
+func (s *Stopper) dequeue(sem Semaphore) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.mu.queued[sem]--; s.mu.queued[sem] == 0 {
+		delete(s.mu.queued, sem)
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+// countingSemaphore wraps a Semaphore and counts the units held.
+type countingSemaphore struct {
+	stop.Semaphore
+	held int64
+}
+
+func (cs *countingSemaphore) Acquire(ctx context.Context, n int64) error {
+	if err := cs.Semaphore.Acquire(ctx, n); err != nil {
+		return err
+	}
+	atomic.AddInt64(&cs.held, n)
+	return nil
+}
+
+func (cs *countingSemaphore) TryAcquire(n int64) bool {
+	if !cs.Semaphore.TryAcquire(n) {
+		return false
+	}
+	atomic.AddInt64(&cs.held, n)
+	return true
+}
+
+func (cs *countingSemaphore) Release(n int64) {
+	atomic.AddInt64(&cs.held, -n)
+	cs.Semaphore.Release(n)
+}
+
+func TestStopperRunLimitedAsyncTaskWithSemaphore(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+
+	sem := &countingSemaphore{Semaphore: stop.ChanSemaphore(make(chan struct{}, 1))}
+
+	block := make(chan struct{})
+	if err := s.RunLimitedAsyncTaskWithSemaphore(ctx, sem, false, func(context.Context) {
+		<-block
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt64(&sem.held); n != 1 {
+		t.Fatalf("expected 1 unit held, got %d", n)
+	}
+
+	if err := s.RunLimitedAsyncTaskWithSemaphore(
+		ctx, sem, false, func(context.Context) {},
+	); !errors.Is(err, stop.ErrThrottled) {
+		t.Fatalf("expected %v; got %v", stop.ErrThrottled, err)
+	}
+
+	// A waiting submission gives up when the stopper quiesces.
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.RunLimitedAsyncTaskWithSemaphore(ctx, sem, true, func(context.Context) {})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	go s.Stop(ctx)
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, stop.ErrQuiescing) {
+			t.Fatalf("expected %v; got %v", stop.ErrQuiescing, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected waiting submission to give up")
+	}
+
+	close(block)
+	<-s.IsStopped()
+	SucceedsSoon(t, func() error {
+		if n := atomic.LoadInt64(&sem.held); n != 0 {
+			return errors.Errorf("expected all units released, got %d held", n)
+		}
+		return nil
+	})
+}
+
+func TestStopperWeightedLimitedAsyncTask(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	sem := stop.NewWeightedSemaphore(4)
+	block := make(chan struct{})
+	heavy := func(context.Context) { <-block }
+
+	if err := s.RunLimitedAsyncTaskWithSemaphore(ctx, sem, false, heavy, stop.Weight(3)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RunLimitedAsyncTaskWithSemaphore(
+		ctx, sem, false, heavy, stop.Weight(2),
+	); !errors.Is(err, stop.ErrThrottled) {
+		t.Fatalf("expected %v; got %v", stop.ErrThrottled, err)
+	}
+	if err := s.RunLimitedAsyncTaskWithSemaphore(ctx, sem, false, heavy); err != nil {
+		t.Fatal(err)
+	}
+
+	// A waiting task is started once enough units are released.
+	done := make(chan struct{})
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.RunLimitedAsyncTaskWithSemaphore(ctx, sem, true, func(context.Context) {
+			close(done)
+		}, stop.Weight(4))
+	}()
+	close(block)
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	if err := s.RunLimitedAsyncTaskWithSemaphore(
+		ctx, sem, true, heavy, stop.Weight(5),
+	); err == nil || errors.Is(err, stop.ErrUnavailable) {
+		t.Fatalf("expected error for weight exceeding the semaphore size, got %v", err)
+	}
+}
+
+func TestStopperLimitedAsyncTaskMaxQueueDepth(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	sem := make(chan struct{}, 1)
+	block := make(chan struct{})
+	if err := s.RunLimitedAsyncTask(ctx, sem, false, func(context.Context) {
+		<-block
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Of two waiting submissions, one is rejected.
+	errCh := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			errCh <- s.RunLimitedAsyncTask(
+				ctx, sem, true, func(context.Context) {}, stop.MaxQueueDepth(1),
+			)
+		}()
+	}
+
+	if err := <-errCh; !errors.Is(err, stop.ErrQueueFull) {
+		t.Fatalf("expected %v; got %v", stop.ErrQueueFull, err)
+	}
+	close(block)
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWeightedSemaphoreFair(t *testing.T) {
+	ctx := context.Background()
+	sem := stop.NewWeightedSemaphore(2, stop.Fair())
+	if !sem.TryAcquire(1) {
+		t.Fatal("expected to acquire 1 unit")
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		if err := sem.Acquire(ctx, 2); err != nil {
+			t.Error(err)
+		}
+		close(acquired)
+	}()
+
+	// Once the waiter is queued, the free unit is reserved for it.
+	SucceedsSoon(t, func() error {
+		if sem.TryAcquire(1) {
+			sem.Release(1)
+			return errors.New("expected TryAcquire to fail while a waiter is queued")
+		}
+		return nil
+	})
+
+	sem.Release(1)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("expected waiter to acquire the semaphore")
+	}
+	sem.Release(2)
+}
+
+func TestStopperLimitedAsyncTaskAcquireTimeout(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	sem := make(chan struct{}, 1)
+	block := make(chan struct{})
+	defer close(block)
+	if err := s.RunLimitedAsyncTask(ctx, sem, false, func(context.Context) {
+		<-block
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RunLimitedAsyncTask(
+		ctx, sem, true, func(context.Context) {}, stop.AcquireTimeout(10*time.Millisecond),
+	); !errors.Is(err, stop.ErrThrottled) {
+		t.Fatalf("expected %v; got %v", stop.ErrThrottled, err)
+	}
+}
+
+func TestStopperOnThrottle(t *testing.T) {
+	infoCh := make(chan stop.ThrottleInfo, 2)
+	s := stop.NewStopper(stop.OnThrottle(func(info stop.ThrottleInfo) {
+		infoCh <- info
+	}))
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	sem := make(chan struct{}, 1)
+	block := make(chan struct{})
+	if err := s.RunLimitedAsyncTask(ctx, sem, false, func(context.Context) {
+		<-block
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RunLimitedAsyncTask(
+		ctx, sem, false, func(context.Context) {}, stop.TaskName("rejected"),
+	); !errors.Is(err, stop.ErrThrottled) {
+		t.Fatalf("expected %v; got %v", stop.ErrThrottled, err)
+	}
+	info := <-infoCh
+	if info.Task != "rejected" || info.Err != stop.ErrThrottled || info.Semaphore != stop.ChanSemaphore(sem) {
+		t.Fatalf("unexpected throttle info %+v", info)
+	}
+
+	time.AfterFunc(10*time.Millisecond, func() { close(block) })
+	if err := s.RunLimitedAsyncTask(
+		ctx, sem, true, func(context.Context) {}, stop.TaskName("queued"),
+	); err != nil {
+		t.Fatal(err)
+	}
+	info = <-infoCh
+	if info.Task != "queued" || info.Err != nil || info.Waited <= 0 {
+		t.Fatalf("unexpected throttle info %+v", info)
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Sleep pauses for d, as measured by the clock of the stopper. It returns nil
+// once d has elapsed, or earlier, if ctx is done, ctx.Err(), or if the
+// stopper begins to quiesce, ErrQuiescing or ErrStopped. If the stopper is
+// already quiescing, it returns right away.
+func (s *Stopper) Sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-s.ShouldQuiesce():
+		return s.errUnavailable()
+	default:
+	}
+	select {
+	case <-s.clock.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.ShouldQuiesce():
+		return s.errUnavailable()
+	}
+}
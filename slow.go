@@ -0,0 +1,92 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// A SlowTask describes a task which has been running for longer than the
+// slow task threshold (see WithSlowTaskThreshold).
+type SlowTask struct {
+	TaskInfo
+	// Running is the time the task had been running for when detected.
+	Running time.Duration
+	// Stack is the stack trace of the goroutine running the task.
+	Stack []byte
+}
+
+type optionSlowTaskThreshold struct {
+	threshold time.Duration
+	report    func(SlowTask)
+}
+
+func (osth optionSlowTaskThreshold) apply(stopper *Stopper) {
+	stopper.slowTasks = osth
+	stopper.watched.m = map[*watchedTask]struct{}{}
+}
+
+// WithSlowTaskThreshold is an option which makes the stopper call report for
+// any task which has been running for longer than d, at any time, turning it
+// into a lightweight detector of runaway goroutines. Running tasks are
+// checked every d/2, so a task is reported once it has been running for
+// between d and 1.5d, and only once. A nil report logs the tasks instead. A d
+// of zero disables the detector, and a d of 1ns is rejected by New.
+//
+// Like DetectIgnoredCancellation, it makes running a task more expensive.
+func WithSlowTaskThreshold(d time.Duration, report func(SlowTask)) Option {
+	return optionSlowTaskThreshold{d, report}
+}
+
+// runSlowTaskDetector starts a worker which checks for slow tasks until the
+// stopper is stopping.
+func (s *Stopper) runSlowTaskDetector() {
+	s.RunWorker(context.Background(), func(context.Context) {
+		ticker := s.clock.NewTicker(s.slowTasks.threshold / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.Chan():
+				s.reportSlowTasks()
+			case <-s.ShouldStop():
+				return
+			}
+		}
+	})
+}
+
+func (s *Stopper) reportSlowTasks() {
+	now := s.clock.Now()
+	var slow []SlowTask
+	s.watched.Lock()
+	for t := range s.watched.m {
+		if running := now.Sub(t.start); !t.reported && running > s.slowTasks.threshold {
+			t.reported = true
+			slow = append(slow, SlowTask{t.info, running, goroutineStack(t.gid)})
+		}
+	}
+	s.watched.Unlock()
+
+	for _, st := range slow {
+		if report := s.slowTasks.report; report != nil {
+			report(st)
+		} else {
+			s.logger.Printf("task %s has been running for %s:\n%s", st.Task, st.Running, st.Stack)
+		}
+	}
+}
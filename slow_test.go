@@ -0,0 +1,96 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+	"github.com/birkelund/stop/stoptest"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperWithSlowTaskThreshold(t *testing.T) {
+	c := stoptest.NewFakeClock(time.Unix(0, 0))
+	slow := make(chan stop.SlowTask, 2)
+	s := stop.NewStopper(
+		stop.WithClock(c),
+		stop.WithSlowTaskThreshold(time.Minute, func(st stop.SlowTask) { slow <- st }),
+	)
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	if err := s.RunAsyncTask(ctx, func(context.Context) {
+		close(started)
+		<-release
+	}, stop.TaskName("runaway")); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	// Wait for the detector to start its ticker.
+	SucceedsSoon(t, func() error {
+		if c.Waiters() == 0 {
+			return errors.New("detector not started")
+		}
+		return nil
+	})
+
+	c.Advance(30 * time.Second)
+	c.Advance(30 * time.Second)
+	select {
+	case st := <-slow:
+		t.Fatalf("task reported before exceeding the threshold: %+v", st.TaskInfo)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	c.Advance(30 * time.Second)
+	st := <-slow
+	if st.Task != "runaway" || st.Running != 90*time.Second {
+		t.Errorf("unexpected slow task %s running for %s", st.Task, st.Running)
+	}
+	if !strings.Contains(string(st.Stack), "TestStopperWithSlowTaskThreshold") {
+		t.Errorf("expected the stack of the task, got:\n%s", st.Stack)
+	}
+
+	// A task is only reported once.
+	c.Advance(30 * time.Second)
+	select {
+	case st := <-slow:
+		t.Fatalf("task reported twice: %+v", st.TaskInfo)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestStopperWithSlowTaskThresholdEdges(t *testing.T) {
+	// A threshold of 1ns would check tasks with a ticker of zero interval.
+	if _, err := stop.New(stop.WithSlowTaskThreshold(time.Nanosecond, nil)); err == nil {
+		t.Error("expected a 1ns threshold to be rejected")
+	}
+
+	// 2ns is the shortest threshold, checked every 1ns.
+	c := stoptest.NewFakeClock(time.Unix(0, 0))
+	s, err := stop.New(stop.WithClock(c), stop.WithSlowTaskThreshold(2*time.Nanosecond, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Advance(time.Nanosecond)
+	s.Stop(context.Background())
+}
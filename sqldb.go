@@ -0,0 +1,121 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"database/sql"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// A DB is a *sql.DB added to a stopper with AddDB. Transactions begun through
+// it are tracked, so that the database is closed only once they have been
+// committed or rolled back.
+type DB struct {
+	*sql.DB
+
+	mu      sync.Mutex
+	txs     int
+	closing bool
+	idle    chan struct{} // closed once closing and txs is zero
+}
+
+// AddDB adds db to be closed with the closers (see AddIOCloser), and returns
+// a wrapper through which tasks should begin their transactions. When the
+// stopper closes the database, it refuses new transactions and waits for the
+// open ones to end before calling db.Close, so that tasks still finishing a
+// transaction, for instance a worker exiting after ShouldStop, don't see
+// their connections closed under them ("driver: bad connection"). The wait is
+// bounded by the closer timeout, if any (see CloserTimeout).
+func (s *Stopper) AddDB(db *sql.DB) *DB {
+	d := &DB{DB: db, idle: make(chan struct{})}
+	s.AddIOCloser(d)
+	return d
+}
+
+// Begin is like BeginTx with a background context and default options.
+func (db *DB) Begin() (*Tx, error) {
+	return db.BeginTx(context.Background(), nil)
+}
+
+// BeginTx begins a tracked transaction, as sql.DB.BeginTx. Once the database
+// is being closed, it returns sql.ErrConnDone.
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	db.mu.Lock()
+	if db.closing {
+		db.mu.Unlock()
+		return nil, sql.ErrConnDone
+	}
+	db.txs++
+	db.mu.Unlock()
+
+	tx, err := db.DB.BeginTx(ctx, opts)
+	if err != nil {
+		db.endTx()
+		return nil, err
+	}
+	return &Tx{Tx: tx, db: db}, nil
+}
+
+func (db *DB) endTx() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.txs--
+	if db.closing && db.txs == 0 {
+		close(db.idle)
+	}
+}
+
+// Close waits for the open transactions to end and closes the database. It
+// is called by the stopper, and may be called directly for a database which
+// must be closed earlier.
+func (db *DB) Close() error {
+	db.mu.Lock()
+	if !db.closing {
+		db.closing = true
+		if db.txs == 0 {
+			close(db.idle)
+		}
+	}
+	db.mu.Unlock()
+	<-db.idle
+	return db.DB.Close()
+}
+
+// A Tx is a transaction begun through a DB. Its end is recorded when Commit
+// or Rollback is called, so a transaction must always be ended explicitly,
+// even if its context is canceled.
+type Tx struct {
+	*sql.Tx
+	db   *DB
+	once sync.Once
+}
+
+// Commit commits the transaction, as sql.Tx.Commit.
+func (tx *Tx) Commit() error {
+	defer tx.end()
+	return tx.Tx.Commit()
+}
+
+// Rollback aborts the transaction, as sql.Tx.Rollback.
+func (tx *Tx) Rollback() error {
+	defer tx.end()
+	return tx.Tx.Rollback()
+}
+
+func (tx *Tx) end() {
+	tx.once.Do(tx.db.endTx)
+}
@@ -0,0 +1,132 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+// testDriver is a database driver supporting nothing but transactions.
+type testDriver struct {
+	open atomic.Int64
+}
+
+func (d *testDriver) Open(string) (driver.Conn, error) {
+	d.open.Add(1)
+	return testConn{d}, nil
+}
+
+type testConn struct {
+	d *testDriver
+}
+
+func (c testConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c testConn) Close() error {
+	c.d.open.Add(-1)
+	return nil
+}
+
+func (c testConn) Begin() (driver.Tx, error) {
+	return testTx{}, nil
+}
+
+type testTx struct{}
+
+func (testTx) Commit() error   { return nil }
+func (testTx) Rollback() error { return nil }
+
+var testSQLDriver = &testDriver{}
+
+func init() {
+	sql.Register("stoptest", testSQLDriver)
+}
+
+func TestAddDB(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+
+	sqlDB, err := sql.Open("stoptest", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := s.AddDB(sqlDB)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A worker still in a transaction after ShouldStop holds up the closing of
+	// the database.
+	tx, err = db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	committed := make(chan error, 1)
+	go func() {
+		<-release
+		committed <- tx.Commit()
+	}()
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		s.Stop(ctx)
+	}()
+	SucceedsSoon(t, func() error {
+		tx, err := db.Begin()
+		if err == nil {
+			_ = tx.Rollback()
+			return errors.New("began transaction")
+		}
+		if err != sql.ErrConnDone {
+			t.Fatalf("expected %v, got %v", sql.ErrConnDone, err)
+		}
+		return nil
+	})
+	select {
+	case <-stopped:
+		t.Fatal("stopped with an open transaction")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(release)
+	<-stopped
+	if err := <-committed; err != nil {
+		t.Fatal(err)
+	}
+	if n := testSQLDriver.open.Load(); n != 0 {
+		t.Fatalf("%d connections open", n)
+	}
+	if err := db.Ping(); err == nil {
+		t.Fatal("expected closed database")
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// A Status is a snapshot of the state of a stopper, as served by
+// StatusHandler.
+type Status struct {
+	// Name is the name given with WithName.
+	Name string `json:"name,omitempty"`
+	// State is one of "running", "paused", "draining", "quiescing",
+	// "stopping" and "stopped".
+	State string `json:"state"`
+	// Since is the time the stopper entered State, and TimeInState the time
+	// elapsed since then.
+	Since       time.Time `json:"since"`
+	TimeInState string    `json:"time_in_state"`
+
+	NumTasks        int     `json:"num_tasks"`
+	Tasks           TaskMap `json:"tasks"`
+	BackgroundTasks TaskMap `json:"background_tasks"`
+	NumWorkers      int     `json:"num_workers"`
+	NumClosers      int     `json:"num_closers"`
+
+	// Closer is the closer which Stop is closing, if any, identified as in
+	// CloserOverrun, and TimeInCloser the time elapsed since it was called, so
+	// that a closer which does not return can be told from a slow one.
+	Closer       string `json:"closer,omitempty"`
+	TimeInCloser string `json:"time_in_closer,omitempty"`
+
+	// Panics is the number of recovered panics per task (see PanicCounts).
+	Panics TaskMap `json:"panics,omitempty"`
+	// StopRequests is the number of calls to Stop (see StopRequests).
+	StopRequests int `json:"stop_requests,omitempty"`
+}
+
+// Status returns a snapshot of the state of the stopper.
+func (s *Stopper) Status() Status {
+	now := s.clock.Now()
+	panics := s.PanicCounts()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := Status{
+		Name:            s.name,
+		State:           s.stateLocked(),
+		Since:           s.mu.since,
+		TimeInState:     now.Sub(s.mu.since).String(),
+		NumTasks:        s.NumTasks(),
+		Tasks:           s.runningTasksLocked(),
+		BackgroundTasks: s.backgroundTasksLocked(),
+		NumWorkers:      s.mu.numWorkers,
+		NumClosers:      len(s.mu.closers),
+		Panics:          panics,
+		StopRequests:    s.mu.stopRequests,
+	}
+	if s.mu.closing != "" {
+		status.Closer = s.mu.closing
+		status.TimeInCloser = now.Sub(s.mu.closingSince).String()
+	}
+	return status
+}
+
+// StatusHandler returns an http.Handler serving the Status of the stopper as
+// JSON, for consumption by dashboards and scripts.
+func (s *Stopper) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(s.Status()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
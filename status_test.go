@@ -0,0 +1,94 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+	"github.com/birkelund/stop/stoptest"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperStatusHandler(t *testing.T) {
+	c := stoptest.NewFakeClock(time.Unix(0, 0))
+	s := stop.NewStopper(stop.WithName("kv-server"), stop.WithClock(c))
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	s.AddCloser(stop.CloserFn(func() {}))
+	block := make(chan struct{})
+	defer close(block)
+	if err := s.RunWorker(ctx, func(context.Context) { <-s.ShouldStop() }); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RunAsyncTask(ctx, func(context.Context) { <-block }, stop.TaskName("raft")); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Advance(time.Minute)
+	s.Pause()
+	c.Advance(time.Second)
+
+	w := httptest.NewRecorder()
+	s.StatusHandler().ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+
+	var status stop.Status
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Name != "kv-server" || status.State != "paused" || status.TimeInState != "1s" ||
+		status.NumTasks != 1 || status.Tasks["raft"] != 1 || status.NumWorkers != 1 || status.NumClosers != 1 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+}
+
+func TestStopperStatusWhileClosing(t *testing.T) {
+	s := stop.NewStopper(stop.WithName("stuck-closer"))
+	ctx := context.Background()
+
+	closing := make(chan struct{})
+	release := make(chan struct{})
+	s.AddCloser(stop.CloserFn(func() {
+		close(closing)
+		<-release
+	}))
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		s.Stop(ctx)
+	}()
+	<-closing
+
+	// Neither the status nor the debug page waits for the closer.
+	status := s.Status()
+	if status.State != "stopping" || !strings.HasSuffix(status.Closer, "TestStopperStatusWhileClosing.func1") ||
+		status.TimeInCloser == "" {
+		t.Errorf("unexpected status %+v", status)
+	}
+	w := httptest.NewRecorder()
+	stop.HandleDebug(w, httptest.NewRequest("GET", "/debug/stopper", nil))
+	if !strings.Contains(w.Body.String(), "closing github.com/birkelund/stop_test.TestStopperStatusWhileClosing.func1 for ") {
+		t.Errorf("expected the closer to be shown, got %q", w.Body.String())
+	}
+
+	close(release)
+	<-stopped
+}
@@ -23,18 +23,30 @@
 // This package is extracted from the CockroachDB source tree (see
 // https://github.com/cockroachdb/cockroach/tree/master/pkg/util/stop) and
 // modified to remove cockroach specific packages.
+//
+// The newer API of the upstream stopper is provided where it maps onto this
+// package (see RunAsyncTaskEx, WithCancelOnQuiesce and AddCloser). Its
+// ShouldQuiesce-only model, in which ShouldStop and RunWorker are removed and
+// long-running loops are async tasks watching ShouldQuiesce, is not yet
+// ported: code written for it works unchanged, but ShouldStop and RunWorker
+// keep their meaning here.
+//
+// A Stopper created inside a testing/synctest bubble may be used to test
+// shutdown deterministically: once Stop has returned and abandoned background
+// tasks have finished, the stopper leaves no goroutines behind.
 package stop
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime/debug"
 	"runtime/pprof"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -49,9 +61,31 @@ import (
 // is no more capacity for async tasks, as limited by the semaphore.
 var ErrThrottled = errors.New("throttled on async limiting semaphore")
 
-// ErrUnavailable is returned from Run* functions if the stopper quiescing.
+// ErrQueueFull is returned from RunLimitedAsyncTask in the event that the
+// maximum number of submissions are already waiting for the semaphore, as
+// limited by the MaxQueueDepth option.
+var ErrQueueFull = errors.New("queue full on async limiting semaphore")
+
+// ErrPaused is returned from Run* functions while task admission is paused
+// (see Pause). Unlike ErrUnavailable, it is temporary and the call may be
+// retried.
+var ErrPaused = errors.New("paused")
+
+// ErrUnavailable is matched, using errors.Is, by the errors returned from Run*
+// functions if the stopper is quiescing or stopped. The returned error is
+// ErrQuiescing or ErrStopped, or, if the stopper was stopped with
+// StopWithReason, an error wrapping the reason which matches either.
 var ErrUnavailable = errors.New("unavailable")
 
+// ErrQuiescing is returned from Run* functions while the stopper is
+// quiescing: it is draining its tasks, and the call could be retried with
+// another stopper. It matches ErrUnavailable.
+var ErrQuiescing error = unavailableError("unavailable: quiescing")
+
+// ErrStopped is returned from Run* functions once the stopper has quiesced
+// and is stopping or stopped for good. It matches ErrUnavailable.
+var ErrStopped error = unavailableError("unavailable: stopped")
+
 func register(s *Stopper) {
 	trackedStoppers.Lock()
 	trackedStoppers.stoppers = append(trackedStoppers.stoppers, s)
@@ -76,19 +110,41 @@ var trackedStoppers struct {
 	stoppers []*Stopper
 }
 
-func handleDebug(w http.ResponseWriter, r *http.Request) {
+// HandleDebug renders the stoppers which have been created by NewStopper and
+// have not yet stopped, with their names (see WithName), states and running
+// tasks. It is served on /debug/stopper by the default HTTP server mux. With
+// the format=dot query parameter, it serves the output of WriteGraph instead.
+func HandleDebug(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("format") == "dot" {
+		w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+		WriteGraph(w)
+		return
+	}
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	// Copy the stoppers, so that the registry is not locked while waiting for
+	// the lock of each stopper.
 	trackedStoppers.Lock()
-	defer trackedStoppers.Unlock()
-	for _, s := range trackedStoppers.stoppers {
+	stoppers := append([]*Stopper(nil), trackedStoppers.stoppers...)
+	trackedStoppers.Unlock()
+	for _, s := range stoppers {
 		s.mu.Lock()
-		fmt.Fprintf(w, "%p: %d tasks\n%s", s, s.mu.numTasks, s.runningTasksLocked())
+		if s.name != "" {
+			fmt.Fprintf(w, "%s ", s.name)
+		}
+		fmt.Fprintf(w, "%p: %s, %d tasks\n%s\n", s, s.stateLocked(), s.NumTasks(), s.runningTasksLocked())
+		if s.mu.closing != "" {
+			fmt.Fprintf(w, "closing %s for %s\n", s.mu.closing, s.clock.Now().Sub(s.mu.closingSince))
+		}
 		s.mu.Unlock()
+		if recent := s.RecentPanics(); len(recent) > 0 {
+			fmt.Fprintf(w, "recovered panics:\n%s\nlast panic in %q: %v\n%s\n",
+				s.PanicCounts(), recent[0].Task, recent[0].Value, recent[0].Stack)
+		}
 	}
 }
 
 func init() {
-	http.Handle("/debug/stopper", http.HandlerFunc(handleDebug))
+	http.Handle("/debug/stopper", http.HandlerFunc(HandleDebug))
 }
 
 // Closer is an interface for objects to attach to the stopper to
@@ -106,11 +162,17 @@ func (f CloserFn) Close() {
 }
 
 type taskKey struct {
+	name string
 	file string
 	line int
+
+	untracked bool // not counted per key (see UntrackedTasks)
 }
 
 func (k taskKey) String() string {
+	if k.name != "" {
+		return k.name
+	}
 	return fmt.Sprintf("%s:%d", k.file, k.line)
 }
 
@@ -130,20 +192,103 @@ func (k taskKey) String() string {
 // be added to the stopper via AddCloser(), to be closed after the
 // stopper has stopped.
 type Stopper struct {
+	drainer    chan struct{}     // Closed when draining
 	quiescer   chan struct{}     // Closed when quiescing
 	stopper    chan struct{}     // Closed when stopping
 	stopped    chan struct{}     // Closed when stopped completely
-	onPanic    func(interface{}) // called with recover() on panic on any goroutine
+	phases     phases            // Closed at the transitions in between
+	onFatal    func(interface{}) // called with recover() on panic in critical tasks
 	trackTasks bool              // Should task call sites be tracked
+	watchdog   optionWatchdog    // Heartbeat checking interval and reporter
 	stop       sync.WaitGroup    // Incremented for outstanding workers
-	mu         struct {
+	heartbeats heartbeats        // Workers started with RunWorkerWithHeartbeat
+
+	backgroundGrace time.Duration      // Time Stop waits for background tasks
+	onThrottle      func(ThrottleInfo) // called when a limited task is throttled
+	limits          taskLimits         // Concurrency limits of named tasks
+	maxTasks        int                // Limit on concurrent tasks, if positive
+	maxTasksWait    bool               // Wait rather than reject above maxTasks
+	ctx             context.Context    // Canceled when quiescing
+	nop             bool               // Run async tasks synchronously, never stop
+	creationStack   []byte             // Set if leak tracking is enabled
+	clock           Clock              // Time source for time-based behavior
+	name            string             // Name shown by HandleDebug
+	closerTimeout   time.Duration      // Bound on each closer, if positive
+	onStopReport    func(StopReport)   // called with the report of each Stop
+	drainDelay      time.Duration      // Time Stop waits between draining and quiescing
+	drainTimeout    time.Duration      // Bound on the drain hooks
+	interceptors    []TaskInterceptor  // Wrap the execution of every task
+	onTaskStart     func(TaskInfo)     // called when a task begins to run
+	onTaskEnd       func(TaskEnd)      // called when a task has run
+	latencies       taskLatencies      // Run durations of tasks, if tracked
+	deduped         dedupedTasks       // Running tasks of RunDedupedTask
+	cancelOnQuiesce bool               // Cancel task contexts when quiescing
+	watched         watchedTasks       // Running tasks, if watched for diagnostics
+	tasks           taskRegistry       // Number of running tasks per key
+	untracked       bool               // Count tasks only in total, not per key
+	panics          panicState         // Called with recover() on panic on any goroutine
+	logger          Logger             // Destination of log messages
+	tracer          Tracer             // Starts a span for each task, if set
+	metrics         Metrics            // Receives task events, if set
+	numTasks        atomic.Int64       // Number of running tasks, see runPrelude
+	quiescing       atomic.Bool        // True once Quiesce has been called, set under mu
+	paused          atomic.Bool        // True between Pause() and Resume(), set under mu
+	waiters         atomic.Int32       // Goroutines waiting on mu.quiesce for tasks
+	shutdownWarn    time.Duration      // Interval of the running tasks logged by Wait
+	shutdownTimeout time.Duration      // Time Wait allows for graceful shutdown
+	envErr          error              // Invalid value read by ConfigFromEnv
+	collectErrors   bool               // Collect errors returned by async tasks
+	failFast        bool               // Stop when a task returns an error
+	exit            exitStatus         // Status given to SetExitStatus
+	forced          forceState         // Cancels task contexts when forced to stop
+	flushTimeout    time.Duration      // Bound on the flushers, if positive
+
+	quiesceProgress optionQuiesceProgress // Progress reporting while quiescing
+
+	restart func(context.Context) error // Called by Wait on SIGHUP, if set
+
+	ignoredCancellation optionDetectIgnoredCancellation // Reporting of stuck tasks
+	slowTasks           optionSlowTaskThreshold         // Reporting of slow tasks
+	blockingTasks       optionReportBlockingTasks       // Reporting of tasks blocking Stop
+
+	mu struct {
 		sync.Mutex
-		quiesce   *sync.Cond // Conditional variable to wait for outstanding tasks
-		quiescing bool       // true when Stop() has been called
-		numTasks  int        // number of outstanding tasks
-		tasks     map[taskKey]int
-		closers   []Closer
-		cancels   []func()
+		quiesce  *sync.Cond // Conditional variable to wait for outstanding tasks
+		stopping bool       // true when tasks have quiesced and workers are stopping
+		closers  []stagedCloser
+		closed   bool       // true once the closers have been taken to be closed
+		flushers []Flusher  // flushers added with AddFlusher()
+		temps    []tempPath // paths added with TrackTempDir() and TrackTempFile()
+		cancels  []func()
+
+		background   map[*backgroundTask]struct{}
+		components   map[string]*componentWorkers // workers started with RunComponentWorker()
+		queued       map[Semaphore]int            // submissions waiting per semaphore
+		draining     bool                         // true once ShouldDrain() is closed
+		stopReason   error                        // reason given to StopWithReason()
+		stopRequests int                          // number of calls to Stop(), see StopRequests()
+
+		afterDrain   afterFuncs // functions registered with AfterDrain()
+		afterQuiesce afterFuncs // functions registered with AfterQuiesce()
+		afterStop    afterFuncs // functions registered with AfterStop()
+
+		numWorkers int       // number of running workers
+		since      time.Time // time the stopper entered its current state
+
+		closing      string    // closer being closed by Stop, if any
+		closingSince time.Time // time the current closer was called
+
+		overruns    []CloserOverrun // closers which exceeded closerTimeout
+		closeErrors []error         // errors returned by closers, see CloserErrors()
+		taskErrors  []error         // errors returned by async tasks, see TaskErrors()
+
+		listeners []handOffListener // listeners created by Listen()
+
+		drainHooks []func(context.Context) error // functions registered with OnDrain()
+
+		quiesceStart time.Time                // time quiescing began
+		drainTimes   map[string]time.Duration // per task, time to finish after quiesceStart
+		stopReport   StopReport               // report of the last Stop
 	}
 }
 
@@ -155,11 +300,12 @@ type Option interface {
 type optionPanicHandler func(interface{})
 
 func (oph optionPanicHandler) apply(stopper *Stopper) {
-	stopper.onPanic = oph
+	stopper.panics.onPanic = oph
 }
 
 // OnPanic is an option which lets the Stopper recover from all panics using
-// the provided panic handler.
+// the provided panic handler. See SetOnPanic and AddPanicHandler for
+// installing panic handlers after construction.
 //
 // When Stop() is invoked during stack unwinding, OnPanic is also invoked, but
 // Stop() may not have carried out its duties.
@@ -167,6 +313,44 @@ func OnPanic(handler func(interface{})) Option {
 	return optionPanicHandler(handler)
 }
 
+// PanicInfo describes a panic recovered by the Stopper.
+type PanicInfo struct {
+	// Value is the value returned by recover().
+	Value interface{}
+	// Stack is the stack trace of the panicking goroutine.
+	Stack []byte
+	// Task identifies the panicking task by name or call site, or the
+	// supervised worker by name. It is empty for panics on other workers and
+	// on goroutines using Recover().
+	Task string
+}
+
+type optionPanicInfoHandler func(PanicInfo)
+
+func (opih optionPanicInfoHandler) apply(stopper *Stopper) {
+	stopper.panics.onPanicWithInfo = opih
+}
+
+// OnPanicWithInfo is like OnPanic, but the panic handler is also given the
+// stack trace of the panicking goroutine and the identity of the task. It takes
+// precedence over OnPanic.
+func OnPanicWithInfo(handler func(PanicInfo)) Option {
+	return optionPanicInfoHandler(handler)
+}
+
+type optionFatalHandler func(interface{})
+
+func (ofh optionFatalHandler) apply(stopper *Stopper) {
+	stopper.onFatal = ofh
+}
+
+// OnFatal is an option which sets the handler called when a critical task (see
+// RunCriticalTask) panics. The handler would typically terminate the process
+// after flushing logs. If it returns, the panic is re-raised.
+func OnFatal(handler func(interface{})) Option {
+	return optionFatalHandler(handler)
+}
+
 type optionTrackTasks bool
 
 func (ott optionTrackTasks) apply(stopper *Stopper) {
@@ -178,24 +362,118 @@ func TrackTasks(enabled bool) Option {
 	return optionTrackTasks(enabled)
 }
 
-// NewStopper returns an instance of Stopper.
+type optionUntrackedTasks struct{}
+
+func (optionUntrackedTasks) apply(stopper *Stopper) {
+	stopper.untracked = true
+}
+
+// UntrackedTasks is an option which makes the stopper skip all per-task
+// bookkeeping, such as looking up call sites and counting tasks per name,
+// and only maintain the number of running tasks, which Quiesce needs to wait
+// for them. It is meant for services where the tracking overhead measurably
+// hurts throughput. Untracked tasks are not listed by RunningTasks, waited
+// for by WaitForTasks, or included in the stop report. Use the Untracked task
+// option to skip the bookkeeping for individual hot-path tasks only.
+func UntrackedTasks() Option {
+	return optionUntrackedTasks{}
+}
+
+type optionMaxTasks struct {
+	n    int
+	wait bool
+}
+
+func (omt optionMaxTasks) apply(stopper *Stopper) {
+	stopper.maxTasks, stopper.maxTasksWait = omt.n, omt.wait
+}
+
+// MaxTasks is an option which limits the number of tasks, of any kind, that
+// may run concurrently to n. Once the limit is reached, a new task waits for
+// a running task to finish if wait is true, and is otherwise rejected with
+// ErrThrottled. The TryRun* functions never wait.
+//
+// Tasks which start other tasks and wait for them can deadlock when the
+// limit is reached with wait set to true.
+func MaxTasks(n int, wait bool) Option {
+	return optionMaxTasks{n, wait}
+}
+
+type optionName string
+
+func (on optionName) apply(stopper *Stopper) {
+	stopper.name = string(on)
+}
+
+// WithName is an option which names the stopper. The name identifies the
+// stopper in the output of String and HandleDebug.
+func WithName(name string) Option {
+	return optionName(name)
+}
+
+// NewStopper returns an instance of Stopper. It panics if the options are
+// invalid, such as a negative duration; use New to handle the error instead.
 func NewStopper(options ...Option) *Stopper {
+	s, err := New(options...)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// New is like NewStopper, but returns an error if the options are invalid.
+func New(options ...Option) (*Stopper, error) {
+	s, err := newStopper(options)
+	if err != nil {
+		return nil, err
+	}
+	recordCreationStack(s)
+	register(s)
+
+	if s.watchdog.report != nil {
+		s.runWatchdog()
+	}
+	if s.slowTasks.threshold > 0 {
+		s.runSlowTaskDetector()
+	}
+	return s, nil
+}
+
+func newStopper(options []Option) (*Stopper, error) {
 	s := &Stopper{
+		drainer:    make(chan struct{}),
 		quiescer:   make(chan struct{}),
 		stopper:    make(chan struct{}),
 		stopped:    make(chan struct{}),
+		phases:     makePhases(),
 		trackTasks: true,
+
+		backgroundGrace: DefaultBackgroundGracePeriod,
+		drainTimeout:    DefaultDrainHookTimeout,
+		flushTimeout:    DefaultFlushTimeout,
+		shutdownWarn:    DefaultShutdownWarnInterval,
+		shutdownTimeout: DefaultShutdownTimeout,
+		clock:           RealClock,
+		logger:          stdLogger{},
 	}
 
-	s.mu.tasks = map[taskKey]int{}
+	s.mu.background = map[*backgroundTask]struct{}{}
+	s.mu.queued = map[Semaphore]int{}
+	s.heartbeats.m = map[*Heartbeat]struct{}{}
+	s.limits.m = map[string]*WeightedSemaphore{}
 
 	for _, opt := range options {
 		opt.apply(s)
 	}
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
 
 	s.mu.quiesce = sync.NewCond(&s.mu)
-	register(s)
-	return s
+	s.mu.since = s.clock.Now()
+	s.ctx = s.WithCancel(context.Background())
+	s.forced.reset()
+	return s, nil
 }
 
 // Recover is used internally by Stopper to provide a hook for recovery of
@@ -204,35 +482,126 @@ func NewStopper(options ...Option) *Stopper {
 // of Stopper.
 func (s *Stopper) Recover(ctx context.Context) {
 	if r := recover(); r != nil {
-		if s.onPanic != nil {
-			s.onPanic(r)
-			return
+		s.handlePanic(ctx, r, "", nil)
+	}
+}
+
+// recoverCritical reports a panic in a critical task to the panic handler, if
+// any, and then calls the fatal handler, if any, before re-raising the panic.
+func (s *Stopper) recoverCritical(ctx context.Context, key taskKey) {
+	if r := recover(); r != nil {
+		s.reportPanic(PanicInfo{r, debug.Stack(), key.String()})
+		s.logger.Printf("panic in critical task: %v", r)
+		if s.onFatal != nil {
+			s.onFatal(r)
 		}
-		log.Print(r)
 		panic(r)
 	}
 }
 
 // RunWorker runs the supplied function as a "worker" to be stopped
 // by the stopper. The function <f> is run in a goroutine.
-func (s *Stopper) RunWorker(ctx context.Context, f func(context.Context)) {
+//
+// RunWorker returns ErrQuiescing, or ErrStopped, without calling f once the
+// stopper has begun to quiesce, so that no workers are started after
+// ShouldQuiesce has been closed.
+func (s *Stopper) RunWorker(ctx context.Context, f func(context.Context)) error {
+	return s.RunWorkerWithCleanup(ctx, f, nil)
+}
+
+// RunWorkerWithCleanup is like RunWorker, but calls cleanup after function f
+// returns (or panics) and before Stop() unblocks. This allows per-worker
+// teardown, such as closing a connection owned by the worker, without
+// registering a separate Closer.
+func (s *Stopper) RunWorkerWithCleanup(
+	ctx context.Context, f func(context.Context), cleanup func(context.Context),
+) error {
+	return s.runWorker(ctx, f, cleanup, false)
+}
+
+// runWorker implements RunWorkerWithCleanup. If whileQuiescing is set, the
+// worker is started even though the stopper is quiescing, as long as it is
+// not stopping, for running tasks which have already been admitted.
+func (s *Stopper) runWorker(
+	ctx context.Context, f func(context.Context), cleanup func(context.Context), whileQuiescing bool,
+) error {
+	s.mu.Lock()
+	if s.mu.stopping || (s.quiescing.Load() && !whileQuiescing) {
+		defer s.mu.Unlock()
+		return s.errUnavailableLocked()
+	}
 	s.stop.Add(1)
+	s.mu.numWorkers++
+	s.mu.Unlock()
+
 	go func() {
 		// Remove any associated span; we need to ensure this because the
 		// worker may run longer than the caller which presumably closes
 		// any spans it has created.
 		//ctx = opentracing.ContextWithSpan(ctx, nil)
 		defer s.Recover(ctx)
-		defer s.stop.Done()
+		defer func() {
+			s.mu.Lock()
+			s.mu.numWorkers--
+			s.mu.Unlock()
+			s.stop.Done()
+		}()
+		if cleanup != nil {
+			defer cleanup(ctx)
+		}
 		f(ctx)
 	}()
+	return nil
 }
 
-// AddCloser adds an object to close after the stopper has been stopped.
+// AddCloser adds an object to close after the stopper has been stopped. It
+// is equivalent to AddCloserToStage with stage 0.
+//
+// As with the upstream stopper, a closer added once the closers have been
+// closed, or are being closed, by Stop is closed immediately.
 func (s *Stopper) AddCloser(c Closer) {
+	s.AddCloserToStage(0, c)
+}
+
+// AddCloserToStage adds an object to close after the stopper has been
+// stopped, as part of the given stage. Stages are closed in increasing order,
+// each running to completion before the next begins, so that for instance
+// caches added to stage 0 are flushed before the database pool added to
+// stage 1 is closed. Within a stage, closers are closed in the order they
+// were added.
+func (s *Stopper) AddCloserToStage(stage int, c Closer) {
 	s.mu.Lock()
+	if s.mu.closed {
+		s.mu.Unlock()
+		c.Close()
+		return
+	}
 	defer s.mu.Unlock()
-	s.mu.closers = append(s.mu.closers, c)
+	s.mu.closers = append(s.mu.closers, stagedCloser{Closer: c, stage: stage})
+}
+
+type stagedCloser struct {
+	Closer
+	stage     int
+	name      string   // component name, see AddComponent
+	dependsOn []string // names of components closed after this one
+}
+
+// sortedClosersLocked returns the closers in the order they are closed.
+func (s *Stopper) sortedClosersLocked() []stagedCloser {
+	closers := append([]stagedCloser(nil), s.mu.closers...)
+	sort.SliceStable(closers, func(i, j int) bool {
+		return closers[i].stage < closers[j].stage
+	})
+	for i := 0; i < len(closers); {
+		j := i + 1
+		for j < len(closers) && closers[j].stage == closers[i].stage {
+			j++
+		}
+		copy(closers[i:j], orderByDependencies(closers[i:j]))
+		i = j
+	}
+	return closers
 }
 
 // RunTask adds one to the count of tasks left to quiesce in the system. Any
@@ -242,21 +611,22 @@ func (s *Stopper) AddCloser(c Closer) {
 // accepts external client requests.
 //
 // Returns an error to indicate that the system is currently quiescing and
-// function f was not called.
-func (s *Stopper) RunTask(ctx context.Context, f func(context.Context)) error {
-	key := taskKey{"???", 1}
-	if s.trackTasks {
-		key.file, key.line, _ = caller.Lookup(1)
-	}
-	if !s.runPrelude(key) {
-		return ErrUnavailable
+// function f was not called, or, with the PanicsAsErrors option, that f
+// panicked.
+func (s *Stopper) RunTask(
+	ctx context.Context, f func(context.Context), opts ...TaskOption,
+) (err error) {
+	o := makeTaskOptions(opts)
+	key := s.makeTaskKey(&o)
+	if err := s.runPrelude(key, &o); err != nil {
+		return newTaskError(key, &o, err)
 	}
 
 	// Call f.
-	defer s.Recover(ctx)
+	defer s.recoverTask(ctx, key, &o, &err)
 	defer s.runPostlude(key)
 
-	f(ctx)
+	s.runTaskFunc(ctx, key, &o, false, f)
 	return nil
 }
 
@@ -268,43 +638,137 @@ func (s *Stopper) RunTask(ctx context.Context, f func(context.Context)) error {
 //
 // If the system is currently quiescing and function f was not called, returns
 // an error indicating this condition. Otherwise, returns whatever f returns.
-func (s *Stopper) RunTaskWithErr(ctx context.Context, f func(context.Context) error) error {
-	key := taskKey{"???", 1}
-	if s.trackTasks {
-		key.file, key.line, _ = caller.Lookup(1)
+func (s *Stopper) RunTaskWithErr(
+	ctx context.Context, f func(context.Context) error, opts ...TaskOption,
+) (err error) {
+	o := makeTaskOptions(opts)
+	key := s.makeTaskKey(&o)
+	if err := s.runPrelude(key, &o); err != nil {
+		return newTaskError(key, &o, err)
 	}
-	if !s.runPrelude(key) {
-		return ErrUnavailable
+
+	// Call f.
+	defer s.recoverTask(ctx, key, &o, &err)
+	defer s.runPostlude(key)
+
+	return s.runTaskFuncWithErr(ctx, key, &o, false, f)
+}
+
+// RunCriticalTask is like RunTask, but for work which must not fail silently,
+// such as work which maintains invariants. If f panics, the panic is reported
+// to the OnPanic handler, but is not recovered: the OnFatal handler is called
+// and the panic is re-raised.
+//
+// The TaskPanicHandler option has no effect on critical tasks.
+func (s *Stopper) RunCriticalTask(
+	ctx context.Context, f func(context.Context), opts ...TaskOption,
+) error {
+	o := makeTaskOptions(opts)
+	key := s.makeTaskKey(&o)
+	if err := s.runPrelude(key, &o); err != nil {
+		return newTaskError(key, &o, err)
 	}
 
 	// Call f.
-	defer s.Recover(ctx)
+	defer s.recoverCritical(ctx, key)
 	defer s.runPostlude(key)
 
-	return f(ctx)
+	s.runTaskFunc(ctx, key, &o, false, f)
+	return nil
 }
 
 // RunAsyncTask runs function f in a goroutine. It returns an error when the
 // Stopper is quiescing, in which case the function is not executed.
-func (s *Stopper) RunAsyncTask(ctx context.Context, f func(context.Context)) error {
-	key := taskKey{"???", 1}
-	if s.trackTasks {
-		key.file, key.line, _ = caller.Lookup(1)
+//
+// If a limit has been set for the task name with SetTaskLimit, RunAsyncTask
+// waits for the number of running tasks with the name to drop below it.
+func (s *Stopper) RunAsyncTask(
+	ctx context.Context, f func(context.Context), opts ...TaskOption,
+) error {
+	t := newAsyncTask(s, ctx, f)
+	t.o = makeTaskOptions(opts)
+	t.key = s.makeTaskKey(&t.o)
+	return s.startAsyncTask(t)
+}
+
+// Go runs function f in a goroutine, like RunAsyncTask, for quick background
+// work where the error returned by RunAsyncTask would be ignored anyway. If
+// the stopper is quiescing, f is not called.
+func (s *Stopper) Go(f func(), opts ...TaskOption) {
+	t := newAsyncTask(s, context.Background(), func(context.Context) { f() })
+	t.o = makeTaskOptions(opts)
+	t.key = s.makeTaskKey(&t.o)
+	_ = s.startAsyncTask(t)
+}
+
+// startAsyncTask admits the async task and starts its goroutine.
+func (s *Stopper) startAsyncTask(t *asyncTask) error {
+	if sem := s.taskLimit(t.o.name); sem != nil {
+		ctx, f, key, o := t.ctx, t.f, t.key, t.o
+		t.release()
+		return newTaskError(key, &o, s.runLimitedAsyncTask(ctx, sem, true, f, key, &o))
 	}
-	if !s.runPrelude(key) {
-		return ErrUnavailable
+	if err := s.runPrelude(t.key, &t.o); err != nil {
+		key, o := t.key, t.o
+		t.release()
+		return newTaskError(key, &o, err)
 	}
 
 	//ctx, span := tracing.ForkCtxSpan(ctx, key.String())
 
 	// Call f.
-	go func() {
-		defer s.Recover(ctx)
+	s.goTask(t.run)
+	return nil
+}
+
+// TryRunTask is like RunTask, but never blocks before calling f. If the
+// MaxTasks limit has been reached, it returns ErrThrottled immediately instead
+// of waiting, and if the stopper is quiescing, it returns ErrUnavailable. In
+// either case f is not called.
+func (s *Stopper) TryRunTask(
+	ctx context.Context, f func(context.Context), opts ...TaskOption,
+) (err error) {
+	o := makeTaskOptions(opts)
+	key := s.makeTaskKey(&o)
+	if err := s.tryRunPrelude(key, &o); err != nil {
+		return newTaskError(key, &o, err)
+	}
+
+	// Call f.
+	defer s.recoverTask(ctx, key, &o, &err)
+	defer s.runPostlude(key)
+
+	s.runTaskFunc(ctx, key, &o, false, f)
+	return nil
+}
+
+// TryRunAsyncTask is like RunAsyncTask, but never blocks. See TryRunTask.
+func (s *Stopper) TryRunAsyncTask(
+	ctx context.Context, f func(context.Context), opts ...TaskOption,
+) error {
+	o := makeTaskOptions(opts)
+	key := s.makeTaskKey(&o)
+	sem := s.taskLimit(o.name)
+	if sem != nil && !sem.TryAcquire(o.weight) {
+		return newTaskError(key, &o, ErrThrottled)
+	}
+	if err := s.tryRunPrelude(key, &o); err != nil {
+		if sem != nil {
+			sem.Release(o.weight)
+		}
+		return newTaskError(key, &o, err)
+	}
+
+	// Call f.
+	s.goTask(func() {
+		defer s.recoverTask(ctx, key, &o, nil)
 		defer s.runPostlude(key)
-		//defer tracing.FinishSpan(span)
+		if sem != nil {
+			defer sem.Release(o.weight)
+		}
 
-		f(ctx)
-	}()
+		s.runTaskFunc(ctx, key, &o, true, f)
+	})
 	return nil
 }
 
@@ -316,87 +780,173 @@ func (s *Stopper) RunAsyncTask(ctx context.Context, f func(context.Context)) err
 // immediately with an error if the semaphore is not
 // available. Returns an error if the Stopper is quiescing, in which
 // case the function is not executed.
+//
+// Waiting callers are not guaranteed to acquire the semaphore in the order
+// they arrived. Use RunLimitedAsyncTaskWithSemaphore with a WeightedSemaphore
+// created with the Fair option for FIFO acquisition.
 func (s *Stopper) RunLimitedAsyncTask(
 	ctx context.Context, sem chan struct{}, wait bool, f func(context.Context),
+	opts ...TaskOption,
 ) error {
-	key := taskKey{"???", 1}
-	if s.trackTasks {
-		key.file, key.line, _ = caller.Lookup(1)
-	}
+	o := makeTaskOptions(opts)
+	key := s.makeTaskKey(&o)
+	return newTaskError(key, &o, s.runLimitedAsyncTask(ctx, ChanSemaphore(sem), wait, f, key, &o))
+}
 
-	// Wait for permission to run from the semaphore.
-	select {
-	case sem <- struct{}{}:
-	case <-ctx.Done():
-		return ctx.Err()
-	case <-s.ShouldQuiesce():
-		return ErrUnavailable
-	default:
-		if !wait {
-			return ErrThrottled
+// runPrelude admits a task, or returns the error with which it is rejected.
+//
+// Admission does not take the stopper lock, so that tasks submitted
+// concurrently don't contend on it. A task is counted in numTasks first, and
+// the flags rejecting it are checked after, so that Quiesce and Drain, which
+// set the flags first and then wait for numTasks to drop to zero, either see
+// the task or have it rejected.
+func (s *Stopper) runPrelude(key taskKey, o *taskOptions) error {
+	for {
+		err := s.admit(o)
+		if err == nil {
+			s.tasks.add(key, 1)
+			return nil
 		}
-		log.Printf("stopper throttling task from %s due to semaphore", key)
-		// Retry the select without the default.
-		select {
-		case sem <- struct{}{}:
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-s.ShouldQuiesce():
-			return ErrUnavailable
+		if err != ErrThrottled || !s.maxTasksWait {
+			return err
 		}
+		s.waitForTaskSlot()
 	}
+}
 
-	// Check for canceled context: it's possible to get the semaphore even
-	// if the context is canceled.
-	select {
-	case <-ctx.Done():
-		<-sem
-		return ctx.Err()
-	default:
+// tryRunPrelude is like runPrelude, but returns ErrThrottled instead of
+// waiting for the MaxTasks limit.
+func (s *Stopper) tryRunPrelude(key taskKey, o *taskOptions) error {
+	if err := s.admit(o); err != nil {
+		return err
 	}
+	s.tasks.add(key, 1)
+	return nil
+}
 
-	if !s.runPrelude(key) {
-		<-sem
-		return ErrUnavailable
+// admit counts a task as running, unless it must be rejected, in which case
+// the count is undone and the error is returned.
+func (s *Stopper) admit(o *taskOptions) error {
+	n := s.numTasks.Add(1)
+	err := s.reject(o, n)
+	if err != nil {
+		s.taskDone()
 	}
+	return err
+}
 
-	//ctx, span := tracing.ForkCtxSpan(ctx, key.String())
-
-	go func() {
-		defer s.Recover(ctx)
-		defer s.runPostlude(key)
-		defer func() { <-sem }()
-		//defer tracing.FinishSpan(span)
+// reject returns the error with which a task with the given options must be
+// rejected because the stopper is quiescing or paused, or because n, the
+// number of running tasks including it, exceeds the MaxTasks limit, if any.
+// High priority tasks are admitted while paused, and while quiescing until
+// all tasks have drained.
+func (s *Stopper) reject(o *taskOptions, n int64) error {
+	if s.quiescing.Load() {
+		if o.priority < HighPriority {
+			return s.errUnavailable()
+		}
+		return s.rejectDrained()
+	}
+	if s.paused.Load() && o.priority < HighPriority {
+		return ErrPaused
+	}
+	if s.maxTasks > 0 && n > int64(s.maxTasks) {
+		return ErrThrottled
+	}
+	return nil
+}
 
-		f(ctx)
-	}()
+// rejectDrained returns the error with which a high priority task must be
+// rejected while quiescing because the running tasks have drained. It takes
+// the lock, under which Quiesce checks that they have.
+func (s *Stopper) rejectDrained() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.phases.quiesced:
+		return s.errUnavailableLocked()
+	default:
+	}
+	// The task counted itself already.
+	if s.NumTasks() <= 1 {
+		return s.errUnavailableLocked()
+	}
 	return nil
 }
 
-func (s *Stopper) runPrelude(key taskKey) bool {
+// waitForTaskSlot waits until the number of running tasks is below the
+// MaxTasks limit, or the stopper is quiescing.
+func (s *Stopper) waitForTaskSlot() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if s.mu.quiescing {
-		return false
+	s.waiters.Add(1)
+	defer s.waiters.Add(-1)
+	for !s.quiescing.Load() && s.NumTasks() >= s.maxTasks {
+		s.mu.quiesce.Wait()
 	}
-	s.mu.numTasks++
-	s.mu.tasks[key]++
-	return true
 }
 
 func (s *Stopper) runPostlude(key taskKey) {
+	s.tasks.add(key, -1)
+	if s.quiescing.Load() && !key.untracked {
+		s.mu.Lock()
+		s.recordDrainLocked(key)
+		s.mu.Unlock()
+	}
+	s.taskDone()
+}
+
+// taskDone removes a task from the count of running tasks and wakes up the
+// goroutines waiting for tasks to finish, if any. These register in waiters
+// before they check the count under the lock, so taking the lock is only
+// needed when there are any.
+func (s *Stopper) taskDone() {
+	s.numTasks.Add(-1)
+	if s.waiters.Load() > 0 {
+		s.mu.Lock()
+		s.mu.quiesce.Broadcast()
+		s.mu.Unlock()
+	}
+}
+
+// stateLocked describes the lifecycle state of the stopper.
+func (s *Stopper) stateLocked() string {
+	select {
+	case <-s.stopped:
+		return "stopped"
+	default:
+	}
+	switch {
+	case s.mu.stopping:
+		return "stopping"
+	case s.quiescing.Load():
+		return "quiescing"
+	case s.mu.draining:
+		return "draining"
+	case s.paused.Load():
+		return "paused"
+	}
+	return "running"
+}
+
+// String implements fmt.Stringer. It returns the name of the stopper (see
+// WithName), or its address if unnamed, with its state and task counts, such
+// as "kv-server (running, 3 tasks, 1 background tasks)".
+func (s *Stopper) String() string {
+	name := s.name
+	if name == "" {
+		name = fmt.Sprintf("stopper %p", s)
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.mu.numTasks--
-	s.mu.tasks[key]--
-	s.mu.quiesce.Broadcast()
+	return fmt.Sprintf("%s (%s, %d tasks, %d background tasks)",
+		name, s.stateLocked(), s.NumTasks(), len(s.mu.background))
 }
 
-// NumTasks returns the number of active tasks.
+// NumTasks returns the number of active tasks. It does not take the lock
+// guarding task admission, so it is cheap to call from monitoring loops.
 func (s *Stopper) NumTasks() int {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	return s.mu.numTasks
+	return int(s.numTasks.Load())
 }
 
 // A TaskMap is returned by RunningTasks().
@@ -414,58 +964,392 @@ func (tm TaskMap) String() string {
 }
 
 // RunningTasks returns a map containing the count of running tasks keyed by
-// call site.
+// call site. It does not block the submission of tasks, so it may be called
+// frequently, for instance by monitoring loops.
 func (s *Stopper) RunningTasks() TaskMap {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	return s.runningTasksLocked()
+	return s.tasks.snapshot()
 }
 
 func (s *Stopper) runningTasksLocked() TaskMap {
-	m := map[string]int{}
-	for k := range s.mu.tasks {
-		if s.mu.tasks[k] == 0 {
-			continue
-		}
-		m[k.String()] = s.mu.tasks[k]
-	}
-	return m
+	return s.tasks.snapshot()
 }
 
 // Stop signals all live workers to stop and then waits for each to
 // confirm it has stopped.
+//
+// Stop may be called more than once, and from several goroutines at once.
+// Only the first call stops the stopper; later calls wait for it to have
+// stopped, after which StopReport returns the same report to all callers.
+// StopRequests returns the number of calls.
+//
+// Stop waits for the running tasks, so a task which calls it waits for
+// itself and deadlocks. A task deciding that the process must shut down calls
+// StopAsync instead.
 func (s *Stopper) Stop(ctx context.Context) {
+	if s.nop {
+		return
+	}
 	defer s.Recover(ctx)
-	defer unregister(s)
+	stopped, first := s.requestStop()
+	if first {
+		defer unregister(s)
+	}
 
-	file, line, _ := caller.Lookup(1)
-	log.Printf("stop has been called from %s:%d, stopping or quiescing all running tasks", file, line)
+	s.logStop(1, first, nil)
 
 	// Don't bother doing stuff cleanly if we're panicking, that would likely
 	// block. Instead, best effort only. This cleans up the stack traces,
 	// avoids stalls and helps some tests in `./cli` finish cleanly (where
 	// panics happen on purpose).
 	if r := recover(); r != nil {
-		go s.Quiesce(ctx)
-		close(s.stopper)
-		close(s.stopped)
-		s.mu.Lock()
-		for _, c := range s.mu.closers {
-			go c.Close()
+		if first {
+			s.stopPanicking(ctx)
 		}
-		s.mu.Unlock()
 		panic(r)
 	}
 
+	if !first {
+		<-stopped
+		return
+	}
+	s.stopCleanly(ctx)
+}
+
+// StopWithReason is like Stop, but records the reason for stopping, which is
+// then returned by StopReason. If the stopper is stopped more than once, the
+// first reason is kept.
+func (s *Stopper) StopWithReason(ctx context.Context, reason error) {
+	if s.nop {
+		return
+	}
+	s.setStopReason(reason)
+
+	defer s.Recover(ctx)
+	stopped, first := s.requestStop()
+	if first {
+		defer unregister(s)
+	}
+
+	s.logStop(1, first, reason)
+
+	// See Stop.
+	if r := recover(); r != nil {
+		if first {
+			s.stopPanicking(ctx)
+		}
+		panic(r)
+	}
+
+	if !first {
+		<-stopped
+		return
+	}
+	s.stopCleanly(ctx)
+}
+
+// StopAsync is like Stop, but returns without waiting for the stopper to stop.
+// It returns the channel closed once the stopper has stopped, as returned by
+// IsStopped. Unlike Stop, it may be called from a task, for instance when a
+// task runs into a condition from which the process cannot recover.
+func (s *Stopper) StopAsync(ctx context.Context) <-chan struct{} {
+	return s.stopAsync(ctx, nil, 1)
+}
+
+// StopAsyncWithReason is like StopAsync, but records the reason for stopping
+// as StopWithReason does.
+func (s *Stopper) StopAsyncWithReason(ctx context.Context, reason error) <-chan struct{} {
+	return s.stopAsync(ctx, reason, 1)
+}
+
+// stopAsync implements StopAsync, logging the caller at the given depth, as in
+// caller.Lookup, from the caller of stopAsync as the stop site.
+func (s *Stopper) stopAsync(ctx context.Context, reason error, depth int) <-chan struct{} {
+	if s.nop {
+		return s.IsStopped()
+	}
+	if reason != nil {
+		s.setStopReason(reason)
+	}
+	stopped, first := s.requestStop()
+	s.logStop(depth+1, first, reason)
+	if first {
+		go func() {
+			defer s.Recover(ctx)
+			defer unregister(s)
+			s.stopCleanly(ctx)
+		}()
+	}
+	return stopped
+}
+
+// logStop logs a call to Stop, StopWithReason or StopAsync, identifying the
+// caller at the given depth, as in caller.Lookup, from the caller of logStop.
+func (s *Stopper) logStop(depth int, first bool, reason error) {
+	file, line, _ := caller.Lookup(depth + 1)
+	why := ""
+	if reason != nil {
+		why = fmt.Sprintf(" (%v)", reason)
+	}
+	if first {
+		s.logger.Printf("stop has been called from %s:%d%s, stopping or quiescing all running tasks", file, line, why)
+	} else {
+		s.logger.Printf("stop has been called again from %s:%d%s, waiting for the stopper to stop", file, line, why)
+	}
+}
+
+// requestStop counts a call to Stop, reporting whether it is the first, and
+// returns the channel closed once the stopper has stopped.
+func (s *Stopper) requestStop() (stopped <-chan struct{}, first bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mu.stopRequests++
+	return s.stopped, s.mu.stopRequests == 1
+}
+
+// StopRequests returns the number of times Stop, StopWithReason or StopAsync
+// has been called since the stopper was created or reset, for instance to tell
+// whether a shutdown was requested by more than one component.
+func (s *Stopper) StopRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mu.stopRequests
+}
+
+// StopReason returns the reason given to StopWithReason, or nil if the
+// stopper has not been stopped with a reason.
+func (s *Stopper) StopReason() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mu.stopReason
+}
+
+// An unavailableError is matched by ErrUnavailable.
+type unavailableError string
+
+func (e unavailableError) Error() string {
+	return string(e)
+}
+
+func (e unavailableError) Is(target error) bool {
+	return target == ErrUnavailable
+}
+
+// A stopReasonError is returned instead of ErrQuiescing or ErrStopped by a
+// stopper which was stopped with a reason. It matches the sentinel with
+// errors.Is and unwraps to the reason.
+type stopReasonError struct {
+	err    error
+	reason error
+}
+
+func (e *stopReasonError) Error() string {
+	return fmt.Sprintf("%v: %v", e.err, e.reason)
+}
+
+func (e *stopReasonError) Is(target error) bool {
+	return errors.Is(e.err, target)
+}
+
+func (e *stopReasonError) Unwrap() error {
+	return e.reason
+}
+
+// errUnavailable returns ErrQuiescing or ErrStopped, wrapping the stop reason
+// if any.
+func (s *Stopper) errUnavailable() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.errUnavailableLocked()
+}
+
+func (s *Stopper) errUnavailableLocked() error {
+	err := ErrQuiescing
+	if s.mu.stopping {
+		err = ErrStopped
+	}
+	if s.mu.stopReason == nil {
+		return err
+	}
+	return &stopReasonError{err, s.mu.stopReason}
+}
+
+func (s *Stopper) setStopReason(reason error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.mu.stopReason == nil {
+		s.mu.stopReason = reason
+	}
+}
+
+func (s *Stopper) stopCleanly(ctx context.Context) {
+	var report StopReport
+	start := s.clock.Now()
+	s.drain()
+	report.Drain = s.clock.Now().Sub(start)
 	s.Quiesce(ctx)
+	report.Quiesce = s.clock.Now().Sub(start) - report.Drain
+	s.waitForBackgroundTasks()
+	report.BackgroundTasks = s.clock.Now().Sub(start) - report.Drain - report.Quiesce
+	s.setStopping()
+	s.stopComponentWorkers()
 	close(s.stopper)
 	s.stop.Wait()
+	s.mu.Lock()
+	closePhase(s.phases.workersDone)
+	s.mu.Unlock()
+	report.Workers = s.clock.Now().Sub(start) - report.Drain - report.Quiesce - report.BackgroundTasks
+	s.closeAll(start, &report)
+	if s.onStopReport != nil {
+		s.onStopReport(report)
+	}
+}
+
+// closeAll runs the closers and marks the stopper as stopped, completing the
+// report of a Stop which began at start.
+//
+// The closers run without the stopper lock held, so that a closer which does
+// not return blocks neither Status nor HandleDebug, which both show the
+// closer being closed.
+func (s *Stopper) closeAll(start time.Time, report *StopReport) {
+	flushStart := s.clock.Now()
+	s.flush()
+	report.Flush = s.clock.Now().Sub(flushStart)
+	s.mu.Lock()
+	closers := s.sortedClosersLocked()
+	s.mu.closed = true
+	s.mu.Unlock()
+
+	for _, c := range closers {
+		closeStart := s.clock.Now()
+		s.mu.Lock()
+		s.mu.closing = c.String()
+		s.mu.closingSince = closeStart
+		s.mu.Unlock()
+		err := s.closeCloser(c)
+		s.mu.Lock()
+		s.mu.closing = ""
+		if err != nil {
+			err = &CloserError{Closer: c.String(), Err: err}
+			s.logger.Printf("%v", err)
+			s.mu.closeErrors = append(s.mu.closeErrors, err)
+		}
+		s.mu.Unlock()
+		report.Closers = append(report.Closers, CloserTiming{c.String(), s.clock.Now().Sub(closeStart), err})
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.removeTempLocked()
+	closePhase(s.phases.closersDone)
+	report.Tasks = s.drainTimesLocked()
+	report.TaskErrors = append([]error(nil), s.mu.taskErrors...)
+	report.Total = s.clock.Now().Sub(start)
+	s.mu.stopReport = *report
+	close(s.stopped)
+	s.mu.since = s.clock.Now()
+	s.fireLocked(&s.mu.afterStop)
+}
+
+func (s *Stopper) stopPanicking(ctx context.Context) {
+	go s.Quiesce(ctx)
+	s.setStopping()
+	close(s.stopper)
+	close(s.stopped)
+	s.mu.Lock()
+	s.phases.closeAll()
+	for _, f := range s.mu.flushers {
+		go f.Flush()
+	}
 	for _, c := range s.mu.closers {
-		c.Close()
+		go c.Close()
 	}
-	close(s.stopped)
+	s.mu.closed = true
+	for _, tp := range s.mu.temps {
+		go tp.remove()
+	}
+	s.mu.since = s.clock.Now()
+	s.fireLocked(&s.mu.afterStop)
+	s.mu.Unlock()
+}
+
+// Reset re-arms a stopped stopper so that it can be reused, keeping the
+// options it was created with. It returns an error, leaving the stopper
+// unchanged, if the stopper has not stopped (see IsStopped).
+//
+// Reset clears the closers and their overruns and errors, the flushers, the
+// temporary paths, the drain hooks, the stop report, the stop reason and the
+// count of stop requests, the exit status and the functions registered with
+// AfterDrain, AfterQuiesce and AfterStop or by ForceContext, and resumes task
+// admission if it was paused. It must not be called concurrently with other
+// methods of the stopper, as the channels returned by ShouldDrain,
+// ShouldQuiesce, ShouldStop and IsStopped, and those of the intermediate
+// phases (see Drained), and the context returned by Ctx, are replaced.
+func (s *Stopper) Reset() error {
+	if s.nop {
+		return nil
+	}
+	select {
+	case <-s.stopped:
+	default:
+		return errors.New("cannot reset a stopper which has not stopped")
+	}
+
+	s.mu.Lock()
+	s.drainer = make(chan struct{})
+	s.quiescer = make(chan struct{})
+	s.stopper = make(chan struct{})
+	s.stopped = make(chan struct{})
+	s.phases = makePhases()
+	s.mu.draining = false
+	s.quiescing.Store(false)
+	s.mu.stopping = false
+	s.paused.Store(false)
+	s.mu.stopReason = nil
+	s.mu.stopRequests = 0
+	s.tasks.reset()
+	s.mu.closers = nil
+	s.mu.closed = false
+	s.mu.components = nil
+	s.mu.flushers = nil
+	s.mu.temps = nil
+	s.mu.drainHooks = nil
+	s.mu.overruns = nil
+	s.mu.closeErrors = nil
+	s.mu.taskErrors = nil
+	s.mu.listeners = nil
+	s.mu.drainTimes = nil
+	s.mu.stopReport = StopReport{}
+	s.mu.cancels = nil
+	s.mu.afterDrain = afterFuncs{}
+	s.mu.afterQuiesce = afterFuncs{}
+	s.mu.afterStop = afterFuncs{}
+	s.mu.since = s.clock.Now()
+	s.mu.Unlock()
+	s.exit.Lock()
+	s.exit.code, s.exit.err = 0, nil
+	s.exit.Unlock()
+	s.forced.reset()
+
+	s.ctx = s.WithCancel(context.Background())
+	recordCreationStack(s)
+	register(s)
+
+	if s.watchdog.report != nil {
+		s.runWatchdog()
+	}
+	if s.slowTasks.threshold > 0 {
+		s.runSlowTaskDetector()
+	}
+	return nil
+}
+
+// setStopping prevents new workers from being started. It must be called
+// before waiting for the running workers.
+func (s *Stopper) setStopping() {
+	s.mu.Lock()
+	s.mu.stopping = true
+	s.mu.since = s.clock.Now()
+	s.mu.Unlock()
 }
 
 // ShouldQuiesce returns a channel which will be closed when Stop() has been
@@ -498,8 +1382,103 @@ func (s *Stopper) IsStopped() <-chan struct{} {
 	return s.stopped
 }
 
+// Pause temporarily stops the admission of new tasks without beginning to
+// quiesce: until Resume is called, the Run* functions return ErrPaused
+// instead of running tasks of normal priority. Running tasks, workers and
+// background tasks are not affected.
+func (s *Stopper) Pause() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setPausedLocked(true)
+}
+
+func (s *Stopper) setPausedLocked(paused bool) {
+	if s.paused.Load() != paused {
+		s.paused.Store(paused)
+		s.mu.since = s.clock.Now()
+	}
+}
+
+// Drain is a reversible Quiesce: it pauses the admission of new tasks (see
+// Pause) and waits until all running tasks have completed or ctx is done, in
+// which case ctx.Err() is returned. Either way, tasks are admitted again once
+// Resume is called. Concurrent calls each wait for the running tasks, with
+// their own ctx.
+func (s *Stopper) Drain(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setPausedLocked(true)
+
+	// Wake up the wait below when ctx is done.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.mu.Lock()
+			s.mu.quiesce.Broadcast()
+			s.mu.Unlock()
+		case <-done:
+		}
+	}()
+
+	s.waiters.Add(1)
+	defer s.waiters.Add(-1)
+	for s.NumTasks() > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s.mu.quiesce.Wait()
+	}
+	return nil
+}
+
+// WaitForTasks waits until no tasks with the given name (see TaskName), or
+// call site if unnamed, are running, or until ctx is done, in which case
+// ctx.Err() is returned. Unlike Drain, it does not affect the admission of
+// tasks, so a subsystem can flush its own async work while others carry on.
+// Tasks with the name which are started while waiting are waited for as well.
+func (s *Stopper) WaitForTasks(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Wake up the wait below when ctx is done.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.mu.Lock()
+			s.mu.quiesce.Broadcast()
+			s.mu.Unlock()
+		case <-done:
+		}
+	}()
+
+	s.waiters.Add(1)
+	defer s.waiters.Add(-1)
+	for s.runningTasksLocked()[name] > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s.mu.quiesce.Wait()
+	}
+	return nil
+}
+
+// Resume resumes the admission of tasks after Pause or Drain.
+func (s *Stopper) Resume() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setPausedLocked(false)
+}
+
 // Quiesce moves the stopper to state quiescing and waits until all
 // tasks complete. This is used from Stop() and unittests.
+//
+// Quiesce may be called more than once, and from several goroutines at once:
+// the stopper begins to quiesce on the first call, and every call returns
+// once the tasks have completed.
 func (s *Stopper) Quiesce(ctx context.Context) {
 	defer s.Recover(ctx)
 	s.mu.Lock()
@@ -507,15 +1486,32 @@ func (s *Stopper) Quiesce(ctx context.Context) {
 	for _, cancel := range s.mu.cancels {
 		cancel()
 	}
-	if !s.mu.quiescing {
-		s.mu.quiescing = true
+	s.cancelBackgroundTasksLocked()
+	if !s.quiescing.Load() {
+		s.startDrainingLocked()
+		s.quiescing.Store(true)
+		s.mu.since = s.clock.Now()
+		s.mu.quiesceStart = s.mu.since
 		close(s.quiescer)
+		closePhase(s.phases.drained)
+		s.fireLocked(&s.mu.afterQuiesce)
+		s.detectStuckTasks()
+		// Wake up tasks waiting for the MaxTasks limit.
+		s.mu.quiesce.Broadcast()
+	}
+	if s.quiesceProgress.report != nil && s.NumTasks() > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		go s.reportQuiesceProgress(s.clock.Now(), done)
 	}
-	for s.mu.numTasks > 0 {
-		log.Printf("quiescing; tasks left:\n%s", s.runningTasksLocked())
+	s.waiters.Add(1)
+	defer s.waiters.Add(-1)
+	for s.NumTasks() > 0 {
+		s.logger.Printf("quiescing; tasks left:\n%s", s.runningTasksLocked())
 		// Unlock s.mu, wait for the signal, and lock s.mu.
 		s.mu.quiesce.Wait()
 	}
+	closePhase(s.phases.quiesced)
 }
 
 // WithCancel returns a child context which is cancelled when the Stopper
@@ -544,8 +1540,40 @@ var DefaultSignals = []os.Signal{
 	syscall.SIGQUIT,
 }
 
+const (
+	// DefaultShutdownWarnInterval is the default ShutdownWarnInterval.
+	DefaultShutdownWarnInterval = 5 * time.Second
+	// DefaultShutdownTimeout is the default ShutdownTimeout.
+	DefaultShutdownTimeout = time.Minute
+)
+
+type optionShutdownWarnInterval time.Duration
+
+func (oswi optionShutdownWarnInterval) apply(stopper *Stopper) {
+	stopper.shutdownWarn = time.Duration(oswi)
+}
+
+// ShutdownWarnInterval is an option which sets the interval at which Wait
+// logs the running tasks while the stopper is shutting down.
+func ShutdownWarnInterval(d time.Duration) Option {
+	return optionShutdownWarnInterval(d)
+}
+
+type optionShutdownTimeout time.Duration
+
+func (ost optionShutdownTimeout) apply(stopper *Stopper) {
+	stopper.shutdownTimeout = time.Duration(ost)
+}
+
+// ShutdownTimeout is an option which sets the time Wait allows the stopper to
+// shut down gracefully, after which it gives up and returns.
+func ShutdownTimeout(d time.Duration) Option {
+	return optionShutdownTimeout(d)
+}
+
 // Wait waits until the stopper is closed or a signal is received on signalCh.
-// interruptFn is called when a signal is received.
+// interruptFn is called when a signal is received. If the stopper was created
+// with the OnRestart option, SIGHUP restarts the process instead.
 func (s *Stopper) Wait(ctx context.Context, interruptFn func(context.Context), sigs []os.Signal) error {
 	var err error
 	var rc int
@@ -553,34 +1581,57 @@ func (s *Stopper) Wait(ctx context.Context, interruptFn func(context.Context), s
 	signalCh := make(chan os.Signal, 1)
 	signal.Notify(signalCh, sigs...)
 
+	// In restart mode, SIGHUP restarts instead of stopping (see OnRestart).
+	var restartCh chan os.Signal
+	if s.restart != nil {
+		restartCh = make(chan os.Signal, 1)
+		signal.Notify(restartCh, syscall.SIGHUP)
+	}
+
 	// wait for termination or signal
-	select {
-	case <-s.ShouldStop():
-	case sig := <-signalCh:
-		log.Printf("received signal '%s'", sig)
-		if sig == os.Interrupt {
-			err = errors.New("interrupted")
-			msg := "a second interrupt will skip graceful shutdown and terminate forcefully"
-			fmt.Fprintln(os.Stdout, msg)
-		}
+wait:
+	for {
+		select {
+		case <-s.ShouldStop():
+			err = s.StopReason()
+		case sig := <-restartCh:
+			s.logger.Printf("received signal '%s'", sig)
+			if rerr := s.restart(ctx); rerr != nil {
+				s.logger.Printf("restart failed, continuing: %v", rerr)
+				continue wait
+			}
+			err = ErrRestarting
+			go s.Stop(ctx)
+		case sig := <-signalCh:
+			s.logger.Printf("received signal '%s'", sig)
+			if sig == os.Interrupt {
+				err = errors.New("interrupted")
+				msg := "a second interrupt will skip graceful shutdown and terminate forcefully"
+				fmt.Fprintln(os.Stdout, msg)
+			}
 
-		go interruptFn(ctx)
+			go interruptFn(ctx)
+		}
+		break
+	}
+	if restartCh != nil {
+		signal.Stop(restartCh)
 	}
 
 	msg := "initiating graceful shutdown of server"
-	log.Print(msg)
+	s.logger.Printf("%v", msg)
 	fmt.Fprintln(os.Stdout, msg)
 
 	go func() {
-		ticker := time.NewTicker(5 * time.Second)
+		ticker := s.clock.NewTicker(s.shutdownWarn)
 		defer ticker.Stop()
 
 		for {
 			select {
-			case <-ticker.C:
+			case <-ticker.Chan():
 				//log.Infof(ctx, "running tasks:\n%s", s.RunningTasks())
-				log.Printf("running tasks:\n%s", s.RunningTasks())
-				//log.Printf("%d running tasks", s.NumTasks())
+				s.logger.Printf("running tasks:\n%s", s.RunningTasks())
+				//s.logger.Printf("%d running tasks", s.NumTasks())
 
 			case <-s.ShouldStop():
 				return
@@ -591,16 +1642,17 @@ func (s *Stopper) Wait(ctx context.Context, interruptFn func(context.Context), s
 	select {
 	case sig := <-signalCh:
 		err = fmt.Errorf("received signal '%s' during shutdown, initiating hard shutdown", sig)
-		log.Print(rc)
+		s.logger.Printf("%v", rc)
 
 		pprof.Lookup("goroutine").WriteTo(os.Stdout, 1)
 		rc = 128 + int(sig.(syscall.Signal))
-	case <-time.After(time.Minute):
+		s.SetExitStatus(rc, err)
+	case <-s.clock.After(s.shutdownTimeout):
 		err = fmt.Errorf("time limit reached, doing hard shutdown")
-		log.Print(err)
+		s.logger.Printf("%v", err)
 	case <-s.IsStopped():
 		msg := "shutdown completed"
-		log.Print(msg)
+		s.logger.Printf("%v", msg)
 		fmt.Fprintln(os.Stdout, msg)
 	}
 
@@ -19,7 +19,12 @@
 package stop_test
 
 import (
+	"bytes"
 	"fmt"
+	"log"
+	"net/http/httptest"
+	"reflect"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -171,6 +176,54 @@ func TestStopperRunWorker(t *testing.T) {
 	}
 }
 
+func TestStopperRunWorkerAfterStop(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+	s.Quiesce(ctx)
+
+	if err := s.RunWorker(ctx, func(context.Context) {
+		t.Error("worker should not run")
+	}); !errors.Is(err, stop.ErrQuiescing) {
+		t.Fatalf("expected %v; got %v", stop.ErrQuiescing, err)
+	}
+
+	s.Stop(ctx)
+
+	if err := s.RunWorker(ctx, func(context.Context) {
+		t.Error("worker should not run")
+	}); !errors.Is(err, stop.ErrStopped) {
+		t.Fatalf("expected %v; got %v", stop.ErrStopped, err)
+	}
+}
+
+func TestStopperRunWorkerWithCleanup(t *testing.T) {
+	s := stop.NewStopper(stop.OnPanic(func(interface{}) {}))
+	ctx := context.Background()
+
+	var cleanups int32
+	cleanup := func(context.Context) {
+		// Give Stop() a chance to return early if it doesn't wait.
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&cleanups, 1)
+	}
+	if err := s.RunWorkerWithCleanup(ctx, func(context.Context) {
+		<-s.ShouldStop()
+	}, cleanup); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RunWorkerWithCleanup(ctx, func(context.Context) {
+		panic("boom")
+	}, cleanup); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Stop(ctx)
+
+	if n := atomic.LoadInt32(&cleanups); n != 2 {
+		t.Fatalf("expected 2 cleanups before Stop() returned, got %d", n)
+	}
+}
+
 // TestStopperQuiesce tests coordinate quiesce with Quiesce.
 func TestStopperQuiesce(t *testing.T) {
 	var stoppers []*stop.Stopper
@@ -191,7 +244,7 @@ func TestStopperQuiesce(t *testing.T) {
 			// Wait until Quiesce() is called.
 			<-qc
 			err := thisStopper.RunTask(ctx, func(context.Context) {})
-			if err != stop.ErrUnavailable {
+			if !errors.Is(err, stop.ErrUnavailable) {
 				t.Error(err)
 			}
 			// Make the stoppers call Stop().
@@ -246,6 +299,65 @@ func TestStopperClosers(t *testing.T) {
 	}
 }
 
+func TestStopperCloserStages(t *testing.T) {
+	s := stop.NewStopper()
+	var closed []string
+	closer := func(name string) stop.Closer {
+		return stop.CloserFn(func() { closed = append(closed, name) })
+	}
+	s.AddCloserToStage(1, closer("db pool"))
+	s.AddCloser(closer("cache"))
+	s.AddCloserToStage(-1, closer("listener"))
+	s.AddCloserToStage(1, closer("db log"))
+	s.AddCloser(closer("index"))
+	s.Stop(context.Background())
+
+	expected := []string{"listener", "cache", "index", "db pool", "db log"}
+	if !reflect.DeepEqual(closed, expected) {
+		t.Errorf("expected closers to run in order %v, got %v", expected, closed)
+	}
+}
+
+func TestStopperTaskCallSites(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	block := make(chan struct{})
+	defer close(block)
+	var sites []string
+	for i := 0; i < 2; i++ {
+		_, line, _ := caller.Lookup(0)
+		if err := s.RunAsyncTask(ctx, func(context.Context) { <-block }); err != nil {
+			t.Fatal(err)
+		}
+		sites = append(sites, fmt.Sprintf("stopper_test.go:%d", line+1))
+		if err := s.RunAsyncTask(ctx, func(context.Context) { <-block }); err != nil {
+			t.Fatal(err)
+		}
+		sites = append(sites, fmt.Sprintf("stopper_test.go:%d", line+5))
+	}
+
+	expected := stop.TaskMap{sites[0]: 2, sites[1]: 2}
+	if tasks := s.RunningTasks(); !reflect.DeepEqual(tasks, expected) {
+		t.Errorf("expected tasks %v, got %v", expected, tasks)
+	}
+}
+
+func TestStopperRunTaskAllocs(t *testing.T) {
+	ctx := context.Background()
+	s := stop.NewStopper()
+	defer s.Stop(ctx)
+	f := func(context.Context) {}
+	if n := testing.AllocsPerRun(100, func() {
+		if err := s.RunTask(ctx, f); err != nil {
+			t.Fatal(err)
+		}
+	}); n != 0 {
+		t.Errorf("expected RunTask not to allocate, got %.1f allocs", n)
+	}
+}
+
 func TestStopperNumTasks(t *testing.T) {
 	s := stop.NewStopper()
 	var tasks []chan bool
@@ -299,6 +411,60 @@ func TestStopperNumTasks(t *testing.T) {
 	s.Stop(context.Background())
 }
 
+func TestStopperUntrackedTasks(t *testing.T) {
+	testCases := []struct {
+		name     string
+		stopOpts []stop.Option
+		taskOpts []stop.TaskOption
+	}{
+		{"stopper", []stop.Option{stop.UntrackedTasks()}, nil},
+		{"task", nil, []stop.TaskOption{stop.Untracked()}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := stop.NewStopper(tc.stopOpts...)
+			ctx := context.Background()
+			release := make(chan struct{})
+			if err := s.RunAsyncTask(ctx, func(context.Context) {
+				<-release
+			}, tc.taskOpts...); err != nil {
+				t.Fatal(err)
+			}
+			if tasks := s.RunningTasks(); len(tasks) != 0 {
+				t.Errorf("expected untracked task not to be listed, got:\n%s", tasks)
+			}
+			if n := s.NumTasks(); n != 1 {
+				t.Errorf("expected 1 task, got %d", n)
+			}
+
+			stopped := make(chan struct{})
+			go func() {
+				s.Stop(ctx)
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+				t.Fatal("expected Stop to wait for the untracked task")
+			case <-time.After(10 * time.Millisecond):
+			}
+			close(release)
+			<-stopped
+		})
+	}
+}
+
+// TestStopperNumTasksFromCloser verifies that NumTasks can be called while the
+// stopper holds its lock, such as from a closer.
+func TestStopperNumTasksFromCloser(t *testing.T) {
+	s := stop.NewStopper()
+	numTasks := -1
+	s.AddCloser(stop.CloserFn(func() { numTasks = s.NumTasks() }))
+	s.Stop(context.Background())
+	if numTasks != 0 {
+		t.Errorf("expected no tasks when closing, got %d", numTasks)
+	}
+}
+
 // TestStopperRunTaskPanic ensures that a panic handler can recover panicking
 // tasks, and that no tasks are leaked when they panic.
 func TestStopperRunTaskPanic(t *testing.T) {
@@ -337,6 +503,462 @@ func TestStopperRunTaskPanic(t *testing.T) {
 	}
 }
 
+func TestStopperRunCriticalTask(t *testing.T) {
+	var reported, fatal interface{}
+	s := stop.NewStopper(
+		stop.OnPanic(func(v interface{}) { reported = v }),
+		stop.OnFatal(func(v interface{}) { fatal = v }),
+	)
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	recovered := func() (v interface{}) {
+		defer func() { v = recover() }()
+		_ = s.RunCriticalTask(ctx, func(context.Context) { panic("boom") })
+		return nil
+	}()
+
+	if recovered != "boom" {
+		t.Errorf("expected panic to be re-raised, got %v", recovered)
+	}
+	if reported != "boom" {
+		t.Errorf("expected panic to be reported, got %v", reported)
+	}
+	if fatal != "boom" {
+		t.Errorf("expected fatal handler to be called, got %v", fatal)
+	}
+	if n := s.NumTasks(); n != 0 {
+		t.Errorf("expected no running tasks, got %d", n)
+	}
+}
+
+func TestStopperTaskPanicHandler(t *testing.T) {
+	var stopperRecovered, taskRecovered interface{}
+	s := stop.NewStopper(stop.OnPanic(func(v interface{}) {
+		stopperRecovered = v
+	}))
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	_ = s.RunTask(ctx, func(context.Context) {
+		panic("task")
+	}, stop.TaskPanicHandler(func(v interface{}) {
+		taskRecovered = v
+	}))
+	if taskRecovered != "task" || stopperRecovered != nil {
+		t.Fatalf("expected task handler to recover %q, got %v / %v", "task", taskRecovered, stopperRecovered)
+	}
+
+	// A nil task panic handler disables recovery.
+	recovered := func() (v interface{}) {
+		defer func() { v = recover() }()
+		_ = s.RunTask(ctx, func(context.Context) {
+			panic("crash")
+		}, stop.TaskPanicHandler(nil))
+		return nil
+	}()
+	if recovered != "crash" || stopperRecovered != nil {
+		t.Fatalf("expected panic %q to propagate, got %v / %v", "crash", recovered, stopperRecovered)
+	}
+}
+
+func TestStopperOnPanicWithInfo(t *testing.T) {
+	ch := make(chan stop.PanicInfo, 1)
+	s := stop.NewStopper(stop.OnPanicWithInfo(func(info stop.PanicInfo) {
+		ch <- info
+	}))
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	explode := func(context.Context) { panic("boom") }
+
+	_ = s.RunTask(ctx, explode, stop.TaskName("exploding"))
+	info := <-ch
+	if info.Value != "boom" || info.Task != "exploding" {
+		t.Errorf("unexpected panic info: %+v", info)
+	}
+	if !strings.Contains(string(info.Stack), "panic") {
+		t.Errorf("expected stack trace, got:\n%s", info.Stack)
+	}
+
+	_ = s.RunTask(ctx, explode)
+	if info := <-ch; !strings.Contains(info.Task, "stopper_test.go:") {
+		t.Errorf("expected task to be identified by call site, got %q", info.Task)
+	}
+}
+
+func TestStopperPanicsAsErrors(t *testing.T) {
+	s := stop.NewStopper(stop.OnPanic(func(v interface{}) {
+		t.Errorf("unexpected panic handler call with %v", v)
+	}))
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	err := s.RunTaskWithErr(ctx, func(context.Context) error {
+		panic("boom")
+	}, stop.TaskName("plugin"), stop.PanicsAsErrors())
+
+	var pe *stop.PanicError
+	if !errors.As(err, &pe) {
+		t.Fatalf("expected *stop.PanicError, got %T: %v", err, err)
+	}
+	if pe.Value != "boom" || pe.Task != "plugin" || len(pe.Stack) == 0 {
+		t.Errorf("unexpected panic error: %+v", pe)
+	}
+	if n := s.NumTasks(); n != 0 {
+		t.Errorf("expected no running tasks, got %d", n)
+	}
+
+	if err := s.RunTask(ctx, func(context.Context) {
+		panic("boom")
+	}, stop.PanicsAsErrors()); err == nil {
+		t.Error("expected panic to be returned as an error")
+	}
+}
+
+func TestStopperTaskError(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	s.Pause()
+	err := s.RunTask(ctx, func(context.Context) {}, stop.TaskName("paused"))
+	s.Resume()
+
+	var te *stop.TaskError
+	if !errors.As(err, &te) {
+		t.Fatalf("expected *stop.TaskError, got %T: %v", err, err)
+	}
+	if te.Task != "paused" || te.Phase != stop.PhaseAdmission || te.Err != stop.ErrPaused {
+		t.Errorf("unexpected task error: %+v", te)
+	}
+	if !strings.Contains(te.Site, "stopper_test.go:") {
+		t.Errorf("expected submission site in stopper_test.go, got %q", te.Site)
+	}
+
+	sem := make(chan struct{}, 1)
+	sem <- struct{}{}
+	err = s.RunLimitedAsyncTask(ctx, sem, false, func(context.Context) {})
+	if !errors.As(err, &te) || te.Phase != stop.PhaseThrottle || te.Task != te.Site {
+		t.Errorf("unexpected task error: %+v", err)
+	}
+
+	// Giving up waiting for the semaphore is not an admission failure.
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	err = s.RunLimitedAsyncTask(cctx, sem, true, func(context.Context) {})
+	if !errors.As(err, &te) || te.Phase != stop.PhaseThrottle || te.Err != context.Canceled {
+		t.Errorf("unexpected task error: %+v", err)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestStopperTaskName(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	_ = s.RunTask(ctx, func(context.Context) {
+		m := s.RunningTasks()
+		if len(m) != 1 || m["named"] != 1 {
+			t.Errorf("expected task to be tracked by name, got %+v", m)
+		}
+	}, stop.TaskName("named"))
+}
+
+func TestStopperTryRunTask(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+
+	ran := false
+	if err := s.TryRunTask(ctx, func(context.Context) { ran = true }); err != nil {
+		t.Fatal(err)
+	}
+	if !ran {
+		t.Fatal("expected task to run")
+	}
+
+	done := make(chan struct{})
+	if err := s.TryRunAsyncTask(ctx, func(context.Context) { close(done) }); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	s.Stop(ctx)
+
+	if err := s.TryRunTask(ctx, func(context.Context) {}); !errors.Is(err, stop.ErrStopped) {
+		t.Fatalf("expected %v; got %v", stop.ErrStopped, err)
+	}
+	if err := s.TryRunAsyncTask(ctx, func(context.Context) {}); !errors.Is(err, stop.ErrStopped) {
+		t.Fatalf("expected %v; got %v", stop.ErrStopped, err)
+	}
+}
+
+func TestStopperMaxTasks(t *testing.T) {
+	ctx := context.Background()
+	block := make(chan struct{})
+	f := func(context.Context) { <-block }
+
+	s := stop.NewStopper(stop.MaxTasks(1, false))
+	if err := s.RunAsyncTask(ctx, f); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RunTask(ctx, func(context.Context) {}); !errors.Is(err, stop.ErrThrottled) {
+		t.Fatalf("expected %v; got %v", stop.ErrThrottled, err)
+	}
+
+	s2 := stop.NewStopper(stop.MaxTasks(1, true))
+	if err := s2.RunAsyncTask(ctx, f); err != nil {
+		t.Fatal(err)
+	}
+	if err := s2.TryRunAsyncTask(ctx, f); !errors.Is(err, stop.ErrThrottled) {
+		t.Fatalf("expected %v; got %v", stop.ErrThrottled, err)
+	}
+
+	// A waiting task runs once the running task finishes.
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s2.RunTask(ctx, func(context.Context) {})
+	}()
+	select {
+	case err := <-errCh:
+		t.Fatalf("expected RunTask to wait, got %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(block)
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+
+	s.Stop(ctx)
+	s2.Stop(ctx)
+}
+
+func TestStopperTaskPriority(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+
+	block := make(chan struct{})
+	if err := s.RunAsyncTask(ctx, func(context.Context) { <-block }); err != nil {
+		t.Fatal(err)
+	}
+	go s.Stop(ctx)
+	<-s.ShouldQuiesce()
+
+	// While draining, only high priority tasks are admitted.
+	if err := s.RunTask(ctx, func(context.Context) {}); !errors.Is(err, stop.ErrQuiescing) {
+		t.Fatalf("expected %v; got %v", stop.ErrQuiescing, err)
+	}
+	ran := false
+	if err := s.RunTask(ctx, func(context.Context) {
+		ran = true
+	}, stop.Priority(stop.HighPriority)); err != nil {
+		t.Fatal(err)
+	}
+	if !ran {
+		t.Fatal("expected high priority task to run")
+	}
+
+	close(block)
+	<-s.IsStopped()
+	if err := s.RunTask(ctx, func(context.Context) {}, stop.Priority(stop.HighPriority)); !errors.Is(err, stop.ErrStopped) {
+		t.Fatalf("expected %v; got %v", stop.ErrStopped, err)
+	}
+}
+
+func TestStopperPauseResume(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	s.Pause()
+	if err := s.RunTask(ctx, func(context.Context) {}); !errors.Is(err, stop.ErrPaused) {
+		t.Fatalf("expected %v; got %v", stop.ErrPaused, err)
+	}
+	if err := s.TryRunAsyncTask(ctx, func(context.Context) {}); !errors.Is(err, stop.ErrPaused) {
+		t.Fatalf("expected %v; got %v", stop.ErrPaused, err)
+	}
+	if err := s.RunTask(ctx, func(context.Context) {}, stop.Priority(stop.HighPriority)); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Resume()
+	if err := s.RunTask(ctx, func(context.Context) {}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStopperDrain(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	block := make(chan struct{})
+	if err := s.RunAsyncTask(ctx, func(context.Context) { <-block }); err != nil {
+		t.Fatal(err)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := s.Drain(timeoutCtx); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v; got %v", context.DeadlineExceeded, err)
+	}
+
+	close(block)
+	if err := s.Drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.NumTasks(); n != 0 {
+		t.Fatalf("expected no tasks after Drain, got %d", n)
+	}
+	if err := s.RunTask(ctx, func(context.Context) {}); !errors.Is(err, stop.ErrPaused) {
+		t.Fatalf("expected %v; got %v", stop.ErrPaused, err)
+	}
+
+	s.Resume()
+	if err := s.RunTask(ctx, func(context.Context) {}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStopperWaitForTasks(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	flush, other := make(chan struct{}), make(chan struct{})
+	defer close(other)
+	if err := s.RunAsyncTask(ctx, func(context.Context) { <-flush }, stop.TaskName("flush")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RunAsyncTask(ctx, func(context.Context) { <-other }, stop.TaskName("other")); err != nil {
+		t.Fatal(err)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := s.WaitForTasks(timeoutCtx, "flush"); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v; got %v", context.DeadlineExceeded, err)
+	}
+
+	close(flush)
+	if err := s.WaitForTasks(ctx, "flush"); err != nil {
+		t.Fatal(err)
+	}
+	if tasks := s.RunningTasks(); tasks["flush"] != 0 || tasks["other"] != 1 {
+		t.Fatalf("expected only the other task to be running, got %v", tasks)
+	}
+	if err := s.RunTask(ctx, func(context.Context) {}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStopperStopWithReason(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+	if err := s.StopReason(); err != nil {
+		t.Fatalf("expected no stop reason, got %v", err)
+	}
+
+	reason := errors.New("disk full")
+	s.StopWithReason(ctx, reason)
+	if err := s.StopReason(); err != reason {
+		t.Fatalf("expected %v; got %v", reason, err)
+	}
+
+	err := s.RunTask(ctx, func(context.Context) {})
+	if !errors.Is(err, stop.ErrUnavailable) || !errors.Is(err, stop.ErrStopped) || !errors.Is(err, reason) {
+		t.Fatalf("expected error matching %v and %v; got %v", stop.ErrStopped, reason, err)
+	}
+}
+
+func TestStopperReset(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+
+	if err := s.Reset(); err == nil {
+		t.Fatal("expected error resetting a running stopper")
+	}
+
+	closed := 0
+	s.AddCloser(stop.CloserFn(func() { closed++ }))
+	s.StopWithReason(ctx, errors.New("maintenance"))
+	if err := s.Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-s.ShouldQuiesce():
+		t.Fatal("expected reset stopper not to be quiescing")
+	case <-s.IsStopped():
+		t.Fatal("expected reset stopper not to be stopped")
+	default:
+	}
+	if err := s.StopReason(); err != nil {
+		t.Fatalf("expected stop reason to be cleared, got %v", err)
+	}
+
+	ran := false
+	if err := s.RunTask(ctx, func(context.Context) { ran = true }); err != nil {
+		t.Fatal(err)
+	}
+	if !ran {
+		t.Fatal("expected task to run on the reset stopper")
+	}
+
+	s.Stop(ctx)
+	if closed != 1 {
+		t.Fatalf("expected closer to run once, ran %d times", closed)
+	}
+}
+
+func TestStopperHandleDebug(t *testing.T) {
+	s := stop.NewStopper(stop.WithName("kv-server"))
+	ctx := context.Background()
+	defer s.Stop(ctx)
+
+	block := make(chan struct{})
+	defer close(block)
+	if err := s.RunAsyncTask(ctx, func(context.Context) { <-block }, stop.TaskName("raft")); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	stop.HandleDebug(w, httptest.NewRequest("GET", "/debug/stopper", nil))
+	out := w.Body.String()
+	for _, expected := range []string{"kv-server", "running, 1 tasks", "raft"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in debug output:\n%s", expected, out)
+		}
+	}
+}
+
+func TestStopperString(t *testing.T) {
+	s := stop.NewStopper(stop.WithName("kv-server"))
+	ctx := context.Background()
+
+	block := make(chan struct{})
+	if err := s.RunAsyncTask(ctx, func(context.Context) { <-block }); err != nil {
+		t.Fatal(err)
+	}
+	if str, expected := s.String(), "kv-server (running, 1 tasks, 0 background tasks)"; str != expected {
+		t.Errorf("expected %q, got %q", expected, str)
+	}
+	close(block)
+	s.Stop(ctx)
+	if str, expected := s.String(), "kv-server (stopped, 0 tasks, 0 background tasks)"; str != expected {
+		t.Errorf("expected %q, got %q", expected, str)
+	}
+
+	unnamed := stop.NewStopper()
+	defer unnamed.Stop(ctx)
+	if str := fmt.Sprint(unnamed); !strings.HasPrefix(str, "stopper 0x") {
+		t.Errorf("unexpected string for unnamed stopper: %q", str)
+	}
+}
+
 func TestStopperWithCancel(t *testing.T) {
 	s := stop.NewStopper()
 	ctx := s.WithCancel(context.Background())
@@ -473,7 +1095,7 @@ func TestStopperRunLimitedAsyncTask(t *testing.T) {
 		context.Background(), sem, false /* wait */, func(_ context.Context) {
 		},
 	)
-	if err != stop.ErrThrottled {
+	if !errors.Is(err, stop.ErrThrottled) {
 		t.Fatalf("expected %v; got %v", stop.ErrThrottled, err)
 	}
 }
@@ -503,7 +1125,7 @@ func TestStopperRunLimitedAsyncTaskCancelContext(t *testing.T) {
 	if err := s.RunAsyncTask(ctx, func(ctx context.Context) {
 		for i := 0; i < maxConcurrency*2; i++ {
 			if err := s.RunLimitedAsyncTask(ctx, sem, true, f); err != nil {
-				if err != context.Canceled {
+				if !errors.Is(err, context.Canceled) {
 					t.Fatal(err)
 				}
 				atomic.AddInt32(&workersCancelled, 1)
@@ -552,12 +1174,59 @@ func BenchmarkStopper(b *testing.B) {
 	ctx := context.Background()
 	s := stop.NewStopper()
 	defer s.Stop(ctx)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := s.RunTask(ctx, maybePrint); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+func BenchmarkStopperUntracked(b *testing.B) {
+	ctx := context.Background()
+	s := stop.NewStopper(stop.UntrackedTasks())
+	defer s.Stop(ctx)
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		if err := s.RunTask(ctx, maybePrint); err != nil {
 			b.Fatal(err)
 		}
 	}
 }
+
+func BenchmarkStopperAsync(b *testing.B) {
+	ctx := context.Background()
+	s := stop.NewStopper()
+	defer s.Stop(ctx)
+	var wg sync.WaitGroup
+	f := func(context.Context) { wg.Done() }
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		wg.Add(1)
+		if err := s.RunAsyncTask(ctx, f); err != nil {
+			b.Fatal(err)
+		}
+		wg.Wait()
+	}
+}
+
+func BenchmarkStopperAsyncPar(b *testing.B) {
+	ctx := context.Background()
+	s := stop.NewStopper()
+	defer s.Stop(ctx)
+	var wg sync.WaitGroup
+	f := func(context.Context) { wg.Done() }
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			wg.Add(1)
+			if err := s.RunAsyncTask(ctx, f); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	wg.Wait()
+}
+
 func BenchmarkDirectCallPar(b *testing.B) {
 	s := stop.NewStopper()
 	ctx := context.Background()
@@ -573,6 +1242,7 @@ func BenchmarkStopperPar(b *testing.B) {
 	ctx := context.Background()
 	s := stop.NewStopper()
 	defer s.Stop(ctx)
+	b.ReportAllocs()
 	b.RunParallel(func(pb *testing.PB) {
 		for pb.Next() {
 			if err := s.RunTask(ctx, maybePrint); err != nil {
@@ -582,6 +1252,136 @@ func BenchmarkStopperPar(b *testing.B) {
 	})
 }
 
+// TestStopperQuiesceConcurrentAdmission verifies that tasks submitted
+// concurrently with Quiesce are either waited for or rejected, now that
+// admission doesn't take the stopper lock.
+func TestStopperQuiesceConcurrentAdmission(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+
+	var quiesced int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				err := s.RunTask(ctx, func(context.Context) {
+					if atomic.LoadInt32(&quiesced) == 1 {
+						t.Error("task ran after Quiesce returned")
+					}
+				})
+				if err != nil {
+					return
+				}
+			}
+		}()
+	}
+	s.Quiesce(ctx)
+	atomic.StoreInt32(&quiesced, 1)
+	wg.Wait()
+	if n := s.NumTasks(); n != 0 {
+		t.Errorf("expected no running tasks, got %d", n)
+	}
+	s.Stop(ctx)
+}
+
+func TestStopperConcurrentStop(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	if err := s.RunAsyncTask(ctx, func(context.Context) {
+		<-release
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 4
+	reason := errors.New("reason")
+	returned := make(chan struct{}, n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			switch i {
+			case 0:
+				s.StopWithReason(ctx, reason)
+			case 1:
+				s.Quiesce(ctx)
+			default:
+				s.Stop(ctx)
+			}
+			returned <- struct{}{}
+		}(i)
+	}
+	SucceedsSoon(t, func() error {
+		if r := s.StopRequests(); r != n-1 {
+			return errors.Errorf("%d stop requests", r)
+		}
+		return nil
+	})
+	select {
+	case <-returned:
+		t.Fatal("returned with a task running")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(release)
+	for i := 0; i < n; i++ {
+		<-returned
+	}
+	select {
+	case <-s.IsStopped():
+	default:
+		t.Fatal("Stop returned before the stopper stopped")
+	}
+	if err := s.StopReason(); err != reason {
+		t.Fatalf("expected %v, got %v", reason, err)
+	}
+
+	// Stop returns right away once the stopper has stopped.
+	report := s.StopReport()
+	s.Stop(ctx)
+	if got := s.StopReport(); !reflect.DeepEqual(got, report) {
+		t.Fatalf("expected report %+v, got %+v", report, got)
+	}
+	if r := s.Status().StopRequests; r < n {
+		t.Fatalf("expected at least %d stop requests, got %d", n, r)
+	}
+}
+
+func TestStopperStopAsyncFromTask(t *testing.T) {
+	var buf bytes.Buffer
+	s := stop.NewStopper(stop.WithLogger(log.New(&buf, "", 0)))
+	ctx := context.Background()
+
+	reason := errors.New("disk full")
+	var stopped <-chan struct{}
+	var line int
+	if err := s.RunTask(ctx, func(ctx context.Context) {
+		_, line, _ = caller.Lookup(0)
+		stopped = s.StopAsyncWithReason(ctx, reason)
+		<-s.ShouldQuiesce()
+	}); err != nil {
+		t.Fatal(err)
+	}
+	<-stopped
+	if err := s.StopReason(); err != reason {
+		t.Fatalf("expected %v, got %v", reason, err)
+	}
+	site := fmt.Sprintf("stopper_test.go:%d (disk full)", line+1)
+	if !strings.Contains(buf.String(), site) {
+		t.Errorf("expected stop from %s to be logged, got %q", site, buf.String())
+	}
+
+	// Stopping again returns the same channel, which is closed.
+	if ch := s.StopAsync(ctx); ch != stopped {
+		t.Fatal("expected the channel returned by IsStopped")
+	}
+	if r := s.StopRequests(); r != 2 {
+		t.Fatalf("expected 2 stop requests, got %d", r)
+	}
+}
+
 // ===========================================================================
 //
 // The following helper functions are copied from cockroach's tree. This
@@ -591,7 +1391,8 @@ func BenchmarkStopperPar(b *testing.B) {
 // verbatim from:
 //  - github.com/cockroach/cockroachdb/pkg/util/retry.go
 //
-// RetryForDuration() copies verbatim from:
+// RetryForDuration() copied from the following, and since reimplemented with
+// stop.Retry:
 //  - github.com/cockroach/cockroachdb/pkg/testutils/soon.go
 //
 // ===========================================================================
@@ -637,17 +1438,11 @@ func SucceedsSoonDepth(depth int, t testing.TB, fn func() error) {
 // immediately at first and then successively with an exponential backoff
 // starting at 1ns and ending at the specified duration.
 func RetryForDuration(duration time.Duration, fn func() error) error {
-	deadline := time.Now().Add(duration)
-	var lastErr error
-	for wait := time.Duration(1); time.Now().Before(deadline); wait *= 2 {
-		lastErr = fn()
-		if lastErr == nil {
-			return nil
-		}
-		if wait > time.Second {
-			wait = time.Second
-		}
-		time.Sleep(wait)
-	}
-	return lastErr
+	return stop.Retry(context.Background(), nil, stop.RetryOptions{
+		InitialBackoff: 1,
+		MaxBackoff:     time.Second,
+		MaxDuration:    duration,
+	}, func(context.Context) error {
+		return fn()
+	})
 }
@@ -0,0 +1,81 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stoptest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+	"github.com/birkelund/stop/stoptest"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(0, 0)
+	c := stoptest.NewFakeClock(start)
+
+	after := c.After(time.Second)
+	ticker := c.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	c.Advance(500 * time.Millisecond)
+	select {
+	case <-after:
+		t.Fatal("expected timer not to fire early")
+	default:
+	}
+
+	c.Advance(500 * time.Millisecond)
+	if now := <-after; !now.Equal(start.Add(time.Second)) {
+		t.Fatalf("expected timer to fire at %s, got %s", start.Add(time.Second), now)
+	}
+	<-ticker.Chan()
+
+	c.Advance(time.Second)
+	<-ticker.Chan()
+	if n := c.Waiters(); n != 1 {
+		t.Fatalf("expected only the ticker to be pending, got %d waiters", n)
+	}
+}
+
+func TestFakeClockBackgroundGracePeriod(t *testing.T) {
+	c := stoptest.NewFakeClock(time.Unix(0, 0))
+	s := stop.NewStopper(stop.WithClock(c), stop.BackgroundGracePeriod(time.Hour))
+	ctx := context.Background()
+
+	block := make(chan struct{})
+	defer close(block)
+	if err := s.RunBackgroundTask(ctx, func(context.Context) { <-block }); err != nil {
+		t.Fatal(err)
+	}
+
+	go s.Stop(ctx)
+	stoptest.SucceedsSoon(t, func() error {
+		if c.Waiters() == 0 {
+			return errors.New("expected Stop to wait for the grace period")
+		}
+		return nil
+	})
+
+	c.Advance(time.Hour)
+	select {
+	case <-s.IsStopped():
+	case <-time.After(time.Second):
+		t.Fatal("expected stopper to stop after the grace period")
+	}
+}
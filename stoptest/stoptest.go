@@ -0,0 +1,116 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package stoptest provides helpers for testing code which uses a
+// stop.Stopper.
+package stoptest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+
+	"golang.org/x/net/context"
+)
+
+// DefaultSucceedsSoonDuration is the maximum amount of time SucceedsSoon waits
+// for a condition to become true.
+const DefaultSucceedsSoonDuration = 45 * time.Second
+
+// CleanupStopTimeout is the time the cleanup registered by NewStopper waits
+// for the stopper to stop before failing the test.
+const CleanupStopTimeout = time.Minute
+
+// NewStopper returns a stopper which is stopped when the test and its subtests
+// complete. The test fails if the stopper has not stopped within
+// CleanupStopTimeout, for instance because a task does not return.
+func NewStopper(t testing.TB, options ...stop.Option) *stop.Stopper {
+	s := stop.NewStopper(options...)
+	t.Cleanup(func() {
+		// Stop is idempotent, so this also covers a stopper which the test
+		// stopped, or only quiesced, itself.
+		select {
+		case <-s.StopAsync(context.Background()):
+		case <-time.After(CleanupStopTimeout):
+			t.Errorf("stopper did not stop within %s; running tasks:\n%s",
+				CleanupStopTimeout, s.RunningTasks())
+		}
+	})
+	return s
+}
+
+// RequireStopsWithin stops the stopper and fails the test if it has not
+// stopped within d, listing the tasks still running.
+func RequireStopsWithin(t testing.TB, s *stop.Stopper, d time.Duration) {
+	t.Helper()
+	go s.Stop(context.Background())
+
+	select {
+	case <-s.IsStopped():
+	case <-time.After(d):
+		t.Fatalf("stopper did not stop within %s; running tasks:\n%s", d, s.RunningTasks())
+	}
+}
+
+// RequireNoRunningTasks fails the test if the stopper has any running tasks.
+func RequireNoRunningTasks(t testing.TB, s *stop.Stopper) {
+	t.Helper()
+	if n := s.NumTasks(); n != 0 {
+		t.Fatalf("expected no running tasks, got %d:\n%s", n, s.RunningTasks())
+	}
+}
+
+// SucceedsSoon fails the test unless fn returns nil within
+// DefaultSucceedsSoonDuration. Function fn is invoked immediately at first and
+// then successively with an exponential backoff starting at 1ns and capped at
+// one second.
+func SucceedsSoon(t testing.TB, fn func() error) {
+	t.Helper()
+	if err := stop.Retry(context.Background(), nil, stop.RetryOptions{
+		InitialBackoff: 1,
+		MaxBackoff:     time.Second,
+		MaxDuration:    DefaultSucceedsSoonDuration,
+	}, func(context.Context) error {
+		return fn()
+	}); err != nil {
+		t.Fatalf("condition failed to evaluate within %s: %s", DefaultSucceedsSoonDuration, err)
+	}
+}
+
+// VerifyNoLeaks arranges for the test to fail if, once the test and the
+// cleanup functions registered after the call have completed, any stopper
+// created by stop.NewStopper since the call has not been stopped. Stoppers
+// which were running already, such as package-level stoppers or those of
+// other tests, are not reported. The creation stacks of the leaked stoppers
+// are reported if stop.EnableLeakTracking has been called. VerifyNoLeaks
+// should be called at the start of the test.
+func VerifyNoLeaks(t testing.TB) {
+	running := map[*stop.Stopper]bool{}
+	for _, l := range stop.LeakedStoppers() {
+		running[l.Stopper] = true
+	}
+	t.Cleanup(func() {
+		for _, l := range stop.LeakedStoppers() {
+			if running[l.Stopper] {
+				continue
+			}
+			if l.Stack != nil {
+				t.Errorf("stopper %p was not stopped; created at:\n%s", l.Stopper, l.Stack)
+			} else {
+				t.Errorf("stopper %p was not stopped", l.Stopper)
+			}
+		}
+	})
+}
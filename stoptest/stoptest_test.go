@@ -0,0 +1,103 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stoptest_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+	"github.com/birkelund/stop/stoptest"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+func TestStoptest(t *testing.T) {
+	s := stoptest.NewStopper(t)
+	ctx := context.Background()
+
+	done := make(chan struct{})
+	if err := s.RunAsyncTask(ctx, func(context.Context) { <-done }); err != nil {
+		t.Fatal(err)
+	}
+	close(done)
+
+	stoptest.SucceedsSoon(t, func() error {
+		if n := s.NumTasks(); n != 0 {
+			return errors.Errorf("expected no tasks, got %d", n)
+		}
+		return nil
+	})
+	stoptest.RequireNoRunningTasks(t, s)
+	stoptest.RequireStopsWithin(t, s, time.Second)
+}
+
+func TestStoptestCleanup(t *testing.T) {
+	var s *stop.Stopper
+	t.Run("sub", func(t *testing.T) {
+		s = stoptest.NewStopper(t)
+	})
+
+	select {
+	case <-s.IsStopped():
+	default:
+		t.Fatal("expected stopper to be stopped at the end of the subtest")
+	}
+
+	// A stopper which the test only quiesced is stopped as well.
+	t.Run("quiesced", func(t *testing.T) {
+		s = stoptest.NewStopper(t)
+		s.Quiesce(context.Background())
+	})
+	select {
+	case <-s.IsStopped():
+	default:
+		t.Fatal("expected quiesced stopper to be stopped at the end of the subtest")
+	}
+}
+
+func TestVerifyNoLeaks(t *testing.T) {
+	// A stopper created before the call is not reported, even though it is
+	// only stopped after the check has run.
+	before := stop.NewStopper()
+	t.Cleanup(func() { before.Stop(context.Background()) })
+
+	stoptest.VerifyNoLeaks(t)
+	prev := stop.SetLeakTracking(true)
+	t.Cleanup(func() { stop.SetLeakTracking(prev) })
+
+	s := stop.NewStopper()
+	leaked := func() []byte {
+		for _, l := range stop.LeakedStoppers() {
+			if l.Stopper == s {
+				return l.Stack
+			}
+		}
+		return nil
+	}
+	if stack := leaked(); !strings.Contains(string(stack), "stoptest_test.go") {
+		t.Fatalf("expected creation stack of leaked stopper, got %q", stack)
+	}
+
+	s.Stop(context.Background())
+	if leaked() != nil {
+		t.Fatal("expected stopped stopper not to be reported")
+	}
+
+	// Stoppers stopped by cleanup functions are not reported.
+	stoptest.NewStopper(t)
+}
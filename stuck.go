@@ -0,0 +1,152 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// A StuckTask describes a task which is still running well after its context
+// was canceled (see DetectIgnoredCancellation).
+type StuckTask struct {
+	TaskInfo
+	// Stack is the stack trace of the goroutine running the task.
+	Stack []byte
+}
+
+// A watchedTask is a running task tracked for diagnostics.
+type watchedTask struct {
+	info     TaskInfo
+	ctx      context.Context
+	gid      []byte    // goroutine running the task
+	start    time.Time // time the task began to run
+	reported bool      // reported as slow
+}
+
+// watchedTasks holds the running tasks if diagnostics which need them, such
+// as DetectIgnoredCancellation and WithSlowTaskThreshold, are enabled.
+type watchedTasks struct {
+	sync.Mutex
+	m map[*watchedTask]struct{} // nil if tasks are not watched
+}
+
+// watch registers a task running on the calling goroutine, returning a
+// function to unregister it.
+func (s *Stopper) watch(ctx context.Context, info TaskInfo) func() {
+	t := &watchedTask{info: info, ctx: ctx, gid: goroutineID(), start: s.clock.Now()}
+	s.watched.Lock()
+	s.watched.m[t] = struct{}{}
+	s.watched.Unlock()
+	return func() {
+		s.watched.Lock()
+		delete(s.watched.m, t)
+		s.watched.Unlock()
+	}
+}
+
+type optionDetectIgnoredCancellation struct {
+	after  time.Duration
+	report func(StuckTask)
+}
+
+func (odic optionDetectIgnoredCancellation) apply(stopper *Stopper) {
+	stopper.ignoredCancellation = odic
+	stopper.watched.m = map[*watchedTask]struct{}{}
+}
+
+// DetectIgnoredCancellation is an option which helps finding the code that
+// holds up shutdown. Once the stopper has been quiescing for the given time,
+// report is called for each task which is still running although its context
+// has been canceled, such as by CancelTasksOnQuiesce or WithCancel, with the
+// stack of the goroutine running it. A nil report logs the tasks instead.
+//
+// It is meant as a diagnostic mode, as it makes running a task more
+// expensive.
+func DetectIgnoredCancellation(after time.Duration, report func(StuckTask)) Option {
+	return optionDetectIgnoredCancellation{after, report}
+}
+
+type optionReportBlockingTasks struct {
+	after  time.Duration
+	report func(StuckTask)
+}
+
+func (orbt optionReportBlockingTasks) apply(stopper *Stopper) {
+	stopper.blockingTasks = orbt
+	stopper.watched.m = map[*watchedTask]struct{}{}
+}
+
+// ReportBlockingTasks is an option which makes debugging a stopper that will
+// not stop possible from its logs alone. Once the stopper has been quiescing
+// for the given time, report is called for each task which is still running,
+// and hence holding up Stop, with the stack of the goroutine running it. A nil
+// report logs the tasks instead.
+//
+// Like DetectIgnoredCancellation, it makes running a task more expensive.
+func ReportBlockingTasks(after time.Duration, report func(StuckTask)) Option {
+	return optionReportBlockingTasks{after, report}
+}
+
+// reportStuckTasks calls report, or logs what the tasks are doing, for the
+// watched tasks matching the filter once after has elapsed, unless the
+// stopper is stopping by then. It is started when the stopper begins to
+// quiesce.
+func (s *Stopper) reportStuckTasks(
+	stopping <-chan struct{}, after time.Duration, report func(StuckTask),
+	filter func(*watchedTask) bool, what string,
+) {
+	select {
+	case <-s.clock.After(after):
+	case <-stopping:
+		return
+	}
+
+	s.watched.Lock()
+	var stuck []*watchedTask
+	for t := range s.watched.m {
+		if filter(t) {
+			stuck = append(stuck, t)
+		}
+	}
+	s.watched.Unlock()
+
+	for _, t := range stuck {
+		st := StuckTask{t.info, goroutineStack(t.gid)}
+		if report != nil {
+			report(st)
+		} else {
+			s.logger.Printf("task %s %s:\n%s", st.Task, what, st.Stack)
+		}
+	}
+}
+
+// detectStuckTasks starts reporting the tasks configured with
+// DetectIgnoredCancellation and ReportBlockingTasks.
+func (s *Stopper) detectStuckTasks() {
+	if o := s.ignoredCancellation; o.after > 0 {
+		go s.reportStuckTasks(s.stopper, o.after, o.report, func(t *watchedTask) bool {
+			return t.ctx.Err() != nil
+		}, fmt.Sprintf("still running %s after its context was canceled", o.after))
+	}
+	if o := s.blockingTasks; o.after > 0 {
+		go s.reportStuckTasks(s.stopper, o.after, o.report, func(*watchedTask) bool {
+			return true
+		}, fmt.Sprintf("blocking Stop after quiescing for %s", o.after))
+	}
+}
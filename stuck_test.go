@@ -0,0 +1,93 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperDetectIgnoredCancellation(t *testing.T) {
+	stuck := make(chan stop.StuckTask, 2)
+	s := stop.NewStopper(
+		stop.CancelTasksOnQuiesce(),
+		stop.DetectIgnoredCancellation(10*time.Millisecond, func(st stop.StuckTask) { stuck <- st }),
+	)
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	if err := s.RunAsyncTask(ctx, func(context.Context) { <-release }, stop.TaskName("stubborn")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RunAsyncTask(ctx, func(ctx context.Context) { <-ctx.Done() }, stop.TaskName("polite")); err != nil {
+		t.Fatal(err)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop(ctx)
+		close(stopped)
+	}()
+
+	st := <-stuck
+	if st.Task != "stubborn" {
+		t.Errorf("expected the stubborn task to be reported, got %s", st.Task)
+	}
+	if !strings.Contains(string(st.Stack), "TestStopperDetectIgnoredCancellation") {
+		t.Errorf("expected the stack of the task, got:\n%s", st.Stack)
+	}
+	close(release)
+	<-stopped
+	select {
+	case st := <-stuck:
+		t.Errorf("unexpected report of %s", st.Task)
+	default:
+	}
+}
+
+func TestStopperReportBlockingTasks(t *testing.T) {
+	blocking := make(chan stop.StuckTask, 2)
+	s := stop.NewStopper(stop.ReportBlockingTasks(10*time.Millisecond, func(st stop.StuckTask) { blocking <- st }))
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	if err := s.RunAsyncTask(ctx, func(ctx context.Context) {
+		// Honoring the context does not help if it is never canceled.
+		select {
+		case <-ctx.Done():
+		case <-release:
+		}
+	}, stop.TaskName("snapshot")); err != nil {
+		t.Fatal(err)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop(ctx)
+		close(stopped)
+	}()
+
+	st := <-blocking
+	if st.Task != "snapshot" || !strings.Contains(string(st.Stack), "TestStopperReportBlockingTasks") {
+		t.Errorf("unexpected blocking task %s:\n%s", st.Task, st.Stack)
+	}
+	close(release)
+	<-stopped
+}
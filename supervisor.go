@@ -0,0 +1,198 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+// ErrTooManyRestarts is reported when a supervised worker exceeds the
+// MaxRestarts of its RestartPolicy.
+var ErrTooManyRestarts = errors.New("supervised worker restarted too many times")
+
+// A GiveUpAction determines what happens when a supervised worker exceeds the
+// MaxRestarts of its RestartPolicy.
+type GiveUpAction int
+
+const (
+	// DropWorker stops restarting the worker and leaves the stopper running.
+	DropWorker GiveUpAction = iota
+	// StopStopper stops the stopper, with the error reported to OnGiveUp as
+	// the stop reason.
+	StopStopper
+)
+
+// A RestartPolicy controls how a supervised worker is restarted.
+//
+// The delay before the first restart is InitialBackoff. Each consecutive
+// restart multiplies the delay by Multiplier, up to MaxBackoff. If the worker
+// ran for longer than MaxBackoff before exiting, the delay is reset to
+// InitialBackoff.
+//
+// If MaxRestarts is positive, the supervisor gives up once the worker has been
+// restarted more than MaxRestarts times within RestartWindow (or ever, if
+// RestartWindow is zero). OnGiveUp, if not nil, is called with the worker name
+// and an error wrapping ErrTooManyRestarts and the last failure, after which
+// GiveUp is carried out.
+type RestartPolicy struct {
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+
+	MaxRestarts   int
+	RestartWindow time.Duration
+	GiveUp        GiveUpAction
+	OnGiveUp      func(name string, err error)
+}
+
+// DefaultRestartPolicy is used by RunSupervisedWorker for zero fields of the
+// given RestartPolicy.
+var DefaultRestartPolicy = RestartPolicy{
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     30 * time.Second,
+	Multiplier:     2,
+}
+
+func (rp RestartPolicy) withDefaults() RestartPolicy {
+	if rp.InitialBackoff <= 0 {
+		rp.InitialBackoff = DefaultRestartPolicy.InitialBackoff
+	}
+	if rp.MaxBackoff <= 0 {
+		rp.MaxBackoff = DefaultRestartPolicy.MaxBackoff
+	}
+	if rp.MaxBackoff < rp.InitialBackoff {
+		rp.MaxBackoff = rp.InitialBackoff
+	}
+	if rp.Multiplier < 1 {
+		rp.Multiplier = DefaultRestartPolicy.Multiplier
+	}
+	return rp
+}
+
+// RunSupervisedWorker runs the supplied function as a worker (see RunWorker)
+// and restarts it with exponential backoff, as described by policy, whenever
+// it returns or panics. Restarts cease once the stopper begins to quiesce;
+// long-running functions should watch ShouldQuiesce() or ShouldStop() and
+// return.
+//
+// With the WithFailFast option, a worker which returns an error or panics is
+// not restarted; the stopper is stopped instead.
+//
+// Panics are reported to the OnPanic handler, if any, and otherwise logged.
+// Unlike other functions run by the stopper, a panicking supervised worker
+// never brings down the process.
+//
+// Returns ErrUnavailable if the stopper is already stopping, in which case
+// function f is not called.
+func (s *Stopper) RunSupervisedWorker(
+	ctx context.Context, name string, f func(context.Context) error, policy RestartPolicy,
+) error {
+	policy = policy.withDefaults()
+
+	return s.RunWorker(ctx, func(ctx context.Context) {
+		var restarts []time.Time
+		backoff := policy.InitialBackoff
+		for {
+			start := s.clock.Now()
+			err := s.runSupervised(ctx, name, f)
+
+			select {
+			case <-s.ShouldQuiesce():
+				return
+			default:
+			}
+
+			if err != nil && s.failFast {
+				s.failFastStop(&TaskError{Task: name, Phase: PhaseRun, Err: err})
+				return
+			}
+
+			if s.clock.Now().Sub(start) > policy.MaxBackoff {
+				backoff = policy.InitialBackoff
+			}
+
+			if policy.MaxRestarts > 0 {
+				now := s.clock.Now()
+				restarts = append(restarts, now)
+				if policy.RestartWindow > 0 {
+					cutoff := now.Add(-policy.RestartWindow)
+					for len(restarts) > 0 && restarts[0].Before(cutoff) {
+						restarts = restarts[1:]
+					}
+				}
+				if len(restarts) > policy.MaxRestarts {
+					s.giveUp(ctx, name, err, policy)
+					return
+				}
+			}
+
+			if err != nil {
+				s.logger.Printf("supervised worker %q failed: %v; restarting in %s", name, err, backoff)
+			} else {
+				s.logger.Printf("supervised worker %q exited; restarting in %s", name, backoff)
+			}
+
+			select {
+			case <-s.clock.After(backoff):
+			case <-s.ShouldQuiesce():
+				return
+			}
+
+			backoff = time.Duration(float64(backoff) * policy.Multiplier)
+			if backoff > policy.MaxBackoff {
+				backoff = policy.MaxBackoff
+			}
+		}
+	})
+}
+
+func (s *Stopper) giveUp(ctx context.Context, name string, err error, policy RestartPolicy) {
+	if err == nil {
+		err = errors.New("worker exited")
+	}
+	err = errors.Wrapf(ErrTooManyRestarts, "%s: more than %d restarts, last failure: %v", name, policy.MaxRestarts, err)
+	s.logger.Printf("giving up on supervised worker %q: %v", name, err)
+
+	if policy.OnGiveUp != nil {
+		policy.OnGiveUp(name, err)
+	}
+
+	if policy.GiveUp == StopStopper {
+		// Stop waits for all workers, including this one, so it must be called
+		// asynchronously.
+		s.StopAsyncWithReason(ctx, err)
+	}
+}
+
+// runSupervised calls f, converting a panic into an error after reporting it.
+func (s *Stopper) runSupervised(
+	ctx context.Context, name string, f func(context.Context) error,
+) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if !s.reportPanic(PanicInfo{r, debug.Stack(), name}) {
+				s.logger.Printf("%v", r)
+			}
+			err = errors.Errorf("panic: %v", r)
+		}
+	}()
+
+	return f(ctx)
+}
@@ -0,0 +1,133 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+var testRestartPolicy = stop.RestartPolicy{
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     5 * time.Millisecond,
+}
+
+func TestStopperRunSupervisedWorker(t *testing.T) {
+	var panics int32
+	s := stop.NewStopper(stop.OnPanic(func(interface{}) {
+		atomic.AddInt32(&panics, 1)
+	}))
+	ctx := context.Background()
+
+	var runs int32
+	s.RunSupervisedWorker(ctx, "test", func(context.Context) error {
+		switch atomic.AddInt32(&runs, 1) {
+		case 1:
+			return errors.New("boom")
+		case 2:
+			panic("boom")
+		case 3:
+			return nil
+		}
+		<-s.ShouldQuiesce()
+		return nil
+	}, testRestartPolicy)
+
+	SucceedsSoon(t, func() error {
+		if n := atomic.LoadInt32(&runs); n < 4 {
+			return errors.Errorf("expected worker to be restarted 3 times, got %d runs", n)
+		}
+		return nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		s.Stop(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("stopper should have finished waiting")
+	}
+
+	if n := atomic.LoadInt32(&runs); n != 4 {
+		t.Errorf("expected worker not to be restarted after quiesce, got %d runs", n)
+	}
+	if n := atomic.LoadInt32(&panics); n != 1 {
+		t.Errorf("expected 1 reported panic, got %d", n)
+	}
+}
+
+func TestStopperRunSupervisedWorkerGiveUp(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+
+	var runs int32
+	gaveUp := make(chan error, 1)
+	policy := testRestartPolicy
+	policy.MaxRestarts = 2
+	policy.OnGiveUp = func(name string, err error) {
+		if name != "dropped" {
+			t.Errorf("unexpected worker name %q", name)
+		}
+		gaveUp <- err
+	}
+	s.RunSupervisedWorker(ctx, "dropped", func(context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		return errors.New("boom")
+	}, policy)
+
+	select {
+	case err := <-gaveUp:
+		if errors.Cause(err) != stop.ErrTooManyRestarts {
+			t.Fatalf("expected %v; got %v", stop.ErrTooManyRestarts, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected supervisor to give up")
+	}
+	if n := atomic.LoadInt32(&runs); n != 3 {
+		t.Fatalf("expected 3 runs, got %d", n)
+	}
+
+	// Dropping the worker leaves the stopper running.
+	select {
+	case <-s.ShouldQuiesce():
+		t.Fatal("expected stopper to keep running")
+	default:
+	}
+
+	policy.OnGiveUp = nil
+	policy.GiveUp = stop.StopStopper
+	s.RunSupervisedWorker(ctx, "fatal", func(context.Context) error {
+		return errors.New("boom")
+	}, policy)
+
+	select {
+	case <-s.IsStopped():
+	case <-time.After(time.Second):
+		t.Fatal("expected supervisor to stop the stopper")
+	}
+	if err := s.StopReason(); errors.Cause(err) != stop.ErrTooManyRestarts {
+		t.Fatalf("expected stop reason caused by %v; got %v", stop.ErrTooManyRestarts, err)
+	}
+}
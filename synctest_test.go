@@ -0,0 +1,68 @@
+//go:build go1.25
+
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/birkelund/stop"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+// TestStopperSynctest checks that a stopper, including its timers, leaves no
+// goroutines behind in a synctest bubble once stopped.
+func TestStopperSynctest(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		s := stop.NewStopper(
+			stop.BackgroundGracePeriod(time.Minute),
+			stop.Watchdog(time.Second, func(stop.WedgedWorker) {}),
+		)
+		ctx := context.Background()
+
+		if err := s.RunAsyncTask(ctx, func(context.Context) {
+			time.Sleep(time.Hour)
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RunSupervisedWorker(ctx, "flaky", func(context.Context) error {
+			return errors.New("boom")
+		}, stop.RestartPolicy{}); err != nil {
+			t.Fatal(err)
+		}
+
+		// A background task which outlives the grace period is abandoned.
+		block := make(chan struct{})
+		if err := s.RunBackgroundTask(ctx, func(context.Context) {
+			<-block
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		start := time.Now()
+		s.Stop(ctx)
+		if d := time.Since(start); d != time.Hour+time.Minute {
+			t.Errorf("expected Stop to take %s, took %s", time.Hour+time.Minute, d)
+		}
+
+		close(block)
+		synctest.Wait()
+	})
+}
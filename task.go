@@ -0,0 +1,392 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/birkelund/caller"
+
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+// A TaskOption can be passed to the Run*Task functions.
+type TaskOption interface {
+	apply(*taskOptions)
+}
+
+type taskOptions struct {
+	name       string            // tracking name, instead of the call site
+	file       string            // call site, if tasks are tracked
+	line       int               // call site line, if tasks are tracked
+	onPanic    func(interface{}) // overrides the stopper panic handler if set
+	onPanicSet bool              // true if onPanic should be used, even if nil
+
+	panicsAsErrors bool  // return panics as a *PanicError from synchronous tasks
+	weight         int64 // semaphore units acquired by limited tasks
+	maxQueueDepth  int   // bound on waiting limited tasks, if positive
+
+	acquireTimeout time.Duration // bound on waiting for the semaphore, if positive
+	priority       TaskPriority  // admission priority while quiescing
+	untracked      bool          // only counted in total, not per key
+	longLived      bool          // drains work while quiescing, see Pipeline
+}
+
+func makeTaskOptions(opts []TaskOption) taskOptions {
+	if len(opts) == 0 {
+		// Avoid allocating o, which escapes through the apply calls.
+		return taskOptions{weight: 1}
+	}
+	o := &taskOptions{weight: 1}
+	for _, opt := range opts {
+		opt.apply(o)
+	}
+	return *o
+}
+
+// site returns the call site the task was submitted from, or the empty
+// string if it was not recorded.
+func (o *taskOptions) site() string {
+	if o.file == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", o.file, o.line)
+}
+
+type optionTaskName string
+
+func (otn optionTaskName) apply(o *taskOptions) {
+	o.name = string(otn)
+}
+
+// TaskName is a task option which makes the stopper track the task by the given
+// name instead of by its call site, for instance in RunningTasks() and in
+// panic reports.
+func TaskName(name string) TaskOption {
+	return optionTaskName(name)
+}
+
+type optionUntracked struct{}
+
+func (optionUntracked) apply(o *taskOptions) {
+	o.untracked = true
+}
+
+// Untracked is a task option which makes the stopper skip the per-task
+// bookkeeping for the task, as for all tasks with the UntrackedTasks option.
+func Untracked() TaskOption {
+	return optionUntracked{}
+}
+
+// optionLongLived marks a task which runs for the lifetime of a component and
+// drains its work once the stopper quiesces, such as a pipeline stage worker.
+// Its context is not canceled on quiesce (see CancelTasksOnQuiesce), and it
+// is not reported as slow or stuck.
+type optionLongLived struct{}
+
+func (optionLongLived) apply(o *taskOptions) {
+	o.longLived = true
+}
+
+// makeTaskKey returns the key a task is tracked by. This is the task name, if
+// given, and otherwise the call site of the Run*Task function calling
+// makeTaskKey. The call site is also recorded in o, unless the task is
+// untracked.
+func (s *Stopper) makeTaskKey(o *taskOptions) taskKey {
+	untracked := s.untracked || o.untracked
+	if s.trackTasks && !untracked {
+		o.file, o.line = lookupCallSite(2)
+	}
+	if o.name != "" {
+		return taskKey{name: o.name, untracked: untracked}
+	}
+	key := taskKey{file: "???", line: 1, untracked: untracked}
+	if o.file != "" {
+		key.file, key.line = o.file, o.line
+	}
+	return key
+}
+
+type callSite struct {
+	file string
+	line int
+}
+
+// callSites caches the call sites returned by lookupCallSite, keyed by
+// program counter.
+var callSites sync.Map // map[uintptr]callSite
+
+// lookupCallSite returns the file and line of the caller at the given depth,
+// like caller.Lookup. Resolving a program counter to a file and line is
+// expensive compared to running a task, so the result is cached per program
+// counter, which is cheap to obtain.
+func lookupCallSite(depth int) (string, int) {
+	var pcs [1]uintptr
+	if runtime.Callers(depth+2, pcs[:]) == 0 {
+		return "???", 1
+	}
+	if cs, ok := callSites.Load(pcs[0]); ok {
+		return cs.(callSite).file, cs.(callSite).line
+	}
+	file, line, _ := caller.Lookup(depth + 1)
+	callSites.Store(pcs[0], callSite{file, line})
+	return file, line
+}
+
+type optionWeight int64
+
+func (ow optionWeight) apply(o *taskOptions) {
+	o.weight = int64(ow)
+}
+
+// Weight is a task option which makes a limited async task acquire n units of
+// its semaphore instead of one, so that expensive tasks consume proportionally
+// more of the concurrency budget. It is meant to be used with a
+// WeightedSemaphore (see NewWeightedSemaphore); the semaphore returned by
+// ChanSemaphore acquires units one at a time.
+func Weight(n int64) TaskOption {
+	return optionWeight(n)
+}
+
+type optionMaxQueueDepth int
+
+func (omqd optionMaxQueueDepth) apply(o *taskOptions) {
+	o.maxQueueDepth = int(omqd)
+}
+
+// MaxQueueDepth is a task option which bounds the number of limited async
+// tasks that may be waiting for the same semaphore (with wait set to true). A
+// submission beyond the bound is rejected with ErrQueueFull instead of
+// waiting. A non-positive n means no bound, which is the default.
+func MaxQueueDepth(n int) TaskOption {
+	return optionMaxQueueDepth(n)
+}
+
+type optionAcquireTimeout time.Duration
+
+func (oat optionAcquireTimeout) apply(o *taskOptions) {
+	o.acquireTimeout = time.Duration(oat)
+}
+
+// AcquireTimeout is a task option which bounds the time a limited async task
+// submitted with wait set to true spends waiting for its semaphore. If the
+// timeout expires first, ErrThrottled is returned. Unlike a deadline on the
+// context, the timeout does not apply to the execution of the task.
+func AcquireTimeout(d time.Duration) TaskOption {
+	return optionAcquireTimeout(d)
+}
+
+// A TaskPriority determines whether a task is admitted while the stopper is
+// quiescing.
+type TaskPriority int
+
+const (
+	// NormalPriority tasks are rejected with ErrQuiescing once the stopper
+	// begins to quiesce. This is the default.
+	NormalPriority TaskPriority = iota
+	// HighPriority tasks are admitted while the stopper is quiescing, for as
+	// long as other tasks are still running. Once all tasks have drained, they
+	// are rejected like other tasks.
+	HighPriority
+)
+
+type optionPriority TaskPriority
+
+func (op optionPriority) apply(o *taskOptions) {
+	o.priority = TaskPriority(op)
+}
+
+// Priority is a task option which sets the priority of the task. It lets
+// critical operations, such as those needed by other tasks to finish, still
+// run while the stopper drains, while new work of normal priority is shed.
+//
+// The TaskPriority of a limited async task only affects its admission; a
+// task waiting for its semaphore gives up when the stopper begins to
+// quiesce regardless.
+func Priority(p TaskPriority) TaskOption {
+	return optionPriority(p)
+}
+
+type optionTaskPanicHandler func(interface{})
+
+func (otph optionTaskPanicHandler) apply(o *taskOptions) {
+	o.onPanic = otph
+	o.onPanicSet = true
+}
+
+// TaskPanicHandler is a task option which makes the task recover from panics
+// using the provided panic handler instead of the one given to the stopper with
+// OnPanic. A nil handler means panics in the task are not recovered, even if
+// the stopper has a panic handler.
+func TaskPanicHandler(handler func(interface{})) TaskOption {
+	return optionTaskPanicHandler(handler)
+}
+
+// A TaskPhase tells at which point a task failed.
+type TaskPhase int
+
+const (
+	// PhaseAdmission means the task was rejected by the stopper, for instance
+	// because it is quiescing or paused.
+	PhaseAdmission TaskPhase = iota
+	// PhaseThrottle means the task was rejected or gave up while waiting for
+	// its semaphore.
+	PhaseThrottle
+	// PhaseRun means the task failed while running.
+	PhaseRun
+)
+
+func (p TaskPhase) String() string {
+	switch p {
+	case PhaseAdmission:
+		return "admission"
+	case PhaseThrottle:
+		return "throttle"
+	case PhaseRun:
+		return "run"
+	}
+	return fmt.Sprintf("TaskPhase(%d)", int(p))
+}
+
+// A TaskError is returned by the Run*Task functions when a task is not run,
+// or when it panics and was run with the PanicsAsErrors option. It wraps the
+// cause, such as ErrThrottled, ErrQuiescing or a *PanicError, which can be
+// matched with errors.Is and errors.As. Errors returned by the task function
+// itself are returned as is.
+type TaskError struct {
+	// Task identifies the task by name or call site.
+	Task string
+	// Site is the call site the task was submitted from. It is empty if task
+	// tracking is disabled (see TrackTasks).
+	Site string
+	// Phase tells at which point the task failed.
+	Phase TaskPhase
+	// Err is the cause of the failure.
+	Err error
+}
+
+func (e *TaskError) Error() string {
+	return fmt.Sprintf("task %s: %s: %v", e.Task, e.Phase, e.Err)
+}
+
+// Unwrap returns the cause of the failure.
+func (e *TaskError) Unwrap() error {
+	return e.Err
+}
+
+// newTaskError returns a *TaskError wrapping err, or nil if err is nil.
+func newTaskError(key taskKey, o *taskOptions, err error) error {
+	if err == nil {
+		return nil
+	}
+	te := &TaskError{Task: key.String(), Site: o.site(), Err: err}
+	switch err := err.(type) {
+	case *PanicError:
+		te.Phase = PhaseRun
+	case *phaseError:
+		te.Phase, te.Err = err.phase, err.err
+	default:
+		if errors.Is(err, ErrThrottled) || errors.Is(err, ErrQueueFull) {
+			te.Phase = PhaseThrottle
+		}
+	}
+	return te
+}
+
+// A phaseError sets the phase of the *TaskError returned for err, where it
+// cannot be told from err itself, such as for a context error.
+type phaseError struct {
+	phase TaskPhase
+	err   error
+}
+
+func (e *phaseError) Error() string {
+	return e.err.Error()
+}
+
+// A PanicError is wrapped in a *TaskError returned by RunTask and
+// RunTaskWithErr when a task run with the PanicsAsErrors option panics.
+type PanicError struct {
+	// Value is the value returned by recover().
+	Value interface{}
+	// Stack is the stack trace of the panicking goroutine.
+	Stack []byte
+	// Task identifies the panicking task by name or call site.
+	Task string
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic in task %s: %v", e.Task, e.Value)
+}
+
+type optionPanicsAsErrors struct{}
+
+func (optionPanicsAsErrors) apply(o *taskOptions) {
+	o.panicsAsErrors = true
+}
+
+// PanicsAsErrors is a task option which makes RunTask and RunTaskWithErr
+// recover a panic in the task and return it as a *PanicError (wrapped in a
+// *TaskError, see errors.As), instead of handing it to the panic handler. It
+// is meant for callers who treat panics in plugin-style code as ordinary
+// failures. It has no effect on async tasks.
+func PanicsAsErrors() TaskOption {
+	return optionPanicsAsErrors{}
+}
+
+// recoverTask is like Recover, but honors the task options. For synchronous
+// tasks, errp points to the error to be returned to the caller.
+func (s *Stopper) recoverTask(ctx context.Context, key taskKey, o *taskOptions, errp *error) {
+	if r := recover(); r != nil {
+		s.taskPanicked(ctx, r, key, o, errp)
+	}
+}
+
+// taskPanicked handles the panic of a task with the recovered value r, like
+// recoverTask.
+func (s *Stopper) taskPanicked(
+	ctx context.Context, r interface{}, key taskKey, o *taskOptions, errp *error,
+) {
+	if o.panicsAsErrors && errp != nil {
+		stack := debug.Stack()
+		s.recordPanic(PanicInfo{r, stack, key.String()})
+		*errp = newTaskError(key, o, &PanicError{r, stack, key.String()})
+		return
+	}
+	s.handlePanic(ctx, r, key.String(), o)
+}
+
+// handlePanic calls the panic handler in effect for the task with the
+// recovered value r. If there is no handler, the panic is re-raised.
+func (s *Stopper) handlePanic(ctx context.Context, r interface{}, task string, o *taskOptions) {
+	info := PanicInfo{r, debug.Stack(), task}
+	if o != nil && o.onPanicSet {
+		s.recordPanic(info)
+		if o.onPanic != nil {
+			o.onPanic(r)
+			return
+		}
+	} else if s.reportPanic(info) {
+		return
+	}
+	s.logger.Printf("%v", r)
+	panic(r)
+}
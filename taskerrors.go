@@ -0,0 +1,118 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+type optionCollectTaskErrors struct{}
+
+func (optionCollectTaskErrors) apply(stopper *Stopper) {
+	stopper.collectErrors = true
+}
+
+// CollectTaskErrors is an option which makes the stopper collect the errors
+// returned by async tasks run with RunAsync, so that a service learns in one
+// place that background work failed. The errors are returned by Err and
+// TaskErrors, and listed in the StopReport.
+func CollectTaskErrors() Option {
+	return optionCollectTaskErrors{}
+}
+
+// TaskErrors is the error returned by Err, listing the errors of the failed
+// async tasks, each a *TaskError, in the order they failed.
+type TaskErrors []error
+
+func (e TaskErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d async tasks failed: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the errors of the failed tasks.
+func (e TaskErrors) Unwrap() []error {
+	return e
+}
+
+// TaskErrors returns the errors collected from async tasks, each a
+// *TaskError, in the order the tasks failed (see CollectTaskErrors).
+func (s *Stopper) TaskErrors() []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]error(nil), s.mu.taskErrors...)
+}
+
+// Err returns the errors collected from async tasks as TaskErrors, or nil if
+// none failed (see CollectTaskErrors). Once IsStopped() is closed, it covers
+// the whole run of the stopper.
+func (s *Stopper) Err() error {
+	if errs := s.TaskErrors(); len(errs) > 0 {
+		return TaskErrors(errs)
+	}
+	return nil
+}
+
+type optionFailFast struct{}
+
+func (optionFailFast) apply(stopper *Stopper) {
+	stopper.failFast = true
+}
+
+// WithFailFast is an option which makes the first error returned by a task
+// stop the stopper, with the *TaskError as the reason (see StopWithReason),
+// like an errgroup.Group at the scope of the process. It applies to the
+// error-returning tasks, such as those of RunTaskWithErr, Run and RunAsync,
+// and to supervised workers, which are then not restarted.
+func WithFailFast() Option {
+	return optionFailFast{}
+}
+
+// runError returns the *TaskError for the error returned by a task.
+func runError(key taskKey, o *taskOptions, err error) *TaskError {
+	return &TaskError{Task: key.String(), Site: o.site(), Phase: PhaseRun, Err: err}
+}
+
+// asyncTaskFailed handles the error returned by an async task.
+func (s *Stopper) asyncTaskFailed(key taskKey, o *taskOptions, err error) {
+	te := runError(key, o, err)
+	s.logger.Printf("%v", te)
+	if s.failFast {
+		s.failFastStop(te)
+	}
+	if !s.collectErrors {
+		return
+	}
+	s.mu.Lock()
+	s.mu.taskErrors = append(s.mu.taskErrors, te)
+	s.mu.Unlock()
+}
+
+// failFastStop stops the stopper with the error of a failed task as the
+// reason, unless it is already stopping. Stop waits for the running tasks,
+// including the failed one, so it is stopped asynchronously.
+func (s *Stopper) failFastStop(err error) {
+	select {
+	case <-s.ShouldQuiesce():
+		return
+	default:
+	}
+	s.StopAsyncWithReason(context.Background(), err)
+}
@@ -0,0 +1,114 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/birkelund/stop"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperCollectTaskErrors(t *testing.T) {
+	s := stop.NewStopper(stop.CollectTaskErrors())
+	ctx := context.Background()
+
+	errFailed := errors.New("failed")
+	for _, name := range []string{"a", "b"} {
+		done := make(chan struct{})
+		if err := stop.RunAsync(ctx, s, func(context.Context) error {
+			defer close(done)
+			return errFailed
+		}, stop.TaskName(name)); err != nil {
+			t.Fatal(err)
+		}
+		<-done
+	}
+	if err := stop.RunAsync(ctx, s, func(context.Context) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	s.Stop(ctx)
+
+	err := s.Err()
+	if !errors.Is(err, errFailed) || !strings.HasPrefix(err.Error(), "2 async tasks failed") {
+		t.Fatalf("expected aggregate of 2 errors, got %v", err)
+	}
+	errs := s.TaskErrors()
+	var te *stop.TaskError
+	if len(errs) != 2 || !errors.As(errs[1], &te) || te.Task != "b" || te.Phase != stop.PhaseRun {
+		t.Fatalf("expected task errors of a and b, got %v", errs)
+	}
+	if report := s.StopReport(); len(report.TaskErrors) != 2 {
+		t.Errorf("expected task errors in stop report, got %v", report.TaskErrors)
+	}
+
+	if err := s.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Err(); err != nil {
+		t.Errorf("expected no errors after Reset, got %v", err)
+	}
+	s.Stop(ctx)
+}
+
+func TestStopperTaskErrorsNotCollected(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+	if err := stop.RunAsync(ctx, s, func(context.Context) error {
+		return errors.New("failed")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s.Stop(ctx)
+	if err := s.Err(); err != nil {
+		t.Errorf("expected errors not to be collected, got %v", err)
+	}
+}
+
+func TestStopperFailFast(t *testing.T) {
+	errFailed := errors.New("failed")
+	ctx := context.Background()
+	testCases := []struct {
+		name string
+		run  func(*stop.Stopper) error
+	}{
+		{"RunTaskWithErr", func(s *stop.Stopper) error {
+			return s.RunTaskWithErr(ctx, func(context.Context) error { return errFailed }, stop.TaskName("task"))
+		}},
+		{"RunAsync", func(s *stop.Stopper) error {
+			return stop.RunAsync(ctx, s, func(context.Context) error { return errFailed }, stop.TaskName("task"))
+		}},
+		{"RunSupervisedWorker", func(s *stop.Stopper) error {
+			return s.RunSupervisedWorker(ctx, "task", func(context.Context) error {
+				return errFailed
+			}, stop.RestartPolicy{})
+		}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := stop.NewStopper(stop.WithFailFast())
+			_ = tc.run(s)
+			<-s.IsStopped()
+
+			var te *stop.TaskError
+			if reason := s.StopReason(); !errors.As(reason, &te) || te.Task != "task" || !errors.Is(te, errFailed) {
+				t.Fatalf("expected task error as stop reason, got %v", reason)
+			}
+		})
+	}
+}
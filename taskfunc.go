@@ -0,0 +1,75 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import "golang.org/x/net/context"
+
+// TaskFunc is the type of the functions accepted by Run and RunAsync: either
+// a function returning nothing, as taken by RunTask, or a function returning
+// an error, as taken by RunTaskWithErr.
+type TaskFunc interface {
+	func(context.Context) | func(context.Context) error
+}
+
+// ErrFunc adapts f to a function returning an error, which is nil if f
+// returns nothing.
+func ErrFunc[F TaskFunc](f F) func(context.Context) error {
+	switch f := any(f).(type) {
+	case func(context.Context) error:
+		return f
+	case func(context.Context):
+		return func(ctx context.Context) error {
+			f(ctx)
+			return nil
+		}
+	}
+	panic("unreachable")
+}
+
+// Run is RunTask or RunTaskWithErr, depending on the type of f. It returns
+// the error returned by f, if any.
+func Run[F TaskFunc](ctx context.Context, s *Stopper, f F, opts ...TaskOption) (err error) {
+	o := makeTaskOptions(opts)
+	key := s.makeTaskKey(&o)
+	if err := s.runPrelude(key, &o); err != nil {
+		return newTaskError(key, &o, err)
+	}
+
+	// Call f.
+	defer s.recoverTask(ctx, key, &o, &err)
+	defer s.runPostlude(key)
+
+	return s.runTaskFuncWithErr(ctx, key, &o, false, ErrFunc(f))
+}
+
+// RunAsync is RunAsyncTask, accepting either type of TaskFunc. An error
+// returned by f is logged and, with the CollectTaskErrors option, collected.
+func RunAsync[F TaskFunc](ctx context.Context, s *Stopper, f F, opts ...TaskOption) error {
+	o := makeTaskOptions(opts)
+	key := s.makeTaskKey(&o)
+	var t *asyncTask
+	switch f := any(f).(type) {
+	case func(context.Context):
+		t = newAsyncTask(s, ctx, f)
+	case func(context.Context) error:
+		t = newAsyncTask(s, ctx, func(ctx context.Context) {
+			if err := f(ctx); err != nil {
+				s.asyncTaskFailed(key, &o, err)
+			}
+		})
+	}
+	t.o, t.key = o, key
+	return s.startAsyncTask(t)
+}
@@ -0,0 +1,88 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/birkelund/stop"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+func TestRun(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+
+	called := false
+	if err := stop.Run(ctx, s, func(context.Context) {
+		called = true
+	}); err != nil || !called {
+		t.Fatalf("expected task to run, got called=%t, err=%v", called, err)
+	}
+	errFailed := errors.New("failed")
+	if err := stop.Run(ctx, s, func(context.Context) error {
+		return errFailed
+	}); err != errFailed {
+		t.Fatalf("expected %v, got %v", errFailed, err)
+	}
+
+	s.Stop(ctx)
+	if err := stop.Run(ctx, s, func(context.Context) {
+		t.Error("unexpected call after Stop")
+	}); !errors.Is(err, stop.ErrUnavailable) {
+		t.Fatalf("expected %v, got %v", stop.ErrUnavailable, err)
+	}
+}
+
+func TestRunAsync(t *testing.T) {
+	var buf bytes.Buffer
+	s := stop.NewStopper(stop.WithLogger(log.New(&buf, "", 0)))
+	ctx := context.Background()
+
+	done := make(chan struct{})
+	if err := stop.RunAsync(ctx, s, func(context.Context) {
+		close(done)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if err := stop.RunAsync(ctx, s, func(context.Context) error {
+		return errors.New("failed")
+	}, stop.TaskName("sync")); err != nil {
+		t.Fatal(err)
+	}
+	s.Stop(ctx)
+
+	if !strings.Contains(buf.String(), "task sync: run: failed") {
+		t.Errorf("expected error to be logged, got %q", buf.String())
+	}
+}
+
+func TestErrFunc(t *testing.T) {
+	ctx := context.Background()
+	called := false
+	if err := stop.ErrFunc(func(context.Context) { called = true })(ctx); err != nil || !called {
+		t.Errorf("expected nil error from called function, got called=%t, err=%v", called, err)
+	}
+	errFailed := errors.New("failed")
+	if err := stop.ErrFunc(func(context.Context) error { return errFailed })(ctx); err != errFailed {
+		t.Errorf("expected %v, got %v", errFailed, err)
+	}
+}
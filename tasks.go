@@ -0,0 +1,107 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// taskShards is the number of shards of a taskRegistry. It is a power of two
+// so that a shard can be picked by masking the hash of a task key.
+const taskShards = 32
+
+// A taskRegistry counts the running tasks per key. The counts are spread over
+// shards by the hash of the key, so that tasks submitted from different call
+// sites don't contend on a single lock. Reads aggregate over all shards.
+//
+// Each count is an atomic counter, and the shard lock is only held for writing
+// to add a key, so that reading the counts, for instance by a monitoring loop
+// calling RunningTasks, doesn't block the submission of tasks.
+type taskRegistry struct {
+	shards [taskShards]taskShard
+}
+
+type taskShard struct {
+	sync.RWMutex
+	m map[taskKey]*atomic.Int64
+
+	// Pad the shard to a cache line to avoid false sharing between shards.
+	_ [64 - 32]byte
+}
+
+// shard returns the shard holding the count of key, using FNV-1a.
+func (r *taskRegistry) shard(key taskKey) *taskShard {
+	h := uint32(2166136261)
+	for i := 0; i < len(key.name); i++ {
+		h = (h ^ uint32(key.name[i])) * 16777619
+	}
+	for i := 0; i < len(key.file); i++ {
+		h = (h ^ uint32(key.file[i])) * 16777619
+	}
+	h = (h ^ uint32(key.line)) * 16777619
+	return &r.shards[h&(taskShards-1)]
+}
+
+// add adds delta to the count of running tasks with the given key, unless the
+// key is untracked. Keys are kept when their count drops to zero, so that
+// tasks submitted repeatedly from the same call site don't allocate.
+func (r *taskRegistry) add(key taskKey, delta int) {
+	if key.untracked {
+		return
+	}
+	sh := r.shard(key)
+	sh.RLock()
+	n := sh.m[key]
+	sh.RUnlock()
+	if n == nil {
+		sh.Lock()
+		if sh.m == nil {
+			sh.m = map[taskKey]*atomic.Int64{}
+		}
+		if n = sh.m[key]; n == nil {
+			n = new(atomic.Int64)
+			sh.m[key] = n
+		}
+		sh.Unlock()
+	}
+	n.Add(int64(delta))
+}
+
+// snapshot returns the counts of running tasks by name or call site.
+func (r *taskRegistry) snapshot() TaskMap {
+	m := TaskMap{}
+	for i := range r.shards {
+		sh := &r.shards[i]
+		sh.RLock()
+		for k, n := range sh.m {
+			if c := int(n.Load()); c != 0 {
+				m[k.String()] += c
+			}
+		}
+		sh.RUnlock()
+	}
+	return m
+}
+
+// reset forgets all keys. It must only be called while no tasks are running.
+func (r *taskRegistry) reset() {
+	for i := range r.shards {
+		sh := &r.shards[i]
+		sh.Lock()
+		sh.m = nil
+		sh.Unlock()
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/birkelund/stop"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperRunningTasksManyKeys(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+	release := make(chan struct{})
+	const keys, perKey = 100, 3
+	for i := 0; i < keys; i++ {
+		for j := 0; j < perKey; j++ {
+			if err := s.RunAsyncTask(ctx, func(context.Context) {
+				<-release
+			}, stop.TaskName(fmt.Sprintf("task-%d", i))); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tasks := s.RunningTasks()
+	if len(tasks) != keys {
+		t.Fatalf("expected %d keys, got %d:\n%s", keys, len(tasks), tasks)
+	}
+	for name, n := range tasks {
+		if n != perKey {
+			t.Errorf("expected %d tasks named %s, got %d", perKey, name, n)
+		}
+	}
+	if n := s.NumTasks(); n != keys*perKey {
+		t.Errorf("expected %d tasks, got %d", keys*perKey, n)
+	}
+
+	close(release)
+	s.Stop(ctx)
+	if tasks := s.RunningTasks(); len(tasks) != 0 {
+		t.Errorf("expected no running tasks, got:\n%s", tasks)
+	}
+}
+
+func TestStopperRunningTasksConcurrent(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+	done := make(chan struct{})
+	read := make(chan struct{})
+	go func() {
+		defer close(read)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			for name, n := range s.RunningTasks() {
+				if n < 0 || n > 4 {
+					t.Errorf("unexpected count %d for %s", n, name)
+				}
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if err := s.RunTask(ctx, func(context.Context) {}, stop.TaskName(fmt.Sprintf("task-%d", j%2))); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(done)
+	<-read
+
+	s.Stop(ctx)
+	if tasks := s.RunningTasks(); len(tasks) != 0 {
+		t.Errorf("expected no running tasks, got:\n%s", tasks)
+	}
+}
+
+func BenchmarkStopperWithRunningTasks(b *testing.B) {
+	ctx := context.Background()
+	s := stop.NewStopper()
+	defer s.Stop(ctx)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				_ = s.RunningTasks()
+			}
+		}
+	}()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := s.RunTask(ctx, func(context.Context) {}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
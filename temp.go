@@ -0,0 +1,71 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import "os"
+
+// A tempPath is a temporary file or directory removed by Stop.
+type tempPath struct {
+	path string
+	dir  bool
+}
+
+// TrackTempDir adds a temporary directory to be removed, with its contents,
+// by Stop once the closers have been closed, so that the scratch space of a
+// service which restarts often does not accumulate. An error removing it,
+// other than it not existing, is recorded as a CloserError (see
+// CloserErrors) and logged.
+func (s *Stopper) TrackTempDir(path string) {
+	s.trackTemp(tempPath{path, true})
+}
+
+// TrackTempFile is like TrackTempDir, but for a file, such as a Unix domain
+// socket or a lock file, which is removed with os.Remove.
+func (s *Stopper) TrackTempFile(path string) {
+	s.trackTemp(tempPath{path, false})
+}
+
+func (s *Stopper) trackTemp(tp tempPath) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mu.temps = append(s.mu.temps, tp)
+}
+
+// remove removes the file or directory, ignoring that it does not exist.
+func (tp tempPath) remove() error {
+	var err error
+	if tp.dir {
+		err = os.RemoveAll(tp.path)
+	} else {
+		err = os.Remove(tp.path)
+	}
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// removeTempLocked removes the temporary files and directories, the most
+// recently tracked first.
+func (s *Stopper) removeTempLocked() {
+	for i := len(s.mu.temps) - 1; i >= 0; i-- {
+		tp := s.mu.temps[i]
+		if err := tp.remove(); err != nil {
+			err = &CloserError{Closer: tp.path, Err: err}
+			s.logger.Printf("%v", err)
+			s.mu.closeErrors = append(s.mu.closeErrors, err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/birkelund/stop"
+
+	"golang.org/x/net/context"
+)
+
+func TestTrackTemp(t *testing.T) {
+	s := stop.NewStopper()
+	base := t.TempDir()
+
+	dir := filepath.Join(base, "scratch")
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "data"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sock := filepath.Join(base, "server.sock")
+	if err := os.WriteFile(sock, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s.TrackTempDir(dir)
+	s.TrackTempFile(sock)
+	s.TrackTempFile(filepath.Join(base, "missing"))
+
+	// The socket is still there when the closers run.
+	s.AddCloser(stop.CloserFn(func() {
+		if _, err := os.Stat(sock); err != nil {
+			t.Errorf("removed before closing: %v", err)
+		}
+	}))
+
+	s.Stop(context.Background())
+	for _, path := range []string{dir, sock} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed, got %v", path, err)
+		}
+	}
+	if errs := s.CloserErrors(); len(errs) != 0 {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+}
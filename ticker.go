@@ -0,0 +1,174 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"sync"
+	"time"
+)
+
+// A StopperTicker is like a time.Ticker, but is turned off, and its channel
+// closed, once the stopper begins to quiesce, so that a loop ranging over
+// the channel ends without watching ShouldQuiesce. It uses the clock of the
+// stopper.
+type StopperTicker struct {
+	// C is the channel on which the ticks are sent. Like for a time.Ticker,
+	// ticks are dropped for slow receivers.
+	C <-chan time.Time
+
+	c    chan time.Time
+	stop chan struct{} // closed by Stop, ending the goroutine forwarding ticks
+
+	mu        sync.Mutex
+	stopped   bool        // true once c is closed
+	dequiesce func() bool // deregisters Stop from AfterQuiesce
+}
+
+// NewTicker returns a StopperTicker which sends the time every d until the
+// stopper begins to quiesce or Stop is called. If the stopper is already
+// quiescing, the channel is closed right away.
+func (s *Stopper) NewTicker(d time.Duration) *StopperTicker {
+	c := make(chan time.Time, 1)
+	t := &StopperTicker{C: c, c: c, stop: make(chan struct{})}
+	select {
+	case <-s.ShouldQuiesce():
+		t.Stop()
+		return t
+	default:
+	}
+	t.mu.Lock()
+	t.dequiesce = s.AfterQuiesce(t.Stop)
+	t.mu.Unlock()
+
+	ticker := s.clock.NewTicker(d)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.Chan():
+				t.send(now)
+			case <-t.stop:
+				return
+			}
+		}
+	}()
+	return t
+}
+
+// send sends a tick, unless the ticker has been stopped.
+func (t *StopperTicker) send(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.stopped {
+		select {
+		case t.c <- now:
+		default:
+		}
+	}
+}
+
+// Stop turns off the ticker and closes its channel. No tick is sent once it
+// has returned. It may be called more than once.
+func (t *StopperTicker) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopped {
+		return
+	}
+	t.stopped = true
+	close(t.stop)
+	close(t.c)
+	if t.dequiesce != nil {
+		t.dequiesce()
+	}
+}
+
+// A StopperTimer is like a time.Timer, but is stopped, and its channel
+// closed, once the stopper begins to quiesce. It uses the clock of the
+// stopper.
+type StopperTimer struct {
+	// C delivers the time once the timer fires, and is then closed. It is
+	// closed without delivering a value if the timer is stopped first.
+	C <-chan time.Time
+
+	c    chan time.Time
+	stop chan struct{} // closed by Stop, ending the goroutine waiting to fire
+
+	mu        sync.Mutex
+	done      bool        // true once the timer has fired or been stopped
+	dequiesce func() bool // deregisters Stop from AfterQuiesce
+}
+
+// NewTimer returns a StopperTimer which fires once d has elapsed, unless the
+// stopper begins to quiesce or Stop is called first. A non-positive d fires
+// the timer right away.
+func (s *Stopper) NewTimer(d time.Duration) *StopperTimer {
+	c := make(chan time.Time, 1)
+	t := &StopperTimer{C: c, c: c, stop: make(chan struct{})}
+	if d <= 0 {
+		t.fire(s.clock.Now())
+		return t
+	}
+	t.mu.Lock()
+	t.dequiesce = s.AfterQuiesce(func() { t.Stop() })
+	t.mu.Unlock()
+
+	// A ticker, unlike After, can be turned off, so that the clock does not
+	// hold on to a timer which has been stopped.
+	ticker := s.clock.NewTicker(d)
+	go func() {
+		defer ticker.Stop()
+		select {
+		case now := <-ticker.Chan():
+			t.fire(now)
+		case <-t.stop:
+		}
+	}()
+	return t
+}
+
+// fire delivers now and closes the channel, unless the timer has been
+// stopped.
+func (t *StopperTimer) fire(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done {
+		return
+	}
+	t.done = true
+	t.c <- now
+	close(t.c)
+	if t.dequiesce != nil {
+		t.dequiesce()
+	}
+}
+
+// Stop stops the timer and closes its channel, unless the timer has fired. It
+// reports whether the call stopped the timer, like time.Timer.Stop: if it
+// returns true, the timer delivers no value.
+func (t *StopperTimer) Stop() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done {
+		return false
+	}
+	t.done = true
+	close(t.stop)
+	close(t.c)
+	if t.dequiesce != nil {
+		t.dequiesce()
+	}
+	return true
+}
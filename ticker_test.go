@@ -0,0 +1,153 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+	"github.com/birkelund/stop/stoptest"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopperTicker(t *testing.T) {
+	c := stoptest.NewFakeClock(time.Unix(0, 0))
+	s := stop.NewStopper(stop.WithClock(c))
+	ctx := context.Background()
+
+	tk := s.NewTicker(time.Second)
+	ticks := make(chan time.Time)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for now := range tk.C {
+			ticks <- now
+		}
+	}()
+
+	for i := 1; i <= 3; i++ {
+		c.Advance(time.Second)
+		if now := <-ticks; !now.Equal(time.Unix(int64(i), 0)) {
+			t.Fatalf("%d: unexpected tick at %s", i, now)
+		}
+	}
+
+	// Quiescing ends the loop and turns off the underlying ticker.
+	s.Quiesce(ctx)
+	<-done
+	SucceedsSoon(t, func() error {
+		if n := c.Waiters(); n != 0 {
+			return errors.Errorf("%d waiters", n)
+		}
+		return nil
+	})
+	s.Stop(ctx)
+
+	// A ticker created after quiescing is closed right away.
+	if _, ok := <-s.NewTicker(time.Second).C; ok {
+		t.Fatal("expected closed channel")
+	}
+}
+
+func TestStopperTickerStop(t *testing.T) {
+	c := stoptest.NewFakeClock(time.Unix(0, 0))
+	s := stop.NewStopper(stop.WithClock(c))
+	defer s.Stop(context.Background())
+
+	tk := s.NewTicker(time.Second)
+	tk.Stop()
+	tk.Stop()
+	if _, ok := <-tk.C; ok {
+		t.Fatal("expected closed channel")
+	}
+	SucceedsSoon(t, func() error {
+		if n := c.Waiters(); n != 0 {
+			return errors.Errorf("%d waiters", n)
+		}
+		return nil
+	})
+}
+
+func TestStopperTimer(t *testing.T) {
+	c := stoptest.NewFakeClock(time.Unix(0, 0))
+	s := stop.NewStopper(stop.WithClock(c))
+	ctx := context.Background()
+
+	tm := s.NewTimer(time.Second)
+	c.Advance(time.Second)
+	if now, ok := <-tm.C; !ok || !now.Equal(time.Unix(1, 0)) {
+		t.Fatalf("unexpected fire at %s (%t)", now, ok)
+	}
+	if _, ok := <-tm.C; ok {
+		t.Fatal("expected closed channel after firing")
+	}
+	if tm.Stop() {
+		t.Fatal("expected Stop to report a fired timer")
+	}
+
+	tm = s.NewTimer(time.Second)
+	if !tm.Stop() {
+		t.Fatal("expected Stop to stop the timer")
+	}
+	if _, ok := <-tm.C; ok {
+		t.Fatal("expected closed channel after Stop")
+	}
+
+	if _, ok := <-s.NewTimer(0).C; !ok {
+		t.Fatal("expected immediate fire")
+	}
+
+	tm = s.NewTimer(time.Second)
+	s.Quiesce(ctx)
+	if _, ok := <-tm.C; ok {
+		t.Fatal("expected closed channel after quiescing")
+	}
+	SucceedsSoon(t, func() error {
+		if n := c.Waiters(); n != 0 {
+			return errors.Errorf("%d waiters", n)
+		}
+		return nil
+	})
+	s.Stop(ctx)
+}
+
+func TestStopperTimerStopRace(t *testing.T) {
+	c := stoptest.NewFakeClock(time.Unix(0, 0))
+	s := stop.NewStopper(stop.WithClock(c))
+	defer s.Stop(context.Background())
+
+	// Stop racing with the timer firing either stops the timer, in which case
+	// no value is delivered, or reports that the timer has fired.
+	for i := 0; i < 100; i++ {
+		tm := s.NewTimer(time.Second)
+		advanced := make(chan struct{})
+		go func() {
+			defer close(advanced)
+			c.Advance(time.Second)
+		}()
+		stopped := tm.Stop()
+		_, fired := <-tm.C
+		<-advanced
+		if stopped && fired {
+			t.Fatalf("%d: timer delivered a value after Stop returned true", i)
+		}
+		if !stopped && !fired {
+			t.Fatalf("%d: Stop returned false, but the timer did not fire", i)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop
+
+import (
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+// ErrChannelClosed is returned by WaitOrStop if the channel is closed.
+var ErrChannelClosed = errors.New("channel closed")
+
+// WaitOrStop receives a value from ch. It returns the value once one is
+// received, or the zero value and an error: ErrChannelClosed if ch is closed,
+// ctx.Err() if ctx is done, or ErrQuiescing or ErrStopped if the stopper
+// begins to quiesce. If the stopper is already quiescing, it returns right
+// away. A nil stopper never quiesces.
+func WaitOrStop[T any](ctx context.Context, s *Stopper, ch <-chan T) (T, error) {
+	var zero T
+	select {
+	case <-s.ShouldQuiesce():
+		return zero, s.errUnavailable()
+	default:
+	}
+	select {
+	case v, ok := <-ch:
+		if !ok {
+			return zero, ErrChannelClosed
+		}
+		return v, nil
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case <-s.ShouldQuiesce():
+		return zero, s.errUnavailable()
+	}
+}
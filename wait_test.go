@@ -0,0 +1,71 @@
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stop_test
+
+import (
+	"testing"
+
+	"github.com/birkelund/stop"
+	"github.com/pkg/errors"
+
+	"golang.org/x/net/context"
+)
+
+func TestWaitOrStop(t *testing.T) {
+	s := stop.NewStopper()
+	ctx := context.Background()
+
+	ch := make(chan int, 1)
+	ch <- 42
+	if v, err := stop.WaitOrStop(ctx, s, ch); err != nil || v != 42 {
+		t.Fatalf("expected 42, got %d (%v)", v, err)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if v, err := stop.WaitOrStop(cctx, s, ch); err != context.Canceled || v != 0 {
+		t.Fatalf("expected %v, got %d (%v)", context.Canceled, v, err)
+	}
+
+	closed := make(chan int)
+	close(closed)
+	if _, err := stop.WaitOrStop(ctx, s, closed); err != stop.ErrChannelClosed {
+		t.Fatalf("expected %v, got %v", stop.ErrChannelClosed, err)
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := stop.WaitOrStop(ctx, s, ch)
+		errCh <- err
+	}()
+	s.Stop(ctx)
+	if err := <-errCh; !errors.Is(err, stop.ErrUnavailable) {
+		t.Fatalf("expected %v, got %v", stop.ErrUnavailable, err)
+	}
+
+	// A value ready after quiescing is not received.
+	ch <- 1
+	if _, err := stop.WaitOrStop(ctx, s, ch); !errors.Is(err, stop.ErrUnavailable) {
+		t.Fatalf("expected %v, got %v", stop.ErrUnavailable, err)
+	}
+}
+
+func TestWaitOrStopNilStopper(t *testing.T) {
+	ch := make(chan string, 1)
+	ch <- "a"
+	if v, err := stop.WaitOrStop(context.Background(), nil, ch); err != nil || v != "a" {
+		t.Fatalf("expected a, got %q (%v)", v, err)
+	}
+}
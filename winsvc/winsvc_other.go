@@ -0,0 +1,35 @@
+//go:build !windows
+
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package winsvc
+
+import (
+	"github.com/birkelund/stop"
+	"github.com/pkg/errors"
+)
+
+// ErrNotWindows is returned by Run on platforms other than Windows.
+var ErrNotWindows = errors.New("winsvc: not running on Windows")
+
+// Run returns ErrNotWindows.
+func Run(name string, s *stop.Stopper) error {
+	return ErrNotWindows
+}
+
+// IsService returns false.
+func IsService() (bool, error) {
+	return false, nil
+}
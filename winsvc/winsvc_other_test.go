@@ -0,0 +1,38 @@
+//go:build !windows
+
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package winsvc_test
+
+import (
+	"testing"
+
+	"github.com/birkelund/stop"
+	"github.com/birkelund/stop/winsvc"
+
+	"golang.org/x/net/context"
+)
+
+func TestRunNotWindows(t *testing.T) {
+	s := stop.NewStopper()
+	defer s.Stop(context.Background())
+
+	if err := winsvc.Run("test", s); err != winsvc.ErrNotWindows {
+		t.Fatalf("expected ErrNotWindows, got %v", err)
+	}
+	if ok, err := winsvc.IsService(); ok || err != nil {
+		t.Fatalf("expected not a service, got %t, %v", ok, err)
+	}
+}
@@ -0,0 +1,114 @@
+//go:build windows
+
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package winsvc
+
+import (
+	"time"
+
+	"github.com/birkelund/stop"
+
+	"golang.org/x/net/context"
+	"golang.org/x/sys/windows/svc"
+)
+
+// DefaultWaitHint is the WaitHint used by Run.
+const DefaultWaitHint = 5 * time.Second
+
+// A Handler is a svc.Handler controlling a stopper. The service is reported
+// as running right away; a STOP or SHUTDOWN control request stops the
+// stopper, and the service is reported as SERVICE_STOP_PENDING, with an
+// increasing checkpoint every WaitHint/2, until the stopper has stopped. The
+// same happens if the stopper is stopped otherwise.
+//
+// The service exits with the code returned by the ExitStatus of the stopper,
+// as a service-specific exit code.
+type Handler struct {
+	Stopper *stop.Stopper
+	// WaitHint is the time the service control manager is told to allow
+	// between checkpoints while the service is stopping. If zero,
+	// DefaultWaitHint is used.
+	WaitHint time.Duration
+}
+
+// Run runs the calling process as the service with the given name, returning
+// once s has stopped. It must be called early in main, as the service control
+// manager gives up on services which take too long to connect.
+func Run(name string, s *stop.Stopper) error {
+	return svc.Run(name, &Handler{Stopper: s})
+}
+
+// IsService reports whether the calling process is running as a Windows
+// service.
+func IsService() (bool, error) {
+	return svc.IsWindowsService()
+}
+
+// Execute implements svc.Handler.
+func (h *Handler) Execute(
+	args []string, r <-chan svc.ChangeRequest, changes chan<- svc.Status,
+) (svcSpecificEC bool, exitCode uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case c := <-r:
+			switch c.Cmd {
+			case svc.Interrogate:
+				changes <- c.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				go h.Stopper.Stop(context.Background())
+				return h.stopping(r, changes)
+			}
+		case <-h.Stopper.ShouldQuiesce():
+			return h.stopping(r, changes)
+		}
+	}
+}
+
+// stopping reports the service as stopping until the stopper has stopped.
+func (h *Handler) stopping(
+	r <-chan svc.ChangeRequest, changes chan<- svc.Status,
+) (svcSpecificEC bool, exitCode uint32) {
+	waitHint := h.WaitHint
+	if waitHint <= 0 {
+		waitHint = DefaultWaitHint
+	}
+	ticker := time.NewTicker(waitHint / 2)
+	defer ticker.Stop()
+
+	status := svc.Status{State: svc.StopPending, WaitHint: uint32(waitHint / time.Millisecond)}
+	for {
+		status.CheckPoint++
+		changes <- status
+
+	wait:
+		for {
+			select {
+			case <-h.Stopper.IsStopped():
+				code, _ := h.Stopper.ExitStatus()
+				return code != 0, uint32(code)
+			case c := <-r:
+				if c.Cmd == svc.Interrogate {
+					changes <- status
+				}
+			case <-ticker.C:
+				break wait
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+//go:build windows
+
+// Copyright 2026 Klaus Birkelund Jensen (birkelund@gmail.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package winsvc_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/birkelund/stop"
+	"github.com/birkelund/stop/winsvc"
+
+	"golang.org/x/net/context"
+	"golang.org/x/sys/windows/svc"
+)
+
+func execute(h *winsvc.Handler) (<-chan svc.Status, chan<- svc.ChangeRequest, <-chan uint32) {
+	changes := make(chan svc.Status, 100)
+	r := make(chan svc.ChangeRequest)
+	exit := make(chan uint32, 1)
+	go func() {
+		_, code := h.Execute([]string{"test"}, r, changes)
+		close(changes)
+		exit <- code
+	}()
+	return changes, r, exit
+}
+
+func TestHandlerStop(t *testing.T) {
+	s := stop.NewStopper()
+	block := make(chan struct{})
+	s.AddCloser(stop.CloserFn(func() { <-block }))
+
+	changes, r, exit := execute(&winsvc.Handler{Stopper: s, WaitHint: 20 * time.Millisecond})
+	for _, want := range []svc.State{svc.StartPending, svc.Running} {
+		if st := <-changes; st.State != want {
+			t.Fatalf("expected state %d, got %d", want, st.State)
+		}
+	}
+	r <- svc.ChangeRequest{Cmd: svc.Stop}
+
+	// The checkpoint advances while the stopper is stopping.
+	var checkpoint uint32
+	for checkpoint < 3 {
+		st := <-changes
+		if st.State != svc.StopPending {
+			t.Fatalf("expected stop pending, got %d", st.State)
+		}
+		if st.CheckPoint <= checkpoint {
+			t.Fatalf("expected checkpoint to advance past %d, got %d", checkpoint, st.CheckPoint)
+		}
+		checkpoint = st.CheckPoint
+	}
+	close(block)
+
+	if code := <-exit; code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	<-s.IsStopped()
+}
+
+func TestHandlerStopperStopped(t *testing.T) {
+	s := stop.NewStopper()
+	s.SetExitStatus(3, nil)
+
+	changes, _, exit := execute(&winsvc.Handler{Stopper: s})
+	s.Stop(context.Background())
+	if code := <-exit; code != 3 {
+		t.Fatalf("expected exit code 3, got %d", code)
+	}
+	for st := range changes {
+		if st.State == svc.StopPending {
+			return
+		}
+	}
+	t.Fatal("expected stop pending")
+}